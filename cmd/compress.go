@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/Axpz/store/internal/storage"
+)
+
+// runCompress 执行 store compress：按 storage.compression 重写全部表文件。
+// 开启压缩后用它压缩已有的表文件；改为 none 后用它把表文件恢复为 JSON。
+func runCompress(cfg *config.Config, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("compress", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := storage.New(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	recompressor, ok := store.(storage.Recompressor)
+	if !ok {
+		return fmt.Errorf("%s 存储不使用 JSON 表文件，不支持压缩: %w", cfg.Storage.Type, storage.ErrNotSupported)
+	}
+
+	reports, err := recompressor.Recompress(context.Background())
+	if err != nil {
+		return err
+	}
+
+	for _, r := range reports {
+		fmt.Fprintf(out, "%-10s %s -> %s  %d records  %d -> %d bytes\n",
+			r.Table, compressionName(r.From), compressionName(r.To), r.Records, r.Before, r.After)
+	}
+	return nil
+}
+
+// compressionName 返回报告中显示的压缩方式
+func compressionName(compression string) string {
+	if compression == "" {
+		return "none"
+	}
+	return compression
+}
@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/Axpz/store/internal/api"
+	"github.com/Axpz/store/internal/backup"
 	"github.com/Axpz/store/internal/config"
 	"github.com/Axpz/store/internal/middleware"
 	"github.com/Axpz/store/internal/service"
@@ -26,6 +33,32 @@ func main() {
 	cfg := config.Load("config.yaml")
 	cfg.Logger = logger
 
+	// 子命令：执行完即退出，不启动 HTTP 服务
+	if len(os.Args) > 1 {
+		command, args := os.Args[1], os.Args[2:]
+		var err error
+		switch command {
+		case "migrate":
+			err = runMigrate(cfg, args, os.Stdout)
+		case "backup":
+			err = runBackup(cfg, args, os.Stdout)
+		case "restore":
+			err = runRestore(cfg, args, os.Stdout)
+		case "copy":
+			err = runCopy(cfg, args, os.Stdout)
+		case "reencrypt":
+			err = runReencrypt(cfg, args, os.Stdout)
+		case "compress":
+			err = runCompress(cfg, args, os.Stdout)
+		default:
+			err = fmt.Errorf("unknown command %q (expected migrate, backup, restore, copy, reencrypt or compress)", command)
+		}
+		if err != nil {
+			log.Fatalf("%s failed: %v", command, err)
+		}
+		return
+	}
+
 	r := gin.Default()
 	r.Use(ginzap.Ginzap(logger, time.RFC3339, true))
 	r.Use(ginzap.RecoveryWithZap(logger, true))
@@ -39,6 +72,24 @@ func main() {
 		log.Fatalf("get store instace failed: %v", err)
 	}
 
+	// 定时备份
+	var scheduler *backup.Scheduler
+	if cfg.Backup.Dir != "" {
+		scheduler = backup.NewScheduler(store, cfg.Backup, logger)
+		scheduler.Start()
+	}
+
+	// 定时清理墓碑
+	var purger *storage.Purger
+	if cfg.Storage.Tombstones.Retention > 0 {
+		purger = storage.NewPurger(store, cfg.Storage.Tombstones, logger)
+		purger.Start()
+	}
+
+	// 定时清扫过期记录
+	sweeper := storage.NewSweeper(store, cfg.Storage.TTL, logger)
+	sweeper.Start()
+
 	// 创建支付提供者
 	payService, err := service.NewPaymentService(cfg)
 	if err != nil {
@@ -61,9 +112,47 @@ func main() {
 	productHandler := api.NewProductHandler(productService, cfg.JWT.Secret)
 	productHandler.RegisterRoutes(r)
 
+	// 管理接口
+	adminService := service.NewAdminService(store)
+	adminHandler := api.NewAdminHandler(adminService, cfg.JWT.Secret, cfg.Admin.Users)
+	adminHandler.RegisterRoutes(r)
+
 	addr := fmt.Sprintf(":%d", cfg.Server.Port)
-	log.Printf("server start at %s", addr)
-	if err := r.Run(addr); err != nil {
-		logger.Fatal("server start failed", zap.Error(err))
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: r,
+	}
+
+	go func() {
+		log.Printf("server start at %s", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal("server start failed", zap.Error(err))
+		}
+	}()
+
+	// 等待退出信号，先停止接收请求，再落盘存储中尚未提交的写入
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	logger.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("server shutdown failed", zap.Error(err))
+	}
+	if scheduler != nil {
+		scheduler.Stop()
+	}
+	if purger != nil {
+		purger.Stop()
+	}
+	sweeper.Stop()
+	if err := store.Flush(shutdownCtx); err != nil {
+		logger.Error("flush store failed", zap.Error(err))
+	}
+	if err := store.Close(); err != nil {
+		logger.Error("close store failed", zap.Error(err))
 	}
 }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/Axpz/store/internal/storage"
+)
+
+// runMigrate 执行 store migrate [--dry-run]：把 JSON 表文件升级到当前 schema 版本
+func runMigrate(cfg *config.Config, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "在数据副本上预览迁移，不修改数据")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := storage.New(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	migrator, ok := store.(storage.Migrator)
+	if !ok {
+		return fmt.Errorf("%s 存储不使用 JSON 表文件，无需迁移", cfg.Storage.Type)
+	}
+
+	reports, err := migrator.Migrate(context.Background(), *dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range reports {
+		status := "up to date"
+		if r.From < r.To {
+			status = "migrated"
+			if *dryRun {
+				status = "would migrate"
+			}
+		}
+		fmt.Fprintf(out, "%-10s v%d -> v%d  %-13s %d records, %d changed\n",
+			r.Table, r.From, r.To, status, r.Records, len(r.Changed))
+		if *dryRun && len(r.Changed) > 0 {
+			fmt.Fprintf(out, "           changed: %s\n", strings.Join(r.Changed, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/Axpz/store/internal/storage"
+)
+
+// runReencrypt 执行 store reencrypt：用当前密钥（密钥列表中的第一个）重写全部表文件。
+// 轮换密钥时先把新密钥加在最前面、保留旧密钥，执行本命令后再移除旧密钥。
+func runReencrypt(cfg *config.Config, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("reencrypt", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if cfg.Storage.Encryption.Keys == "" && cfg.Storage.Encryption.KeyFile == "" {
+		return errors.New("未配置加密密钥（storage.encryption.key_file 或 STORE_ENCRYPTION_KEYS）")
+	}
+
+	store, err := storage.New(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	reencrypter, ok := store.(storage.Reencrypter)
+	if !ok {
+		return fmt.Errorf("%s 存储不使用 JSON 表文件，不支持加密", cfg.Storage.Type)
+	}
+
+	reports, err := reencrypter.Reencrypt(context.Background())
+	if err != nil {
+		return err
+	}
+
+	for _, r := range reports {
+		from := r.From
+		if from == "" {
+			from = "plaintext"
+		}
+		fmt.Fprintf(out, "%-10s %s -> %s  %d records\n", r.Table, from, r.To, r.Records)
+	}
+	return nil
+}
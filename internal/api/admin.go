@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Axpz/store/internal/middleware"
+	"github.com/Axpz/store/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler struct {
+	adminService *service.AdminService
+	jwtSecret    string
+	admins       []string
+}
+
+func NewAdminHandler(adminService *service.AdminService, jwtSecret string, admins []string) *AdminHandler {
+	return &AdminHandler{
+		adminService: adminService,
+		jwtSecret:    jwtSecret,
+		admins:       admins,
+	}
+}
+
+func (h *AdminHandler) RegisterRoutes(router *gin.Engine) {
+	admin := router.Group("/api/admin")
+	admin.Use(middleware.Auth(h.jwtSecret), middleware.RequireAdmin(h.admins))
+	{
+		admin.GET("/history/:table/:id", h.GetHistory)
+		admin.POST("/restore/:table/:id", h.Restore)
+	}
+}
+
+// GetHistory 返回记录的修改历史，limit 参数是最多检查的提交数；
+// 带 at 参数（RFC 3339 时间）时只返回记录在该时刻的版本
+func (h *AdminHandler) GetHistory(c *gin.Context) {
+	table, id := c.Param("table"), c.Param("id")
+
+	if at := c.Query("at"); at != "" {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "at must be an RFC 3339 time")
+			return
+		}
+		record, err := h.adminService.GetAt(c, table, id, t)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, record)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		writeError(c, http.StatusBadRequest, "limit must be a non-negative integer")
+		return
+	}
+
+	revisions, err := h.adminService.History(c, table, id, limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  revisions,
+		"total": len(revisions),
+	})
+}
+
+// Restore 恢复被软删除的记录
+func (h *AdminHandler) Restore(c *gin.Context) {
+	if err := h.adminService.Restore(c, c.Param("table"), c.Param("id")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "record restored"})
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/Axpz/store/internal/storage"
+	"github.com/Axpz/store/internal/types"
+	"github.com/Axpz/store/internal/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 错误码，定义见 types
+const (
+	CodeInvalidArgument = types.CodeInvalidArgument
+	CodeUnauthenticated = types.CodeUnauthenticated
+	CodeForbidden       = types.CodeForbidden
+	CodeNotFound        = types.CodeNotFound
+	CodeAlreadyExists   = types.CodeAlreadyExists
+	CodeConflict        = types.CodeConflict
+	CodeTimeout         = types.CodeTimeout
+	CodeUnimplemented   = types.CodeUnimplemented
+	CodeInternal        = types.CodeInternal
+)
+
+// statusCodes 是各 HTTP 状态码默认对应的错误码
+var statusCodes = map[int]string{
+	http.StatusBadRequest:          CodeInvalidArgument,
+	http.StatusUnauthorized:        CodeUnauthenticated,
+	http.StatusForbidden:           CodeForbidden,
+	http.StatusNotFound:            CodeNotFound,
+	http.StatusConflict:            CodeConflict,
+	http.StatusGatewayTimeout:      CodeTimeout,
+	http.StatusNotImplemented:      CodeUnimplemented,
+	http.StatusInternalServerError: CodeInternal,
+}
+
+// errorStatus 把服务层返回的错误映射为 HTTP 状态码和错误码
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, storage.ErrInvalidQuery), errors.Is(err, storage.ErrInvalidArgument):
+		return http.StatusBadRequest, CodeInvalidArgument
+	case errors.Is(err, storage.ErrUnauthenticated):
+		return http.StatusUnauthorized, CodeUnauthenticated
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound, CodeNotFound
+	case errors.Is(err, storage.ErrAlreadyExists):
+		return http.StatusConflict, CodeAlreadyExists
+	case errors.Is(err, storage.ErrConflict):
+		return http.StatusConflict, CodeConflict
+	case errors.Is(err, storage.ErrForbidden):
+		return http.StatusForbidden, CodeForbidden
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, CodeTimeout
+	case errors.Is(err, storage.ErrNotSupported):
+		return http.StatusNotImplemented, CodeUnimplemented
+	default:
+		return http.StatusInternalServerError, CodeInternal
+	}
+}
+
+// respondError 按错误类别返回错误响应。内部错误只记录日志，不把细节返回给客户端
+func respondError(c *gin.Context, err error) {
+	status, code := errorStatus(err)
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		utils.LoggerFromContext(c.Request.Context()).Error("request failed", zap.Error(err))
+		message = "internal error"
+	}
+	c.JSON(status, types.ErrorResponse{Code: code, Error: message})
+}
+
+// writeError 返回处理器自身检查出的错误（参数错误、未登录等），错误码由状态码决定
+func writeError(c *gin.Context, status int, message string) {
+	code, ok := statusCodes[status]
+	if !ok {
+		code = CodeInternal
+	}
+	c.JSON(status, types.ErrorResponse{Code: code, Error: message})
+}
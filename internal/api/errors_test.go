@@ -0,0 +1,37 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Axpz/store/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{fmt.Errorf("不能按字段 password 过滤: %w", storage.ErrInvalidQuery), http.StatusBadRequest, CodeInvalidArgument},
+		{fmt.Errorf("orders o-1: %w", storage.ErrNotFound), http.StatusNotFound, CodeNotFound},
+		{fmt.Errorf("users u-1: %w", storage.ErrAlreadyExists), http.StatusConflict, CodeAlreadyExists},
+		{&storage.ConflictError{Table: "orders", IDs: []string{"o-1"}}, http.StatusConflict, CodeConflict},
+		{fmt.Errorf("order o-1: %w", storage.ErrForbidden), http.StatusForbidden, CodeForbidden},
+		{fmt.Errorf("user id is empty: %w", storage.ErrUnauthenticated), http.StatusUnauthorized, CodeUnauthenticated},
+		{fmt.Errorf("order o-1 has no changes: %w", storage.ErrInvalidArgument), http.StatusBadRequest, CodeInvalidArgument},
+		{fmt.Errorf("加载表 users 失败: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, CodeTimeout},
+		{fmt.Errorf("local 存储不保留修改历史: %w", storage.ErrNotSupported), http.StatusNotImplemented, CodeUnimplemented},
+		{errors.New("disk full"), http.StatusInternalServerError, CodeInternal},
+	}
+
+	for _, tt := range tests {
+		status, code := errorStatus(tt.err)
+		assert.Equal(t, tt.status, status, tt.err.Error())
+		assert.Equal(t, tt.code, code, tt.err.Error())
+	}
+}
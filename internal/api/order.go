@@ -46,7 +46,7 @@ func (h *OrderHandler) RegisterRoutes(router *gin.Engine) {
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	var req types.CreateOrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		writeError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -62,13 +62,13 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 
 	err := h.orderService.CreateOrder(c, &order)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	dbOrder, err := h.orderService.GetOrder(c, order.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -109,28 +109,28 @@ func (h *OrderHandler) HandlePaymentWebhook(c *gin.Context) {
 func (h *OrderHandler) CaptureOrder(c *gin.Context) {
 	orderID := c.Param("id")
 	if orderID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "order id is required"})
+		writeError(c, http.StatusBadRequest, "order id is required")
 		return
 	}
 
 	order, err := h.orderService.GetOrder(c, orderID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "order id is not found" + err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	if order.UserID != utils.GetUserIDFromContext(c) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "order is not owned by current user"})
+		writeError(c, http.StatusForbidden, "order is not owned by current user")
 		return
 	}
 
 	if order.Status != "pending" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "order status is not pending"})
+		writeError(c, http.StatusConflict, "order status is not pending")
 		return
 	}
 
 	if err := h.payService.CaptureOrder(c, orderID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Errorf("failed to capture payment: %v", err)})
+		respondError(c, fmt.Errorf("failed to capture payment: %w", err))
 		return
 	}
 
@@ -144,12 +144,12 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 	id := c.Param("id")
 	order, err := h.orderService.GetOrder(c, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	if order == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+		writeError(c, http.StatusNotFound, "order not found")
 		return
 	}
 
@@ -159,36 +159,55 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 func (h *OrderHandler) GetOrders(c *gin.Context) {
 	logger := utils.LoggerFromContext(c.Request.Context())
 
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "100"))
+	limit, ok := queryLimit(c)
+	if !ok {
+		return
+	}
 
-	logger.Info("GetOrders", zap.Int("page", page), zap.Int("pageSize", pageSize))
+	logger.Info("GetOrders", zap.Int("limit", limit))
 
-	orders, total, err := h.orderService.GetOrders(c, page, pageSize)
+	orders, next, err := h.orderService.GetOrders(c, c.Query("status"), limit, c.Query("cursor"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data":  orders,
-		"total": total,
-		"page":  page,
-		"size":  pageSize,
+		"data":        orders,
+		"count":       len(orders),
+		"next_cursor": next,
 	})
 }
 
+// queryLimit 读取查询参数 limit（每页条数，0 或缺省表示不分页），无效时返回 400。
+// 已废弃的 page/page_size 参数也返回 400，避免客户端以为翻了页却一直拿到第一页
+func queryLimit(c *gin.Context) (int, bool) {
+	for _, name := range []string{"page", "page_size"} {
+		if _, ok := c.GetQuery(name); ok {
+			writeError(c, http.StatusBadRequest, name+" is no longer supported; use limit and cursor")
+			return 0, false
+		}
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		writeError(c, http.StatusBadRequest, "limit must be a non-negative integer")
+		return 0, false
+	}
+	return limit, true
+}
+
 func (h *OrderHandler) UpdateOrder(c *gin.Context) {
 	id := c.Param("id")
 	var req types.UpdateOrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		writeError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	dbOrder, err := h.orderService.GetOrder(c, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -200,7 +219,7 @@ func (h *OrderHandler) UpdateOrder(c *gin.Context) {
 
 	err = h.orderService.UpdateOrder(c, updateOrder)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -212,24 +231,24 @@ func (h *OrderHandler) DeleteOrder(c *gin.Context) {
 
 	userID := utils.GetUserIDFromContext(c)
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		writeError(c, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
 	order, err := h.orderService.GetOrder(c, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	if order.UserID != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden: you are not allowed to delete this order"})
+		writeError(c, http.StatusForbidden, "forbidden: you are not allowed to delete this order")
 		return
 	}
 
 	err = h.orderService.DeleteOrder(c, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
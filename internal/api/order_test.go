@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		query string
+		limit int
+		ok    bool
+	}{
+		{"", 0, true},
+		{"limit=20&cursor=abc", 20, true},
+		{"limit=-1", 0, false},
+		{"limit=x", 0, false},
+		{"page=2", 0, false},
+		{"limit=20&page_size=50", 0, false},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/orders?"+tt.query, nil)
+
+		limit, ok := queryLimit(c)
+		assert.Equal(t, tt.ok, ok, tt.query)
+		assert.Equal(t, tt.limit, limit, tt.query)
+		if !ok {
+			assert.Equal(t, http.StatusBadRequest, w.Code, tt.query)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package api
 
 import (
 	"net/http"
-	"strconv"
 
 	"github.com/Axpz/store/internal/service"
 	"github.com/Axpz/store/internal/utils"
@@ -42,12 +41,12 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 	id := c.Param("id")
 	product, err := h.productService.GetProduct(c, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	if product == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		writeError(c, http.StatusNotFound, "product not found")
 		return
 	}
 
@@ -57,22 +56,30 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 func (h *ProductHandler) GetProducts(c *gin.Context) {
 	logger := utils.LoggerFromContext(c.Request.Context())
 
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "100"))
+	limit, ok := queryLimit(c)
+	if !ok {
+		return
+	}
 
-	logger.Info("GetProducts", zap.Int("page", page), zap.Int("pageSize", pageSize))
+	logger.Info("GetProducts", zap.Int("limit", limit))
+
+	filter := make(map[string]string)
+	for _, field := range []string{"type", "status"} {
+		if value := c.Query(field); value != "" {
+			filter[field] = value
+		}
+	}
 
-	products, err := h.productService.GetProducts(c)
+	products, next, err := h.productService.GetProducts(c, filter, limit, c.Query("cursor"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data":  products,
-		"total": len(products),
-		"page":  page,
-		"size":  pageSize,
+		"data":        products,
+		"count":       len(products),
+		"next_cursor": next,
 	})
 }
 
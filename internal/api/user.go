@@ -1,18 +1,18 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/Axpz/store/internal/pkg/jwt"
 	"github.com/Axpz/store/internal/service"
+	"github.com/Axpz/store/internal/storage"
 	"github.com/Axpz/store/internal/types"
 	"github.com/Axpz/store/internal/utils"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 // UserHandler 用户处理器
@@ -61,7 +61,7 @@ func (h *UserHandler) RegisterRoutes(router *gin.Engine) {
 func (h *UserHandler) Login(c *gin.Context) {
 	var req types.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request parameters"})
+		writeError(c, http.StatusBadRequest, "invalid request parameters")
 		return
 	}
 
@@ -72,17 +72,17 @@ func (h *UserHandler) Login(c *gin.Context) {
 
 	user, err := h.userService.GetUser(c, utils.GetUserIDFromEmail(req.Email))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		respondError(c, err)
 		return
 	}
 
 	if user.Verified != nil && !*user.Verified {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not verified, please check your email for verification."})
+		writeError(c, http.StatusUnauthorized, "User not verified, please check your email for verification.")
 		return
 	}
 
 	if !user.CheckPassword(req.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
+		writeError(c, http.StatusUnauthorized, "invalid username or password")
 		return
 	}
 
@@ -91,7 +91,7 @@ func (h *UserHandler) Login(c *gin.Context) {
 	// 生成token
 	token, err := jwt.GenerateToken(user.ID, user.Username, h.jwtSecret, 7*24*time.Hour)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		writeError(c, http.StatusInternalServerError, "failed to generate token")
 		return
 	}
 
@@ -126,7 +126,7 @@ func (h *UserHandler) SignUp(c *gin.Context) {
 	var logger = utils.LoggerFromContext(c.Request.Context())
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request parameters" + err.Error()})
+		writeError(c, http.StatusBadRequest, "invalid request parameters"+err.Error())
 		return
 	}
 
@@ -143,19 +143,19 @@ func (h *UserHandler) SignUp(c *gin.Context) {
 	user.HashPassword()
 
 	userGet, err := h.userService.GetUser(c, utils.GetUserIDFromEmail(user.Email))
-	if err != nil && status.Code(err) != codes.NotFound {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user:" + err.Error()})
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		respondError(c, err)
 		return
 	}
 
 	if userGet != nil {
 		if userGet.Verified == nil || *userGet.Verified {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "user already exists"})
+			respondError(c, fmt.Errorf("user %s: %w", userGet.ID, storage.ErrAlreadyExists))
 			return
 		}
 	} else {
 		if err := h.userService.CreateUser(c, &user); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user:" + err.Error()})
+			respondError(c, err)
 			return
 		}
 	}
@@ -163,7 +163,7 @@ func (h *UserHandler) SignUp(c *gin.Context) {
 	tokenString, err := user.GenVerificationJWTToken(h.jwtSecret, 7*24*time.Hour)
 	if err != nil {
 		logger.Error("failed to generate verification token", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate verification token:" + err.Error()})
+		writeError(c, http.StatusInternalServerError, "failed to generate verification token:"+err.Error())
 		return
 	}
 
@@ -172,7 +172,7 @@ func (h *UserHandler) SignUp(c *gin.Context) {
 
 	if err := h.emailService.SendVerificationEmail(c, verificationLink, user.Email); err != nil {
 		logger.Error("failed to send verification email", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send verification email:" + err.Error()})
+		writeError(c, http.StatusInternalServerError, "failed to send verification email:"+err.Error())
 		return
 	}
 
@@ -185,7 +185,7 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 	var req types.RegisterRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request parameters"})
+		writeError(c, http.StatusBadRequest, "invalid request parameters")
 		return
 	}
 
@@ -203,19 +203,19 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 
 	// 加密密码
 	if err := user.HashPassword(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
+		writeError(c, http.StatusInternalServerError, "failed to hash password")
 		return
 	}
 
 	// 保存用户
 	if err := h.userService.CreateUser(c, &user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	userGet, err := h.userService.GetUser(c, user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user after creating"})
+		respondError(c, err)
 		return
 	}
 
@@ -227,7 +227,7 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 	id := c.Param("id")
 	user, err := h.userService.GetUser(c, id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		respondError(c, err)
 		return
 	}
 
@@ -238,7 +238,7 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	var userReq types.UpdateUserRequest
 	if err := c.ShouldBindJSON(&userReq); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request parameters"})
+		writeError(c, http.StatusBadRequest, "invalid request parameters")
 		return
 	}
 
@@ -260,13 +260,13 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	logger.Info("UpdateUser", zap.Any("req", logReq))
 
 	if err := h.userService.UpdateUser(c, &user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	userGet, err := h.userService.GetUser(c, user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user after updating"})
+		respondError(c, err)
 		return
 	}
 
@@ -277,7 +277,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	id := c.Param("id")
 	if err := h.userService.DeleteUser(c, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -289,7 +289,7 @@ func (h *UserHandler) Verify(c *gin.Context) {
 
 	tokenString := c.Query("token")
 	if tokenString == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing token"})
+		writeError(c, http.StatusBadRequest, "missing token")
 		return
 	}
 
@@ -297,7 +297,7 @@ func (h *UserHandler) Verify(c *gin.Context) {
 
 	user, err := user.VerifyAndParseVerificationJWTToken(h.jwtSecret, tokenString)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired token" + err.Error()})
+		writeError(c, http.StatusBadRequest, "invalid or expired token"+err.Error())
 		return
 	}
 
@@ -306,7 +306,7 @@ func (h *UserHandler) Verify(c *gin.Context) {
 
 	userGet, err := h.userService.GetUser(c, user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -317,7 +317,7 @@ func (h *UserHandler) Verify(c *gin.Context) {
 
 	if err := h.userService.UpdateUser(c, &user); err != nil {
 		logger.Error("failed to update user", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user"})
+		respondError(c, err)
 		return
 	}
 
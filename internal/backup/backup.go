@@ -0,0 +1,321 @@
+// Package backup 把任意存储后端的全部表快照为一个归档文件，并可以把归档恢复到任意后端。
+//
+// 归档是 gzip 压缩的 tar 文件：第一个文件是清单 manifest.json，
+// 之后每张表一个 tables/<表名>.json（记录 ID → 记录）。清单记录归档格式版本、
+// 每张表的 schema 版本、记录数和 SHA-256 校验和，恢复前先校验全部表。
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/Axpz/store/internal/storage"
+)
+
+// FormatVersion 是当前的归档格式版本
+const FormatVersion = 1
+
+const manifestFile = "manifest.json"
+
+// ErrCorrupt 表示归档不完整、校验和不匹配或格式无法识别
+var ErrCorrupt = errors.New("backup archive is corrupt")
+
+// Manifest 描述一个归档
+type Manifest struct {
+	Format  int         `json:"format"`
+	Created int64       `json:"created"`
+	Tables  []TableInfo `json:"tables"`
+}
+
+// TableInfo 描述归档中的一张表
+type TableInfo struct {
+	Name string `json:"name"`
+	// SchemaVersion 是备份时表的 schema 版本，恢复时记录从该版本升级到当前版本
+	SchemaVersion int    `json:"schema_version"`
+	Records       int    `json:"records"`
+	SHA256        string `json:"sha256"`
+}
+
+// Snapshot 把 store 的全部表写入 w。
+// 全部表在同一个事务中读取，得到的是同一时刻的一致快照。
+func Snapshot(ctx context.Context, store storage.StoreInterface, w io.Writer) (*Manifest, error) {
+	tables := make(map[string][]byte, len(storage.TableNames))
+	manifest := &Manifest{Format: FormatVersion, Created: time.Now().Unix()}
+
+	err := store.Tx(ctx, func(tx storage.Records) error {
+		for _, tableName := range storage.TableNames {
+			records, err := readTable(ctx, tx, tableName, true)
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(records)
+			if err != nil {
+				return fmt.Errorf("序列化表 %s 失败: %v", tableName, err)
+			}
+
+			tables[tableName] = data
+			manifest.Tables = append(manifest.Tables, TableInfo{
+				Name:          tableName,
+				SchemaVersion: storage.SchemaVersion(tableName),
+				Records:       len(records),
+				SHA256:        checksum(data),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeArchive(w, manifest, tables); err != nil {
+		return nil, fmt.Errorf("写入归档失败: %v", err)
+	}
+	return manifest, nil
+}
+
+// Restore 校验 r 中的归档，并用归档内容替换 store 的全部记录：
+// 归档中没有的记录被（软）删除，已有的记录被覆盖。替换在一个事务中完成，失败时 store 保持不变。
+func Restore(ctx context.Context, store storage.StoreInterface, r io.Reader) (*Manifest, error) {
+	manifest, tables, err := Read(r)
+	if err != nil {
+		return nil, err
+	}
+
+	err = store.Tx(ctx, func(tx storage.Records) error {
+		for _, info := range manifest.Tables {
+			if err := restoreTable(ctx, tx, info.Name, tables[info.Name]); err != nil {
+				return fmt.Errorf("恢复表 %s 失败: %w", info.Name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Read 读取并校验归档，返回清单和升级到当前 schema 版本的各表记录
+func Read(r io.Reader) (*Manifest, map[string]map[string]json.RawMessage, error) {
+	manifest, files, err := readArchive(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if manifest.Format < 1 || manifest.Format > FormatVersion {
+		return nil, nil, fmt.Errorf("不支持的归档格式版本 %d: %w", manifest.Format, ErrCorrupt)
+	}
+
+	tables := make(map[string]map[string]json.RawMessage, len(manifest.Tables))
+	for _, info := range manifest.Tables {
+		if _, ok := tableOps[info.Name]; !ok {
+			return nil, nil, fmt.Errorf("未知的表 %s: %w", info.Name, ErrCorrupt)
+		}
+		data, ok := files[tableFile(info.Name)]
+		if !ok {
+			return nil, nil, fmt.Errorf("缺少表 %s: %w", info.Name, ErrCorrupt)
+		}
+		if checksum(data) != info.SHA256 {
+			return nil, nil, fmt.Errorf("表 %s 的校验和不匹配: %w", info.Name, ErrCorrupt)
+		}
+
+		upgraded, err := storage.UpgradeRecords(info.Name, info.SchemaVersion, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		var records map[string]json.RawMessage
+		if err := json.Unmarshal(upgraded, &records); err != nil {
+			return nil, nil, fmt.Errorf("解析表 %s 失败: %v: %w", info.Name, err, ErrCorrupt)
+		}
+		if len(records) != info.Records {
+			return nil, nil, fmt.Errorf("表 %s 的记录数不匹配: %w", info.Name, ErrCorrupt)
+		}
+		tables[info.Name] = records
+	}
+	return manifest, tables, nil
+}
+
+// readTable 读取一张表的全部记录（ID → 记录），withDeleted 为 true 时包含墓碑
+func readTable(ctx context.Context, tx storage.Records, tableName string, withDeleted bool) (map[string]json.RawMessage, error) {
+	page, err := tx.List(ctx, tableName, storage.Query{WithDeleted: withDeleted})
+	if err != nil {
+		return nil, fmt.Errorf("读取表 %s 失败: %w", tableName, err)
+	}
+
+	records := make(map[string]json.RawMessage, len(page.Items))
+	for _, item := range page.Items {
+		var key struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(item, &key); err != nil {
+			return nil, fmt.Errorf("解析表 %s 的记录失败: %v", tableName, err)
+		}
+		records[key.ID] = item
+	}
+	return records, nil
+}
+
+// restoreTable 让表 tableName 的内容与 records 一致。records 中的墓碑按原样写入；
+// 表中已有的墓碑会被同一主键的记录覆盖，records 中没有的保持不变
+func restoreTable(ctx context.Context, tx storage.Records, tableName string, records map[string]json.RawMessage) error {
+	ops := tableOps[tableName]
+	existing, err := readTable(ctx, tx, tableName, false)
+	if err != nil {
+		return err
+	}
+
+	for id := range existing {
+		if _, ok := records[id]; !ok {
+			if err := ops.delete(ctx, tx, id); err != nil {
+				return err
+			}
+		}
+	}
+	for id, data := range records {
+		_, exists := existing[id]
+		if err := ops.put(ctx, tx, data, exists); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordOps 是一张表的通用操作，映射到 storage.Records 中该表的方法
+type recordOps struct {
+	put    func(ctx context.Context, tx storage.Records, data json.RawMessage, exists bool) error
+	delete func(ctx context.Context, tx storage.Records, id string) error
+	// canonical 把记录解码为记录类型后重新编码
+	canonical func(data json.RawMessage) ([]byte, error)
+}
+
+// upsert 创建记录，已存在时覆盖
+func (ops recordOps) upsert(ctx context.Context, tx storage.Records, data json.RawMessage) error {
+	err := ops.put(ctx, tx, data, false)
+	if errors.Is(err, storage.ErrAlreadyExists) {
+		err = ops.put(ctx, tx, data, true)
+	}
+	return err
+}
+
+// tableOps 按表名索引各表的写操作
+var tableOps = map[string]recordOps{
+	"users":    opsFor(storage.Records.Create, storage.Records.Update, storage.Records.Delete),
+	"orders":   opsFor(storage.Records.CreateOrder, storage.Records.UpdateOrder, storage.Records.DeleteOrder),
+	"products": opsFor(storage.Records.CreateProduct, storage.Records.UpdateProduct, storage.Records.DeleteProduct),
+	"comments": opsFor(storage.Records.CreateComment, storage.Records.UpdateComment, storage.Records.DeleteComment),
+}
+
+func opsFor[T any](
+	create, update func(storage.Records, context.Context, T) error,
+	del func(storage.Records, context.Context, string) error,
+) recordOps {
+	return recordOps{
+		put: func(ctx context.Context, tx storage.Records, data json.RawMessage, exists bool) error {
+			var v T
+			if err := json.Unmarshal(data, &v); err != nil {
+				return fmt.Errorf("解析记录失败: %v", err)
+			}
+			if exists {
+				return update(tx, ctx, v)
+			}
+			return create(tx, ctx, v)
+		},
+		delete: func(ctx context.Context, tx storage.Records, id string) error {
+			return del(tx, ctx, id)
+		},
+		canonical: func(data json.RawMessage) ([]byte, error) {
+			var v T
+			if err := json.Unmarshal(data, &v); err != nil {
+				return nil, fmt.Errorf("解析记录失败: %v", err)
+			}
+			return json.Marshal(v)
+		},
+	}
+}
+
+func tableFile(tableName string) string {
+	return path.Join("tables", tableName+".json")
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeArchive 写入清单和各表文件
+func writeArchive(w io.Writer, manifest *Manifest, tables map[string][]byte) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	modTime := time.Unix(manifest.Created, 0)
+	if err := writeFile(tw, manifestFile, data, modTime); err != nil {
+		return err
+	}
+	for _, info := range manifest.Tables {
+		if err := writeFile(tw, tableFile(info.Name), tables[info.Name], modTime); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: modTime}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// readArchive 读取归档中的清单和全部文件
+func readArchive(r io.Reader) (*Manifest, map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解压归档失败: %v: %w", err, ErrCorrupt)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取归档失败: %v: %w", err, ErrCorrupt)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取归档失败: %v: %w", err, ErrCorrupt)
+		}
+		files[hdr.Name] = data
+	}
+
+	data, ok := files[manifestFile]
+	if !ok {
+		return nil, nil, fmt.Errorf("缺少 %s: %w", manifestFile, ErrCorrupt)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("解析 %s 失败: %v: %w", manifestFile, err, ErrCorrupt)
+	}
+	return &manifest, files, nil
+}
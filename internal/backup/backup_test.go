@@ -0,0 +1,179 @@
+package backup
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/Axpz/store/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// backends 返回可以在本地运行的各类存储
+func backends() map[string]func(t *testing.T) storage.StoreInterface {
+	open := func(t *testing.T, cfg config.StorageConfig) storage.StoreInterface {
+		store, err := storage.New(&config.Config{Storage: cfg})
+		require.NoError(t, err)
+		t.Cleanup(func() { store.Close() })
+		return store
+	}
+	return map[string]func(t *testing.T) storage.StoreInterface{
+		"memory": func(t *testing.T) storage.StoreInterface {
+			return open(t, config.StorageConfig{Type: "memory"})
+		},
+		"local": func(t *testing.T) storage.StoreInterface {
+			return open(t, config.StorageConfig{Type: "local", Path: t.TempDir()})
+		},
+		"sqlite": func(t *testing.T) storage.StoreInterface {
+			return open(t, config.StorageConfig{Type: "sqlite", Path: filepath.Join(t.TempDir(), "store.db")})
+		},
+		"bolt": func(t *testing.T) storage.StoreInterface {
+			return open(t, config.StorageConfig{Type: "bolt", Path: filepath.Join(t.TempDir(), "store.db")})
+		},
+	}
+}
+
+func seed(t *testing.T, store storage.StoreInterface) {
+	verified := true
+	require.NoError(t, store.Create(t.Context(), storage.User{ID: "user-1", Email: "a@example.com", Verified: &verified}))
+	require.NoError(t, store.CreateOrder(t.Context(), storage.Order{ID: "o-1", UserID: "user-1", Status: "paid", Updated: 10}))
+	require.NoError(t, store.CreateOrder(t.Context(), storage.Order{ID: "o-2", UserID: "user-1", Status: "pending", Updated: 20}))
+	require.NoError(t, store.CreateProduct(t.Context(), storage.Product{ID: "p-1", Name: "Widget", Content: []string{"a", "b"}}))
+	require.NoError(t, store.CreateComment(t.Context(), storage.Comment{ID: "c-1"}))
+	// 软删除的商品作为墓碑一起备份和复制
+	require.NoError(t, store.CreateProduct(t.Context(), storage.Product{ID: "p-2", Name: "Retired"}))
+	require.NoError(t, store.DeleteProduct(t.Context(), "p-2"))
+}
+
+func TestSnapshotAndRestore(t *testing.T) {
+	for srcName, openSrc := range backends() {
+		t.Run(srcName, func(t *testing.T) {
+			src := openSrc(t)
+			seed(t, src)
+
+			var archive bytes.Buffer
+			manifest, err := Snapshot(t.Context(), src, &archive)
+			require.NoError(t, err)
+			assert.Equal(t, FormatVersion, manifest.Format)
+			require.Len(t, manifest.Tables, len(storage.TableNames))
+
+			for dstName, openDst := range backends() {
+				t.Run(dstName, func(t *testing.T) {
+					dst := openDst(t)
+					// 恢复会删除归档中没有的记录、覆盖已有的记录
+					require.NoError(t, dst.CreateOrder(t.Context(), storage.Order{ID: "o-stale", UserID: "user-1"}))
+					require.NoError(t, dst.CreateOrder(t.Context(), storage.Order{ID: "o-1", UserID: "user-2"}))
+
+					_, err := Restore(t.Context(), dst, bytes.NewReader(archive.Bytes()))
+					require.NoError(t, err)
+
+					_, err = dst.GetOrder(t.Context(), "o-stale")
+					assert.ErrorIs(t, err, storage.ErrNotFound)
+					orders, err := dst.GetOrdersByUserID(t.Context(), "user-1")
+					require.NoError(t, err)
+					require.Len(t, orders, 2)
+					assert.Equal(t, "o-2", orders[0].ID)
+					assert.Equal(t, "paid", orders[1].Status)
+
+					user, err := dst.Get(t.Context(), "user-1")
+					require.NoError(t, err)
+					assert.Equal(t, "a@example.com", user.Email)
+					product, err := dst.GetProduct(t.Context(), "p-1")
+					require.NoError(t, err)
+					assert.Equal(t, []string{"a", "b"}, product.Content)
+					_, err = dst.GetComment(t.Context(), "c-1")
+					assert.NoError(t, err)
+
+					_, err = dst.GetProduct(t.Context(), "p-2")
+					assert.ErrorIs(t, err, storage.ErrNotFound)
+					require.NoError(t, dst.Undelete(t.Context(), "products", "p-2"))
+				})
+			}
+		})
+	}
+}
+
+func TestRestore_RejectsCorruptArchive(t *testing.T) {
+	src := backends()["memory"](t)
+	seed(t, src)
+
+	var archive bytes.Buffer
+	_, err := Snapshot(t.Context(), src, &archive)
+	require.NoError(t, err)
+
+	// 篡改一张表：重新打包时保留原来的清单
+	manifest, files, err := readArchive(bytes.NewReader(archive.Bytes()))
+	require.NoError(t, err)
+	tables := make(map[string][]byte)
+	for _, info := range manifest.Tables {
+		tables[info.Name] = files[tableFile(info.Name)]
+	}
+	tables["orders"] = []byte(`{}`)
+	var tampered bytes.Buffer
+	require.NoError(t, writeArchive(&tampered, manifest, tables))
+
+	dst := backends()["memory"](t)
+	require.NoError(t, dst.CreateOrder(t.Context(), storage.Order{ID: "o-keep"}))
+
+	_, err = Restore(t.Context(), dst, &tampered)
+	assert.ErrorIs(t, err, ErrCorrupt)
+	_, err = Restore(t.Context(), dst, bytes.NewReader(archive.Bytes()[:archive.Len()/2]))
+	assert.ErrorIs(t, err, ErrCorrupt)
+
+	// 校验失败时存储不变
+	_, err = dst.GetOrder(t.Context(), "o-keep")
+	assert.NoError(t, err)
+}
+
+func TestScheduler_Retention(t *testing.T) {
+	store := backends()["memory"](t)
+	seed(t, store)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644))
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewScheduler(store, config.BackupConfig{Dir: dir, Keep: 3, MaxAge: 36 * time.Hour}, nil)
+	s.now = func() time.Time { return now }
+
+	var paths []string
+	for range 5 {
+		path, err := s.RunOnce(t.Context())
+		require.NoError(t, err)
+		paths = append(paths, path)
+		now = now.Add(time.Hour)
+	}
+
+	// 只保留最新的 3 个备份，其他文件不受影响
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{
+		filepath.Base(paths[2]), filepath.Base(paths[3]), filepath.Base(paths[4]), "notes.txt",
+	}, names)
+
+	// 超过 MaxAge 的备份被删除，最新的一个总是保留
+	now = now.Add(48 * time.Hour)
+	latest, err := s.RunOnce(t.Context())
+	require.NoError(t, err)
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	names = nil
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{filepath.Base(latest), "notes.txt"}, names)
+
+	f, err := os.Open(latest)
+	require.NoError(t, err)
+	defer f.Close()
+	_, _, err = Read(f)
+	assert.NoError(t, err)
+}
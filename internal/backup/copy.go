@@ -0,0 +1,220 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Axpz/store/internal/storage"
+)
+
+// defaultPageSize 是复制时每页读取的记录数
+const defaultPageSize = 500
+
+// ErrVerifyFailed 表示复制后源和目标的记录数或校验和不一致
+var ErrVerifyFailed = errors.New("copy verification failed")
+
+// CopyOptions 配置 Copy
+type CopyOptions struct {
+	// PageSize 每页读取并在一个目标事务中写入的记录数，默认 500
+	PageSize int
+	// Checkpoint 是进度文件的路径，为空表示不记录进度。
+	// 每写完一页更新一次；再次运行时从记录的位置继续，全部完成并校验通过后删除。
+	Checkpoint string
+	// OnPage 在每页写入后调用，用于输出进度，可选
+	OnPage func(table string, copied int)
+}
+
+// CopyReport 是一张表的复制和校验结果
+type CopyReport struct {
+	Table string
+	// Copied 是本次运行写入的记录数，不含之前运行已复制的部分
+	Copied int
+	// Source 和 Target 是两边的记录数和校验和
+	Source, Target TableDigest
+}
+
+// TableDigest 是一张表的记录数和与记录顺序无关的 SHA-256 校验和
+type TableDigest struct {
+	Records int
+	SHA256  string
+}
+
+// checkpoint 是 Copy 的进度文件
+type checkpoint struct {
+	Tables map[string]*tableProgress `json:"tables"`
+}
+
+type tableProgress struct {
+	// Cursor 是下一页的游标
+	Cursor string `json:"cursor"`
+	Copied int    `json:"copied"`
+	Done   bool   `json:"done"`
+}
+
+// Copy 把 src 的全部记录逐页复制到 dst，再校验两边每张表的记录数和校验和。
+//
+// 每条记录在目标中不存在时创建、存在时覆盖，因此重复运行是幂等的；
+// 目标中多出的记录不会被删除，会导致校验失败。校验失败时返回 ErrVerifyFailed。
+func Copy(ctx context.Context, src, dst storage.StoreInterface, opts CopyOptions) ([]CopyReport, error) {
+	if opts.PageSize <= 0 {
+		opts.PageSize = defaultPageSize
+	}
+
+	cp, err := loadCheckpoint(opts.Checkpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]CopyReport, 0, len(storage.TableNames))
+	for _, tableName := range storage.TableNames {
+		progress := cp.Tables[tableName]
+		if progress == nil {
+			progress = &tableProgress{}
+			cp.Tables[tableName] = progress
+		}
+
+		report := CopyReport{Table: tableName}
+		for !progress.Done {
+			page, err := src.List(ctx, tableName, storage.Query{Limit: opts.PageSize, Cursor: progress.Cursor, WithDeleted: true})
+			if err != nil {
+				return nil, fmt.Errorf("读取表 %s 失败: %w", tableName, err)
+			}
+
+			err = dst.Tx(ctx, func(tx storage.Records) error {
+				for _, item := range page.Items {
+					if err := tableOps[tableName].upsert(ctx, tx, item); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("写入表 %s 失败: %w", tableName, err)
+			}
+
+			report.Copied += len(page.Items)
+			progress.Copied += len(page.Items)
+			progress.Cursor, progress.Done = page.Next, page.Next == ""
+			if err := cp.save(opts.Checkpoint); err != nil {
+				return nil, err
+			}
+			if opts.OnPage != nil {
+				opts.OnPage(tableName, progress.Copied)
+			}
+		}
+
+		if report.Source, err = digest(ctx, src, tableName, opts.PageSize); err != nil {
+			return nil, err
+		}
+		if report.Target, err = digest(ctx, dst, tableName, opts.PageSize); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+
+	var mismatched []string
+	for _, r := range reports {
+		if r.Source != r.Target {
+			mismatched = append(mismatched, r.Table)
+		}
+	}
+	if len(mismatched) > 0 {
+		return reports, fmt.Errorf("表 %v 的记录数或校验和不一致: %w", mismatched, ErrVerifyFailed)
+	}
+
+	if opts.Checkpoint != "" {
+		if err := os.Remove(opts.Checkpoint); err != nil && !os.IsNotExist(err) {
+			return reports, fmt.Errorf("删除进度文件失败: %v", err)
+		}
+	}
+	return reports, nil
+}
+
+// digest 逐页读取表 tableName，计算记录数和校验和。
+// 每条记录先解码为记录类型再重新编码，不同后端对同一记录的 JSON 表示得到相同的结果；
+// 校验和是每条记录 SHA-256 的和（模 2^256），与 List 返回的顺序无关，
+// 不受不同数据库对 ID 排序规则（如 PostgreSQL 的 collation）的影响。
+func digest(ctx context.Context, store storage.StoreInterface, tableName string, pageSize int) (TableDigest, error) {
+	var (
+		d   TableDigest
+		sum [sha256.Size]byte
+	)
+	q := storage.Query{Limit: pageSize, WithDeleted: true}
+	for {
+		page, err := store.List(ctx, tableName, q)
+		if err != nil {
+			return TableDigest{}, fmt.Errorf("读取表 %s 失败: %w", tableName, err)
+		}
+		for _, item := range page.Items {
+			canonical, err := tableOps[tableName].canonical(item)
+			if err != nil {
+				return TableDigest{}, err
+			}
+			addSum(&sum, sha256.Sum256(canonical))
+			d.Records++
+		}
+		if page.Next == "" {
+			break
+		}
+		q.Cursor = page.Next
+	}
+	d.SHA256 = hex.EncodeToString(sum[:])
+	return d, nil
+}
+
+// addSum 把 h 作为大端序整数加到 sum 上，溢出的进位丢弃
+func addSum(sum *[sha256.Size]byte, h [sha256.Size]byte) {
+	var carry uint16
+	for i := len(sum) - 1; i >= 0; i-- {
+		v := uint16(sum[i]) + uint16(h[i]) + carry
+		sum[i], carry = byte(v), v>>8
+	}
+}
+
+// loadCheckpoint 读取进度文件，文件不存在或 path 为空时从头开始
+func loadCheckpoint(path string) (*checkpoint, error) {
+	cp := &checkpoint{Tables: make(map[string]*tableProgress)}
+	if path == "" {
+		return cp, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取进度文件失败: %v", err)
+	}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("解析进度文件失败: %v", err)
+	}
+	if cp.Tables == nil {
+		cp.Tables = make(map[string]*tableProgress)
+	}
+	return cp, nil
+}
+
+// save 原子地写入进度文件
+func (cp *checkpoint) save(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入进度文件失败: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("写入进度文件失败: %v", err)
+	}
+	return nil
+}
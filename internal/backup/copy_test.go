@@ -0,0 +1,124 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Axpz/store/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopy(t *testing.T) {
+	for srcName, openSrc := range backends() {
+		for dstName, openDst := range backends() {
+			t.Run(srcName+"_to_"+dstName, func(t *testing.T) {
+				src, dst := openSrc(t), openDst(t)
+				seed(t, src)
+
+				reports, err := Copy(t.Context(), src, dst, CopyOptions{PageSize: 1})
+				require.NoError(t, err)
+				require.Len(t, reports, len(storage.TableNames))
+				for _, r := range reports {
+					assert.Equal(t, r.Source, r.Target, r.Table)
+				}
+
+				orders, err := dst.GetOrdersByUserID(t.Context(), "user-1")
+				require.NoError(t, err)
+				assert.Len(t, orders, 2)
+
+				// 重复运行是幂等的
+				_, err = Copy(t.Context(), src, dst, CopyOptions{})
+				require.NoError(t, err)
+			})
+		}
+	}
+}
+
+// failingStore 在第 failAt 个事务时返回错误，模拟复制中断
+type failingStore struct {
+	storage.StoreInterface
+	txs    int
+	failAt int
+}
+
+var errInterrupted = errors.New("interrupted")
+
+func (s *failingStore) Tx(ctx context.Context, fn func(tx storage.Records) error) error {
+	s.txs++
+	if s.txs == s.failAt {
+		return errInterrupted
+	}
+	return s.StoreInterface.Tx(ctx, fn)
+}
+
+func TestCopy_Resume(t *testing.T) {
+	src := backends()["memory"](t)
+	for i := range 10 {
+		require.NoError(t, src.CreateOrder(t.Context(), storage.Order{ID: fmt.Sprintf("o-%02d", i), UserID: "user-1"}))
+	}
+	checkpoint := filepath.Join(t.TempDir(), "copy.json")
+
+	dst := &failingStore{StoreInterface: backends()["local"](t), failAt: 4}
+	// users 表为空占一个事务，orders 表写完两页后中断
+	_, err := Copy(t.Context(), src, dst, CopyOptions{PageSize: 3, Checkpoint: checkpoint})
+	assert.ErrorIs(t, err, errInterrupted)
+	assert.FileExists(t, checkpoint)
+
+	reports, err := Copy(t.Context(), src, dst, CopyOptions{PageSize: 3, Checkpoint: checkpoint})
+	require.NoError(t, err)
+	assert.Equal(t, "orders", reports[1].Table)
+	assert.Equal(t, 4, reports[1].Copied, "从第三页继续")
+	assert.Equal(t, 10, reports[1].Target.Records)
+	assert.Equal(t, reports[1].Source, reports[1].Target)
+
+	_, err = os.Stat(checkpoint)
+	assert.True(t, os.IsNotExist(err), "完成后删除进度文件")
+}
+
+func TestCopy_VerifyFailsOnExtraRecords(t *testing.T) {
+	src, dst := backends()["memory"](t), backends()["bolt"](t)
+	seed(t, src)
+	require.NoError(t, dst.CreateComment(t.Context(), storage.Comment{ID: "c-extra"}))
+
+	reports, err := Copy(t.Context(), src, dst, CopyOptions{})
+	assert.ErrorIs(t, err, ErrVerifyFailed)
+	require.Len(t, reports, len(storage.TableNames))
+	assert.Equal(t, 1, reports[3].Source.Records)
+	assert.Equal(t, 2, reports[3].Target.Records)
+}
+
+// reversedStore 倒序返回每页记录，模拟按不同排序规则返回 ID 的后端
+type reversedStore struct {
+	storage.StoreInterface
+}
+
+func (s reversedStore) List(ctx context.Context, tableName string, q storage.Query) (storage.Page, error) {
+	page, err := s.StoreInterface.List(ctx, tableName, q)
+	slices.Reverse(page.Items)
+	return page, err
+}
+
+func TestDigest_OrderIndependent(t *testing.T) {
+	store := backends()["memory"](t)
+	for _, id := range []string{"C-1", "a-1", "b_1", "a_1", "B-1"} {
+		require.NoError(t, store.CreateComment(t.Context(), storage.Comment{ID: id}))
+	}
+
+	want, err := digest(t.Context(), store, "comments", 0)
+	require.NoError(t, err)
+	got, err := digest(t.Context(), reversedStore{store}, "comments", 0)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+	assert.Equal(t, 5, got.Records)
+
+	require.NoError(t, store.UpdateComment(t.Context(), storage.Comment{ID: "a-1", Content: "edited"}))
+	changed, err := digest(t.Context(), store, "comments", 0)
+	require.NoError(t, err)
+	assert.NotEqual(t, want.SHA256, changed.SHA256)
+}
@@ -0,0 +1,171 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/Axpz/store/internal/storage"
+	"go.uber.org/zap"
+)
+
+// defaultInterval 是定时备份的默认间隔
+const defaultInterval = 24 * time.Hour
+
+// 备份文件名为 backup-<UTC 时间>.tar.gz，按文件名排序即按时间排序
+const (
+	filePrefix = "backup-"
+	fileSuffix = ".tar.gz"
+	timeLayout = "20060102T150405Z"
+)
+
+// Scheduler 定期把存储快照到备份目录，并按保留规则删除旧备份
+type Scheduler struct {
+	store  storage.StoreInterface
+	cfg    config.BackupConfig
+	logger *zap.Logger
+	// now 返回当前时间，测试中可替换
+	now func() time.Time
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// NewScheduler 创建定时备份，调用 Start 后开始运行
+func NewScheduler(store storage.StoreInterface, cfg config.BackupConfig, logger *zap.Logger) *Scheduler {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Scheduler{store: store, cfg: cfg, logger: logger, now: time.Now}
+}
+
+// Start 在后台按间隔执行备份
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stop, s.done = cancel, make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				path, err := s.RunOnce(ctx)
+				if err != nil {
+					s.logger.Error("定时备份失败", zap.Error(err))
+					continue
+				}
+				s.logger.Info("定时备份完成", zap.String("path", path))
+			}
+		}
+	}()
+}
+
+// Stop 停止定时备份，等待正在进行的备份结束
+func (s *Scheduler) Stop() {
+	if s.stop == nil {
+		return
+	}
+	s.stop()
+	<-s.done
+}
+
+// RunOnce 立即备份一次，再按保留规则删除旧备份，返回新备份的路径
+func (s *Scheduler) RunOnce(ctx context.Context) (string, error) {
+	if err := os.MkdirAll(s.cfg.Dir, 0755); err != nil {
+		return "", fmt.Errorf("创建备份目录失败: %v", err)
+	}
+
+	name := filePrefix + s.now().UTC().Format(timeLayout) + fileSuffix
+	path := filepath.Join(s.cfg.Dir, name)
+	if err := WriteFile(ctx, s.store, path); err != nil {
+		return "", err
+	}
+
+	if err := s.prune(); err != nil {
+		return path, fmt.Errorf("清理旧备份失败: %v", err)
+	}
+	return path, nil
+}
+
+// prune 删除超出 Keep 个数或早于 MaxAge 的备份，最新的一个备份总是保留
+func (s *Scheduler) prune() error {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return err
+	}
+
+	type backupFile struct {
+		name    string
+		created time.Time
+	}
+	var files []backupFile
+	for _, entry := range entries {
+		stamp, ok := strings.CutPrefix(entry.Name(), filePrefix)
+		if !ok {
+			continue
+		}
+		stamp, ok = strings.CutSuffix(stamp, fileSuffix)
+		if !ok {
+			continue
+		}
+		created, err := time.Parse(timeLayout, stamp)
+		if err != nil {
+			continue
+		}
+		files = append(files, backupFile{name: entry.Name(), created: created})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].created.After(files[j].created) })
+
+	now := s.now()
+	for i, f := range files {
+		if i == 0 {
+			continue
+		}
+		expired := s.cfg.MaxAge > 0 && now.Sub(f.created) > s.cfg.MaxAge
+		if (s.cfg.Keep > 0 && i >= s.cfg.Keep) || expired {
+			if err := os.Remove(filepath.Join(s.cfg.Dir, f.name)); err != nil {
+				return err
+			}
+			s.logger.Info("删除旧备份", zap.String("name", f.name))
+		}
+	}
+	return nil
+}
+
+// WriteFile 把 store 的快照写入 path。先写临时文件再重命名，写到一半失败不会留下不完整的备份。
+func WriteFile(ctx context.Context, store storage.StoreInterface, path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("创建备份文件失败: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := Snapshot(ctx, store, tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入备份文件失败: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入备份文件失败: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("写入备份文件失败: %v", err)
+	}
+	return nil
+}
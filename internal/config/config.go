@@ -18,6 +18,8 @@ type Config struct {
 	JWT     JWTConfig     `yaml:"jwt"`
 	Email   EmailConfig   `yaml:"email"`
 	PayPal  PayPalConfig  `yaml:"paypal"`
+	Backup  BackupConfig  `yaml:"backup"`
+	Admin   AdminConfig   `yaml:"admin"`
 
 	Logger *zap.Logger
 }
@@ -26,6 +28,10 @@ type Config struct {
 type GitHubConfig struct {
 	Repo  RepoConfig `yaml:"repo"`
 	Token string
+	// RefreshInterval 轮询数据仓库变更的间隔，0 表示不轮询
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+	// FlushInterval 写回队列的合并窗口，默认 10 秒
+	FlushInterval time.Duration `yaml:"flush_interval"`
 }
 
 // RepoConfig 表示仓库相关配置
@@ -43,6 +49,11 @@ type TablesConfig struct {
 	Comments string `yaml:"comments"`
 	Orders   string `yaml:"orders"`
 	Products string `yaml:"products"`
+	// Layout 表在数据仓库中的布局：file（默认）每张表一个文件，使用上面的文件名；
+	// records 每条记录一个文件，位于 <path>/<表名>/<ID>.json
+	Layout string `yaml:"layout"`
+	// ShardPrefix records 布局下按记录 ID 的 SHA-1 前缀分目录的字符数，0 表示不分目录
+	ShardPrefix int `yaml:"shard_prefix"`
 }
 
 // ServerConfig
@@ -55,6 +66,69 @@ type ServerConfig struct {
 type StorageConfig struct {
 	Type string `yaml:"type"`
 	Path string `yaml:"path"`
+	// CompactEvery local 存储每追加多少条日志压缩一次，默认 100
+	CompactEvery int `yaml:"compact_every"`
+	// Fixture memory 存储启动时加载的种子数据文件（JSON 或 YAML），可选
+	Fixture string `yaml:"fixture"`
+	// DSN postgres 存储的连接串，可由环境变量 STORE_POSTGRES_DSN 覆盖
+	DSN string `yaml:"dsn"`
+	// MaxOpenConns 数据库连接池的最大连接数，0 表示使用默认值
+	MaxOpenConns int `yaml:"max_open_conns"`
+	// MaxIdleConns 数据库连接池保留的空闲连接数，0 表示使用默认值
+	MaxIdleConns int `yaml:"max_idle_conns"`
+	// ConnMaxLifetime 单个连接的最长使用时间，0 表示使用默认值
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	// Timeout 单次存储读写的超时，0 表示使用各后端的默认值（github 30 秒，local/sqlite/postgres/bolt 5 秒，memory 不限制）
+	Timeout time.Duration `yaml:"timeout"`
+	// Encryption local 和 github 存储的表文件加密，不配置密钥时不加密
+	Encryption EncryptionConfig `yaml:"encryption"`
+	// Compression local 和 github 存储的表文件压缩：none（默认）或 gzip，先压缩再加密
+	Compression string `yaml:"compression"`
+	// Tombstones 软删除记录的定时清理
+	Tombstones TombstoneConfig `yaml:"tombstones"`
+	// TTL 过期记录的后台清扫
+	TTL TTLConfig `yaml:"ttl"`
+}
+
+// TTLConfig 过期记录清扫配置。记录到期后立即不可见，清扫只负责把它彻底删除
+type TTLConfig struct {
+	// SweepInterval 清扫间隔，默认 1 分钟
+	SweepInterval time.Duration `yaml:"sweep_interval"`
+}
+
+// TombstoneConfig 墓碑（软删除的记录）清理配置
+type TombstoneConfig struct {
+	// Retention 墓碑保留时间，超过后被彻底删除；0 表示不清理
+	Retention time.Duration `yaml:"retention"`
+	// Interval 检查间隔，默认 1 小时
+	Interval time.Duration `yaml:"interval"`
+}
+
+// EncryptionConfig 表文件加密配置（AES-256-GCM）。
+// 每个密钥的格式为 "<密钥 ID>:<base64 编码的 32 字节密钥>"，第一个为当前密钥，其余只用于解密旧数据。
+type EncryptionConfig struct {
+	// KeyFile 密钥文件，每行一个密钥，# 开头的行为注释
+	KeyFile string `yaml:"key_file"`
+	// Keys 逗号分隔的密钥，来自环境变量 STORE_ENCRYPTION_KEYS，设置时忽略 KeyFile
+	Keys string `yaml:"-"`
+}
+
+// BackupConfig 定时备份配置
+type BackupConfig struct {
+	// Dir 备份文件目录，为空表示不做定时备份
+	Dir string `yaml:"dir"`
+	// Interval 备份间隔，默认 24 小时
+	Interval time.Duration `yaml:"interval"`
+	// Keep 最多保留的备份个数，0 表示不限制
+	Keep int `yaml:"keep"`
+	// MaxAge 备份的最长保留时间，0 表示不限制；最新的一个备份总是保留
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+// AdminConfig 管理接口配置
+type AdminConfig struct {
+	// Users 可以访问 /api/admin 的用户 ID，为空表示没有管理员
+	Users []string `yaml:"users"`
 }
 
 // JWTConfig
@@ -89,6 +163,10 @@ func Load(configPath string) *Config {
 	// Load sensitive values from environment variables
 	config.Email.Password = os.Getenv(config.Email.PasswordEnv)
 	config.GitHub.Token = os.Getenv("GITHUB_API_TOKEN")
+	if dsn := os.Getenv("STORE_POSTGRES_DSN"); dsn != "" {
+		config.Storage.DSN = dsn
+	}
+	config.Storage.Encryption.Keys = os.Getenv("STORE_ENCRYPTION_KEYS")
 
 	// Validate the full configuration and exit on fatal errors
 	config.validate()
@@ -120,8 +198,28 @@ func (c *Config) validateStorageConfig() {
 		if c.Storage.Path == "" {
 			log.Fatal("fatal: storage.path is required when storage.type is 'local'")
 		}
+	case "memory":
+		// storage.fixture is optional
+	case "sqlite":
+		if c.Storage.Path == "" {
+			log.Fatal("fatal: storage.path is required when storage.type is 'sqlite'")
+		}
+	case "bolt":
+		if c.Storage.Path == "" {
+			log.Fatal("fatal: storage.path is required when storage.type is 'bolt'")
+		}
+	case "postgres":
+		if c.Storage.DSN == "" {
+			log.Fatal("fatal: storage.dsn (or STORE_POSTGRES_DSN) is required when storage.type is 'postgres'")
+		}
+	default:
+		log.Fatalf("fatal: invalid storage.type: %s (expected 'github', 'local', 'memory', 'sqlite', 'bolt' or 'postgres')", c.Storage.Type)
+	}
+
+	switch c.Storage.Compression {
+	case "", "none", "gzip":
 	default:
-		log.Fatalf("fatal: invalid storage.type: %s (expected 'github' or 'local')", c.Storage.Type)
+		log.Fatalf("fatal: invalid storage.compression: %s (expected 'none' or 'gzip')", c.Storage.Compression)
 	}
 }
 
@@ -146,6 +244,20 @@ func (c *Config) validateGitHubConfig() {
 	if c.GitHub.Repo.Tables.Path == "" {
 		log.Fatal("fatal: github.repo.tables.path is required")
 	}
+	switch c.GitHub.Repo.Tables.Layout {
+	case "", "file":
+	case "records":
+		if n := c.GitHub.Repo.Tables.ShardPrefix; n < 0 || n > 8 {
+			log.Fatalf("fatal: github.repo.tables.shard_prefix must be between 0 and 8, got %d", n)
+		}
+		if c.Storage.Compression == "gzip" {
+			log.Fatal("fatal: storage.compression is not supported with github.repo.tables.layout 'records'")
+		}
+		// 每条记录一个文件时不使用表文件名
+		return
+	default:
+		log.Fatalf("fatal: invalid github.repo.tables.layout: %s (expected 'file' or 'records')", c.GitHub.Repo.Tables.Layout)
+	}
 	if c.GitHub.Repo.Tables.Users == "" {
 		log.Fatal("fatal: github.repo.tables.users is required")
 	}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/Axpz/store/internal/pkg/jwt"
+	"github.com/Axpz/store/internal/types"
 	"github.com/gin-gonic/gin"
 )
 
@@ -27,10 +28,7 @@ func Auth(jwtSecret string) gin.HandlerFunc {
 		if token != "" {
 			// 检查token格式
 			if !strings.HasPrefix(token, TokenPrefix) {
-				c.JSON(http.StatusUnauthorized, gin.H{
-					"error": "访问令牌格式错误",
-				})
-				c.Abort()
+				abortWithError(c, http.StatusUnauthorized, types.CodeUnauthenticated, "访问令牌格式错误")
 				return
 			}
 
@@ -40,10 +38,7 @@ func Auth(jwtSecret string) gin.HandlerFunc {
 			// 检查cookie
 			cookieToken, err := c.Cookie("token")
 			if err != nil || cookieToken == "" {
-				c.JSON(http.StatusUnauthorized, gin.H{
-					"error": "未提供访问令牌",
-				})
-				c.Abort()
+				abortWithError(c, http.StatusUnauthorized, types.CodeUnauthenticated, "未提供访问令牌")
 				return
 			}
 			token = cookieToken
@@ -52,14 +47,11 @@ func Auth(jwtSecret string) gin.HandlerFunc {
 		// 验证token
 		claims, err := jwt.ValidateToken(token, jwtSecret)
 		if err != nil {
-			status := http.StatusUnauthorized
+			status, code := http.StatusUnauthorized, types.CodeUnauthenticated
 			if err == jwt.ErrExpiredToken {
-				status = http.StatusForbidden
+				status, code = http.StatusForbidden, types.CodeForbidden
 			}
-			c.JSON(status, gin.H{
-				"error": err.Error(),
-			})
-			c.Abort()
+			abortWithError(c, status, code, err.Error())
 			return
 		}
 
@@ -70,3 +62,25 @@ func Auth(jwtSecret string) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RequireAdmin 只允许 userIDs 中的用户继续访问，必须放在 Auth 之后
+func RequireAdmin(userIDs []string) gin.HandlerFunc {
+	admins := make(map[string]struct{}, len(userIDs))
+	for _, id := range userIDs {
+		admins[id] = struct{}{}
+	}
+
+	return func(c *gin.Context) {
+		if _, ok := admins[c.GetString(UserIDKey)]; !ok {
+			abortWithError(c, http.StatusForbidden, types.CodeForbidden, "需要管理员权限")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// abortWithError 以与 API 处理器相同的错误响应格式终止请求
+func abortWithError(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, types.ErrorResponse{Code: code, Error: message})
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Axpz/store/internal/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, tt := range []struct {
+		userID string
+		status int
+	}{
+		{"admin-1", http.StatusOK},
+		{"user-1", http.StatusForbidden},
+	} {
+		router := gin.New()
+		router.GET("/admin", func(c *gin.Context) {
+			c.Set(UserIDKey, tt.userID)
+		}, RequireAdmin([]string{"admin-1"}), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+		require.Equal(t, tt.status, w.Code, tt.userID)
+		if tt.status == http.StatusOK {
+			continue
+		}
+
+		var resp types.ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, types.CodeForbidden, resp.Code)
+		assert.NotEmpty(t, resp.Error)
+	}
+}
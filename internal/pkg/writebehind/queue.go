@@ -0,0 +1,290 @@
+package writebehind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FlushFunc 将某个 key（通常是表名）的最新状态落盘
+type FlushFunc func(ctx context.Context, key string) error
+
+// BatchFunc 将一组 key 的最新状态一次落盘，失败时整组保持脏状态
+type BatchFunc func(ctx context.Context, keys []string) error
+
+// Options 配置写回队列
+type Options struct {
+	// Interval 合并窗口：同一 key 在窗口内的多次写入只落盘一次
+	Interval time.Duration
+	// MaxBackoff 失败重试的最大间隔，默认 5 分钟
+	MaxBackoff time.Duration
+	// OnError 在后台落盘失败时调用，用于日志与告警；批量落盘时 key 为逗号分隔的整组 key
+	OnError func(key string, err error)
+}
+
+// Written 包装数据已经落盘、但仍需告知调用方的错误（例如合并时丢弃了冲突的修改）。
+// 队列把它当作成功的落盘：不重新标记、不计入失败、不退避，也不调用 OnError；
+// 同步的 Flush 和 Close 仍然返回该错误。err 为 nil 时返回 nil
+func Written(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &writtenError{err: err}
+}
+
+type writtenError struct {
+	err error
+}
+
+func (e *writtenError) Error() string { return e.err.Error() }
+
+func (e *writtenError) Unwrap() error { return e.err }
+
+// failed 判断落盘是否失败，Written 包装的错误不算失败
+func failed(err error) bool {
+	var written *writtenError
+	return err != nil && !errors.As(err, &written)
+}
+
+// Stats 是队列的运行指标
+type Stats struct {
+	Requests  uint64   // MarkDirty 调用次数
+	Flushes   uint64   // 成功落盘次数，批量落盘时按 key 计
+	Failures  uint64   // 落盘失败次数，批量落盘时按 key 计
+	Pending   []string // 尚未落盘的 key
+	LastError error    // 最近一次失败的错误
+}
+
+// Queue 是按 key 维护脏标记的写回队列。
+// 每个 key 有独立的脏标记，互不覆盖；后台按 Interval 合并落盘，
+// 失败的 key 保持脏状态并按指数退避重试；Flush 和 Close 同步落盘全部脏数据。
+type Queue struct {
+	flush FlushFunc
+	// batch 非空时每一轮的全部脏 key 通过一次调用落盘
+	batch BatchFunc
+	opts  Options
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	stats   Stats
+
+	// sem 保证同一时刻只有一轮落盘在执行
+	sem  chan struct{}
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+type entry struct {
+	dirty    bool
+	failures int
+	retryAt  time.Time
+}
+
+// New 创建写回队列并启动后台落盘，每个 key 单独落盘
+func New(flush FlushFunc, opts Options) *Queue {
+	return newQueue(flush, nil, opts)
+}
+
+// NewBatch 创建批量落盘的写回队列：每一轮到期的全部脏 key 通过一次 flush 调用落盘，
+// 适用于一次写入多个 key 与写入一个 key 代价相同的后端
+func NewBatch(flush BatchFunc, opts Options) *Queue {
+	return newQueue(nil, flush, opts)
+}
+
+func newQueue(flush FlushFunc, batch BatchFunc, opts Options) *Queue {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 5 * time.Minute
+	}
+
+	q := &Queue{
+		flush:   flush,
+		batch:   batch,
+		opts:    opts,
+		entries: make(map[string]*entry),
+		sem:     make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// MarkDirty 标记 key 需要落盘
+func (q *Queue) MarkDirty(key string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e, ok := q.entries[key]
+	if !ok {
+		e = &entry{}
+		q.entries[key] = e
+	}
+	e.dirty = true
+	q.stats.Requests++
+}
+
+// Flush 立即落盘所有脏 key（忽略重试退避），返回各 key 的错误
+func (q *Queue) Flush(ctx context.Context) error {
+	return q.flushWhere(ctx, func(*entry, time.Time) bool { return true })
+}
+
+// Close 停止后台落盘，并同步落盘剩余的脏数据
+func (q *Queue) Close(ctx context.Context) error {
+	q.once.Do(func() {
+		close(q.stop)
+		<-q.done
+	})
+	return q.Flush(ctx)
+}
+
+// Stats 返回队列指标的快照
+func (q *Queue) Stats() Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := q.stats
+	stats.Pending = nil
+	for key, e := range q.entries {
+		if e.dirty {
+			stats.Pending = append(stats.Pending, key)
+		}
+	}
+	sort.Strings(stats.Pending)
+	return stats
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+
+	ticker := time.NewTicker(q.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+			// 错误已经通过 OnError 上报，失败的 key 等待退避后重试
+			q.flushWhere(context.Background(), func(e *entry, now time.Time) bool {
+				return !now.Before(e.retryAt)
+			})
+		}
+	}
+}
+
+// flushWhere 落盘所有满足条件的脏 key
+func (q *Queue) flushWhere(ctx context.Context, due func(*entry, time.Time) bool) error {
+	select {
+	case q.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-q.sem }()
+
+	q.mu.Lock()
+	now := time.Now()
+	var keys []string
+	for key, e := range q.entries {
+		if e.dirty && due(e, now) {
+			keys = append(keys, key)
+		}
+	}
+	q.mu.Unlock()
+	sort.Strings(keys)
+
+	if q.batch != nil {
+		return q.flushBatch(ctx, keys)
+	}
+
+	var errs []error
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if err := q.flushKey(ctx, key); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (q *Queue) flushKey(ctx context.Context, key string) error {
+	// 先清除脏标记，落盘期间的新写入会重新标记
+	q.mu.Lock()
+	e := q.entries[key]
+	e.dirty = false
+	q.mu.Unlock()
+
+	err := q.flush(ctx, key)
+
+	q.mu.Lock()
+	q.settle(e, err)
+	q.mu.Unlock()
+
+	if failed(err) && q.opts.OnError != nil {
+		q.opts.OnError(key, err)
+	}
+	return err
+}
+
+// flushBatch 通过一次调用落盘 keys，失败时整组重新标记并退避
+func (q *Queue) flushBatch(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	q.mu.Lock()
+	for _, key := range keys {
+		q.entries[key].dirty = false
+	}
+	q.mu.Unlock()
+
+	err := q.batch(ctx, keys)
+
+	q.mu.Lock()
+	for _, key := range keys {
+		q.settle(q.entries[key], err)
+	}
+	q.mu.Unlock()
+
+	if err == nil {
+		return nil
+	}
+	if failed(err) && q.opts.OnError != nil {
+		q.opts.OnError(strings.Join(keys, ","), err)
+	}
+	return fmt.Errorf("%s: %w", strings.Join(keys, ", "), err)
+}
+
+// settle 记录一个 key 的落盘结果，调用方持有锁
+func (q *Queue) settle(e *entry, err error) {
+	if failed(err) {
+		e.dirty = true
+		e.failures++
+		e.retryAt = time.Now().Add(q.backoff(e.failures))
+		q.stats.Failures++
+		q.stats.LastError = err
+	} else {
+		e.failures = 0
+		e.retryAt = time.Time{}
+		q.stats.Flushes++
+	}
+}
+
+// backoff 返回第 failures 次失败后的重试间隔
+func (q *Queue) backoff(failures int) time.Duration {
+	d := q.opts.Interval
+	for i := 1; i < failures && d < q.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, q.opts.MaxBackoff)
+}
@@ -0,0 +1,167 @@
+package writebehind
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	mu      sync.Mutex
+	flushed map[string]int
+	fail    map[string]error
+}
+
+func (r *recorder) flush(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.fail[key]; err != nil {
+		return err
+	}
+	r.flushed[key]++
+	return nil
+}
+
+func TestQueue_CoalescesPerKey(t *testing.T) {
+	r := &recorder{flushed: make(map[string]int)}
+	q := New(r.flush, Options{Interval: time.Hour})
+
+	q.MarkDirty("users")
+	q.MarkDirty("users")
+	q.MarkDirty("products")
+
+	require.NoError(t, q.Flush(context.Background()))
+	assert.Equal(t, map[string]int{"users": 1, "products": 1}, r.flushed)
+
+	// 没有新的写入时不会重复落盘
+	require.NoError(t, q.Close(context.Background()))
+	assert.Equal(t, map[string]int{"users": 1, "products": 1}, r.flushed)
+
+	stats := q.Stats()
+	assert.Equal(t, uint64(3), stats.Requests)
+	assert.Equal(t, uint64(2), stats.Flushes)
+	assert.Empty(t, stats.Pending)
+}
+
+func TestQueue_RetriesFailures(t *testing.T) {
+	boom := errors.New("boom")
+	r := &recorder{flushed: make(map[string]int), fail: map[string]error{"orders": boom}}
+
+	var reported []string
+	q := New(r.flush, Options{
+		Interval: time.Hour,
+		OnError:  func(key string, err error) { reported = append(reported, key) },
+	})
+	defer q.Close(context.Background())
+
+	q.MarkDirty("orders")
+	q.MarkDirty("users")
+
+	err := q.Flush(context.Background())
+	assert.ErrorIs(t, err, boom)
+	assert.Equal(t, []string{"orders"}, reported)
+	assert.Equal(t, map[string]int{"users": 1}, r.flushed)
+
+	stats := q.Stats()
+	assert.Equal(t, []string{"orders"}, stats.Pending)
+	assert.Equal(t, uint64(1), stats.Failures)
+	assert.ErrorIs(t, stats.LastError, boom)
+
+	// 失败的 key 保持脏状态，恢复后再次落盘
+	r.mu.Lock()
+	r.fail = nil
+	r.mu.Unlock()
+
+	require.NoError(t, q.Flush(context.Background()))
+	assert.Equal(t, map[string]int{"users": 1, "orders": 1}, r.flushed)
+	assert.Empty(t, q.Stats().Pending)
+}
+
+func TestQueue_Batch(t *testing.T) {
+	boom := errors.New("boom")
+	var (
+		mu      sync.Mutex
+		batches [][]string
+		fail    error
+	)
+	q := NewBatch(func(ctx context.Context, keys []string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail != nil {
+			return fail
+		}
+		batches = append(batches, keys)
+		return nil
+	}, Options{Interval: time.Hour})
+	defer q.Close(context.Background())
+
+	q.MarkDirty("users")
+	q.MarkDirty("orders")
+	q.MarkDirty("users")
+	require.NoError(t, q.Flush(context.Background()))
+	assert.Equal(t, [][]string{{"orders", "users"}}, batches)
+
+	// 没有脏 key 时不调用落盘函数
+	require.NoError(t, q.Flush(context.Background()))
+	assert.Len(t, batches, 1)
+
+	// 失败时整组保持脏状态
+	mu.Lock()
+	fail = boom
+	mu.Unlock()
+	q.MarkDirty("orders")
+	q.MarkDirty("products")
+	assert.ErrorIs(t, q.Flush(context.Background()), boom)
+	assert.Equal(t, []string{"orders", "products"}, q.Stats().Pending)
+
+	mu.Lock()
+	fail = nil
+	mu.Unlock()
+	require.NoError(t, q.Flush(context.Background()))
+	assert.Equal(t, [][]string{{"orders", "users"}, {"orders", "products"}}, batches)
+
+	stats := q.Stats()
+	assert.Equal(t, uint64(4), stats.Flushes)
+	assert.Equal(t, uint64(2), stats.Failures)
+	assert.Empty(t, stats.Pending)
+
+	// 已经落盘但需要报告的错误返回给调用方，但不算失败
+	mu.Lock()
+	fail = Written(boom)
+	mu.Unlock()
+	q.MarkDirty("users")
+	assert.ErrorIs(t, q.Flush(context.Background()), boom)
+	stats = q.Stats()
+	assert.Equal(t, uint64(5), stats.Flushes)
+	assert.Equal(t, uint64(2), stats.Failures)
+	assert.Empty(t, stats.Pending)
+}
+
+func TestQueue_BackgroundFlush(t *testing.T) {
+	r := &recorder{flushed: make(map[string]int)}
+	q := New(r.flush, Options{Interval: 10 * time.Millisecond})
+	defer q.Close(context.Background())
+
+	q.MarkDirty("comments")
+
+	assert.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.flushed["comments"] == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestQueue_Backoff(t *testing.T) {
+	q := &Queue{opts: Options{Interval: time.Second, MaxBackoff: 5 * time.Second}}
+
+	assert.Equal(t, time.Second, q.backoff(1))
+	assert.Equal(t, 2*time.Second, q.backoff(2))
+	assert.Equal(t, 4*time.Second, q.backoff(3))
+	assert.Equal(t, 5*time.Second, q.backoff(4))
+}
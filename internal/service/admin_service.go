@@ -0,0 +1,90 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Axpz/store/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// redactedPassword 替换返回给管理员的密码哈希
+var redactedPassword = json.RawMessage(`"*"`)
+
+// AdminService 提供排查问题用的管理操作
+type AdminService struct {
+	store storage.StoreInterface
+}
+
+func NewAdminService(store storage.StoreInterface) *AdminService {
+	return &AdminService{
+		store: store,
+	}
+}
+
+// History 返回记录的修改历史，按时间从新到旧排列，limit 是最多检查的提交数（0 表示默认值）。
+// 存储后端不保留历史时返回 ErrNotSupported
+func (s *AdminService) History(c *gin.Context, tableName, id string, limit int) ([]storage.Revision, error) {
+	history, err := s.historyReader()
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := history.History(c.Request.Context(), tableName, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i, rev := range revisions {
+		rev.Before, rev.After = redact(rev.Before), redact(rev.After)
+		for j, field := range rev.Diff {
+			if field.Field != "password" {
+				continue
+			}
+			if field.Before != nil {
+				rev.Diff[j].Before = redactedPassword
+			}
+			if field.After != nil {
+				rev.Diff[j].After = redactedPassword
+			}
+		}
+		revisions[i] = rev
+	}
+	return revisions, nil
+}
+
+// GetAt 返回记录在时刻 at 的版本
+func (s *AdminService) GetAt(c *gin.Context, tableName, id string, at time.Time) (any, error) {
+	history, err := s.historyReader()
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := history.GetAt(c.Request.Context(), tableName, id, at)
+	if err != nil {
+		return nil, err
+	}
+	return redact(record), nil
+}
+
+// Restore 恢复表 tableName 中被软删除的记录 id，记录不存在或未被删除时返回 ErrNotFound
+func (s *AdminService) Restore(c *gin.Context, tableName, id string) error {
+	return s.store.Undelete(c.Request.Context(), tableName, id)
+}
+
+func (s *AdminService) historyReader() (storage.HistoryReader, error) {
+	history, ok := s.store.(storage.HistoryReader)
+	if !ok {
+		return nil, fmt.Errorf("存储后端不保留修改历史: %w", storage.ErrNotSupported)
+	}
+	return history, nil
+}
+
+// redact 隐藏用户记录中的密码哈希
+func redact(record any) any {
+	if user, ok := record.(storage.User); ok {
+		user.Password = "*"
+		return user
+	}
+	return record
+}
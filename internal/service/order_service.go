@@ -13,6 +13,9 @@ import (
 	"go.uber.org/zap"
 )
 
+// pendingOrderTTL 是未支付订单的保留时间，过期后由存储层自动删除
+const pendingOrderTTL = 7 * 24 * time.Hour
+
 type OrderService struct {
 	store storage.StoreInterface
 }
@@ -27,7 +30,7 @@ func (s *OrderService) CreateOrder(c *gin.Context, order *types.Order) error {
 	// Get user_id from context
 	userID := utils.GetUserIDFromContext(c)
 	if userID == "" {
-		return fmt.Errorf("user id is empty")
+		return fmt.Errorf("user id is empty: %w", storage.ErrUnauthenticated)
 	}
 
 	// // Create payment order
@@ -37,17 +40,18 @@ func (s *OrderService) CreateOrder(c *gin.Context, order *types.Order) error {
 	// }
 	// order.ID = paymentOrderID
 	// Set default values
-	now := time.Now().Unix()
-	order.Created = now
-	order.Updated = now
+	now := time.Now()
+	order.Created = now.Unix()
+	order.Updated = now.Unix()
 	order.Status = "pending" // Default status
 	order.UserID = userID    // Add user_id to order
+	order.Expires = now.Add(pendingOrderTTL).Unix()
 
-	return s.store.CreateOrder(storage.Order(*order))
+	return s.store.CreateOrder(c.Request.Context(), storage.Order(*order))
 }
 
 // func (s *OrderService) CaptureOrder(c *gin.Context, orderID string) error {
-// 	order, err := s.store.GetOrder(orderID)
+// 	order, err := s.store.GetOrder(c.Request.Context(), orderID)
 // 	if err != nil {
 // 		return err
 // 	}
@@ -69,21 +73,21 @@ func (s *OrderService) CreateOrder(c *gin.Context, order *types.Order) error {
 // 	// Update order status
 // 	order.Status = "completed"
 // 	order.Updated = time.Now().Unix()
-// 	return s.store.UpdateOrder(storage.Order(order))
+// 	return s.store.UpdateOrder(c.Request.Context(), storage.Order(order))
 // }
 
 func (s *OrderService) GetOrder(c *gin.Context, id string) (*types.Order, error) {
-	order, err := s.store.GetOrder(id)
+	order, err := s.store.GetOrder(c.Request.Context(), id)
 	if err != nil {
 		return nil, err
 	}
 
 	if order.UserID != utils.GetUserIDFromContext(c) {
-		return nil, fmt.Errorf("order is not owned by current user")
+		return nil, fmt.Errorf("order %s is not owned by current user: %w", id, storage.ErrForbidden)
 	}
 
 	for i := range order.Products {
-		p, err := s.store.GetProduct(order.Products[i].ID)
+		p, err := s.store.GetProduct(c.Request.Context(), order.Products[i].ID)
 		if err != nil {
 			return nil, err
 		}
@@ -94,23 +98,37 @@ func (s *OrderService) GetOrder(c *gin.Context, id string) (*types.Order, error)
 	return &order, nil
 }
 
-func (s *OrderService) GetOrders(c *gin.Context, page, pageSize int) ([]types.Order, int, error) {
+// GetOrders 按更新时间倒序分页列出当前用户的订单，status 非空时只返回该状态的订单。
+// 返回的游标用于获取下一页，为空表示没有更多订单。
+func (s *OrderService) GetOrders(c *gin.Context, status string, limit int, cursor string) ([]types.Order, string, error) {
 	userID := utils.GetUserIDFromContext(c)
 	logger := utils.LoggerFromContext(c.Request.Context())
 
 	if userID == "" {
-		return nil, 0, fmt.Errorf("user id is empty")
+		return nil, "", fmt.Errorf("user id is empty: %w", storage.ErrUnauthenticated)
 	}
 
-	orders, err := s.store.GetOrdersByUserID(userID)
-
+	filter := map[string]string{"user_id": userID}
+	if status != "" {
+		filter["status"] = status
+	}
+	page, err := s.store.List(c.Request.Context(), "orders", storage.Query{
+		Filter: filter,
+		Sort:   "-updated",
+		Limit:  limit,
+		Cursor: cursor,
+	})
 	if err != nil {
-		return nil, 0, err
+		return nil, "", err
+	}
+	orders, err := storage.DecodePage[types.Order](page)
+	if err != nil {
+		return nil, "", err
 	}
 
 	for i := range orders {
 		for j := range orders[i].Products {
-			p, err := s.store.GetProduct(orders[i].Products[j].ID)
+			p, err := s.store.GetProduct(c.Request.Context(), orders[i].Products[j].ID)
 			if err != nil {
 				logger.Error("ignored failed to get product", zap.Error(err))
 				continue
@@ -120,31 +138,36 @@ func (s *OrderService) GetOrders(c *gin.Context, page, pageSize int) ([]types.Or
 		}
 	}
 
-	return orders, len(orders), nil
+	return orders, page.Next, nil
 }
 
 func (s *OrderService) UpdateOrder(c *gin.Context, order *types.Order) error {
 	// Update timestamp
-	dbOrder, err := s.store.GetOrder(order.ID)
+	dbOrder, err := s.store.GetOrder(c.Request.Context(), order.ID)
 	if err != nil {
 		return err
 	}
 
 	if reflect.DeepEqual(dbOrder, *order) {
-		return fmt.Errorf("no changes")
+		return fmt.Errorf("order %s has no changes: %w", order.ID, storage.ErrInvalidArgument)
 	}
 
 	if order.UserID != dbOrder.UserID {
-		return fmt.Errorf("order is not owned by current user")
+		return fmt.Errorf("order %s is not owned by current user: %w", order.ID, storage.ErrForbidden)
 	}
 
+	// 过期时间由服务端管理：订单离开 pending 状态后不再过期
+	order.Expires = dbOrder.Expires
+	if order.Status != "pending" {
+		order.Expires = 0
+	}
 	order.Updated = time.Now().Unix()
 
-	return s.store.UpdateOrder(storage.Order(*order))
+	return s.store.UpdateOrder(c.Request.Context(), storage.Order(*order))
 }
 
 func (s *OrderService) DeleteOrder(c *gin.Context, id string) error {
-	return s.store.DeleteOrder(id)
+	return s.store.DeleteOrder(c.Request.Context(), id)
 }
 
 func (s *OrderService) MarkOrderAsPaid(c *gin.Context, orderID string) error {
@@ -157,7 +180,7 @@ func (s *OrderService) MarkOrderAsPaid(c *gin.Context, orderID string) error {
 	// 3. 记录支付信息
 	// 4. 触发后续业务逻辑（如发货等）
 
-	order, err := s.store.GetOrder(orderID)
+	order, err := s.store.GetOrder(c.Request.Context(), orderID)
 	if err != nil {
 		return errors.Wrap(err, "failed to get order")
 	}
@@ -165,6 +188,7 @@ func (s *OrderService) MarkOrderAsPaid(c *gin.Context, orderID string) error {
 	logger.Info("order marked as paid successfully", zap.String("order_id", orderID))
 	// Update order status
 	order.Status = "paid"
+	order.Expires = 0
 	order.Updated = time.Now().Unix()
-	return s.store.UpdateOrder(storage.Order(order))
+	return s.store.UpdateOrder(c.Request.Context(), storage.Order(order))
 }
@@ -18,29 +18,40 @@ func NewProductService(store storage.StoreInterface) *ProductService {
 }
 
 func (s *ProductService) CreateProduct(c *gin.Context, product *storage.Product) error {
-	return fmt.Errorf("not implemented")
+	return fmt.Errorf("products are read-only: %w", storage.ErrNotSupported)
 }
 
 func (s *ProductService) GetProduct(c *gin.Context, id string) (*storage.Product, error) {
-	product, err := s.store.GetProduct(id)
+	product, err := s.store.GetProduct(c.Request.Context(), id)
 	if err != nil {
 		return nil, err
 	}
 	return &product, nil
 }
 
-func (s *ProductService) GetProducts(c *gin.Context) ([]storage.Product, error) {
-	products, err := s.store.GetProducts()
+// GetProducts 按名称分页列出商品，filter 按字段等值过滤（如 type、status）。
+// 返回的游标用于获取下一页，为空表示没有更多商品。
+func (s *ProductService) GetProducts(c *gin.Context, filter map[string]string, limit int, cursor string) ([]storage.Product, string, error) {
+	page, err := s.store.List(c.Request.Context(), "products", storage.Query{
+		Filter: filter,
+		Sort:   "name",
+		Limit:  limit,
+		Cursor: cursor,
+	})
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	products, err := storage.DecodePage[storage.Product](page)
+	if err != nil {
+		return nil, "", err
 	}
-	return products, nil
+	return products, page.Next, nil
 }
 
 func (s *ProductService) UpdateProduct(c *gin.Context, product *storage.Product) error {
-	return fmt.Errorf("not implemented")
+	return fmt.Errorf("products are read-only: %w", storage.ErrNotSupported)
 }
 
 func (s *ProductService) DeleteProduct(c *gin.Context, id string) error {
-	return fmt.Errorf("not implemented")
+	return fmt.Errorf("products are read-only: %w", storage.ErrNotSupported)
 }
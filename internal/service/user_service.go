@@ -10,6 +10,9 @@ import (
 	"go.uber.org/zap"
 )
 
+// unverifiedUserTTL 是未验证邮箱的注册用户的保留时间，过期后由存储层自动删除
+const unverifiedUserTTL = 7 * 24 * time.Hour
+
 // UserService
 type UserService struct {
 	store storage.StoreInterface
@@ -26,16 +29,18 @@ func NewUserService(store storage.StoreInterface) *UserService {
 func (s *UserService) CreateUser(c *gin.Context, user *types.User) error {
 
 	// create user object
+	now := time.Now()
 	user.ID = utils.GetUserIDFromEmail(user.Email)
-	user.Created = time.Now().Unix()
-	user.Updated = time.Now().Unix()
-	user.LastLogin = time.Now().Unix()
+	user.Created = now.Unix()
+	user.Updated = now.Unix()
+	user.LastLogin = now.Unix()
 	user.Verified = &[]bool{false}[0]
+	user.Expires = now.Add(unverifiedUserTTL).Unix()
 
 	logger := utils.LoggerFromContext(c.Request.Context())
 
 	// save user
-	if err := s.store.Create(storage.User(*user)); err != nil {
+	if err := s.store.Create(c.Request.Context(), storage.User(*user)); err != nil {
 		logger.Error("create user failed", zap.Error(err))
 		return err
 	}
@@ -46,7 +51,7 @@ func (s *UserService) CreateUser(c *gin.Context, user *types.User) error {
 // GetUser
 func (s *UserService) GetUser(c *gin.Context, id string) (*types.User, error) {
 	logger := utils.LoggerFromContext(c.Request.Context())
-	user, err := s.store.Get(id)
+	user, err := s.store.Get(c.Request.Context(), id)
 	if err != nil {
 		logger.Error("get user failed", zap.Error(err))
 		return nil, err
@@ -59,7 +64,7 @@ func (s *UserService) GetUser(c *gin.Context, id string) (*types.User, error) {
 func (s *UserService) UpdateUser(c *gin.Context, user *types.User) error {
 	logger := utils.LoggerFromContext(c.Request.Context())
 
-	existingUser, err := s.store.Get(user.ID)
+	existingUser, err := s.store.Get(c.Request.Context(), user.ID)
 	if err != nil {
 		logger.Error("get user failed", zap.Error(err))
 		return err
@@ -76,13 +81,14 @@ func (s *UserService) UpdateUser(c *gin.Context, user *types.User) error {
 	}
 	if user.Verified != nil && *user.Verified {
 		existingUser.Verified = user.Verified
+		existingUser.Expires = 0
 	}
 
 	existingUser.Plan = user.Plan
 	existingUser.Updated = time.Now().Unix()
 
 	// save update
-	if err := s.store.Update(existingUser); err != nil {
+	if err := s.store.Update(c.Request.Context(), existingUser); err != nil {
 		logger.Error("update user failed", zap.Error(err))
 		return err
 	}
@@ -94,7 +100,7 @@ func (s *UserService) UpdateUser(c *gin.Context, user *types.User) error {
 func (s *UserService) UpdateUserLastLogin(c *gin.Context, id string) error {
 	logger := utils.LoggerFromContext(c.Request.Context())
 
-	existingUser, err := s.store.Get(id)
+	existingUser, err := s.store.Get(c.Request.Context(), id)
 	if err != nil {
 		logger.Error("get user failed", zap.Error(err))
 		return err
@@ -103,7 +109,7 @@ func (s *UserService) UpdateUserLastLogin(c *gin.Context, id string) error {
 	existingUser.LastLogin = time.Now().Unix()
 
 	// save update
-	if err := s.store.Update(existingUser); err != nil {
+	if err := s.store.Update(c.Request.Context(), existingUser); err != nil {
 		logger.Error("update user failed", zap.Error(err))
 		return err
 	}
@@ -115,13 +121,13 @@ func (s *UserService) UpdateUserLastLogin(c *gin.Context, id string) error {
 func (s *UserService) DeleteUser(c *gin.Context, id string) error {
 	logger := utils.LoggerFromContext(c.Request.Context())
 
-	if _, err := s.store.Get(id); err != nil {
+	if _, err := s.store.Get(c.Request.Context(), id); err != nil {
 		logger.Error("user not found", zap.Error(err))
 		return err
 	}
 
 	// delete user
-	if err := s.store.Delete(id); err != nil {
+	if err := s.store.Delete(c.Request.Context(), id); err != nil {
 		logger.Error("delete user failed", zap.Error(err))
 		return err
 	}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic 以“临时文件 + fsync + rename”的方式写入文件，
+// 保证读者只会看到完整的旧内容或完整的新内容，不会看到写了一半的文件。
+func writeFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	tmpPath := tmp.Name()
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return fmt.Errorf("写入临时文件失败: %v", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		return fmt.Errorf("同步临时文件失败: %v", err)
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return fmt.Errorf("设置文件权限失败: %v", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("关闭临时文件失败: %v", err)
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("重命名临时文件失败: %v", err)
+	}
+
+	return syncDir(dir)
+}
+
+// syncDir 同步目录项，确保 rename 在掉电后依然可见
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("打开目录失败: %v", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("同步目录失败: %v", err)
+	}
+	return nil
+}
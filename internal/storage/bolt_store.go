@@ -0,0 +1,602 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/Axpz/store/internal/config"
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltStore 实现基于 bbolt 的嵌入式键值存储。
+// 每张表一个 bucket，记录以 JSON 存储；每个操作都在一个 bbolt 事务中完成。
+type BoltStore struct {
+	db     *bolt.DB
+	config *config.Config
+	// timeout 是单次读写的超时，写操作排队等待写事务的时间也计算在内
+	timeout time.Duration
+	// writer 是容量为 1 的写事务排队通道。bbolt 的写事务互斥且等待时无法取消，
+	// 先在这里排队，等待时间才能受 ctx 和 timeout 限制
+	writer chan struct{}
+	// tx 非 nil 时所有操作都在该读写事务中执行，见 Tx
+	tx *bolt.Tx
+	// feed 分发记录的变化，在 bbolt 事务提交后发布
+	feed *changeFeed
+}
+
+// NewBoltStore 打开 storage.path 指定的数据库文件，并确保所有 bucket 存在
+func NewBoltStore(cfg *config.Config) (StoreInterface, error) {
+	if dir := filepath.Dir(cfg.Storage.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("创建数据库目录失败: %v", err)
+		}
+	}
+
+	timeout := storageTimeout(cfg, defaultBoltTimeout)
+	db, err := bolt.Open(cfg.Storage.Path, 0600, &bolt.Options{Timeout: timeout})
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库失败: %v", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range TableNames {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return fmt.Errorf("创建 bucket %s 失败: %v", name, err)
+			}
+		}
+		for _, idx := range Indexes {
+			if err := boltCreateIndex(tx, idx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db, config: cfg, timeout: timeout, writer: make(chan struct{}, 1), feed: newChangeFeed()}, nil
+}
+
+// Create 创建新用户
+func (s *BoltStore) Create(ctx context.Context, user User) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		return boltInsert(tx, s.feed, "users", user.ID, user, errAlreadyExists("users", user.ID))
+	})
+}
+
+// Get 获取用户
+func (s *BoltStore) Get(ctx context.Context, id string) (User, error) {
+	var user User
+	err := s.view(ctx, func(tx *bolt.Tx) error {
+		return boltGet(tx, "users", id, &user, errNotFound("users", id))
+	})
+	return user, err
+}
+
+// Update 更新用户
+func (s *BoltStore) Update(ctx context.Context, user User) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		return boltReplace(tx, s.feed, "users", user.ID, user, errNotFound("users", user.ID))
+	})
+}
+
+// Delete 删除用户
+func (s *BoltStore) Delete(ctx context.Context, id string) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		return boltDelete(tx, s.feed, "users", id, errNotFound("users", id))
+	})
+}
+
+// CreateOrder 创建新订单
+func (s *BoltStore) CreateOrder(ctx context.Context, order Order) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		return boltInsert(tx, s.feed, "orders", order.ID, order, errAlreadyExists("orders", order.ID))
+	})
+}
+
+// GetOrder 获取订单
+func (s *BoltStore) GetOrder(ctx context.Context, id string) (Order, error) {
+	var order Order
+	err := s.view(ctx, func(tx *bolt.Tx) error {
+		return boltGet(tx, "orders", id, &order, errNotFound("orders", id))
+	})
+	return order, err
+}
+
+// GetOrdersByUserID 通过 user_id 索引获取用户订单，按更新时间倒序排列
+func (s *BoltStore) GetOrdersByUserID(ctx context.Context, userID string) ([]Order, error) {
+	var result []Order
+	now := time.Now().Unix()
+	err := s.view(ctx, func(tx *bolt.Tx) error {
+		orders := tx.Bucket([]byte("orders"))
+		return boltScanIndex(tx, Index{Table: "orders", Field: "user_id"}, userID, func(id []byte) error {
+			data := orders.Get(id)
+			if data == nil {
+				return nil
+			}
+
+			var order Order
+			if err := json.Unmarshal(data, &order); err != nil {
+				return fmt.Errorf("解析订单失败: %v", err)
+			}
+			if visible(order, now) {
+				result = append(result, order)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Updated > result[j].Updated
+	})
+
+	return result, nil
+}
+
+// UpdateOrder 更新订单
+func (s *BoltStore) UpdateOrder(ctx context.Context, order Order) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		return boltReplace(tx, s.feed, "orders", order.ID, order, errNotFound("orders", order.ID))
+	})
+}
+
+// DeleteOrder 删除订单
+func (s *BoltStore) DeleteOrder(ctx context.Context, id string) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		return boltDelete(tx, s.feed, "orders", id, errNotFound("orders", id))
+	})
+}
+
+// CreateProduct 创建新商品
+func (s *BoltStore) CreateProduct(ctx context.Context, product Product) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		return boltInsert(tx, s.feed, "products", product.ID, product, errAlreadyExists("products", product.ID))
+	})
+}
+
+// GetProduct 获取商品
+func (s *BoltStore) GetProduct(ctx context.Context, id string) (Product, error) {
+	var product Product
+	err := s.view(ctx, func(tx *bolt.Tx) error {
+		return boltGet(tx, "products", id, &product, errNotFound("products", id))
+	})
+	return product, err
+}
+
+// GetProducts 获取所有商品，按名称排序
+func (s *BoltStore) GetProducts(ctx context.Context) ([]Product, error) {
+	var products []Product
+	now := time.Now().Unix()
+	err := s.view(ctx, func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("products")).ForEach(func(k, v []byte) error {
+			var product Product
+			if err := json.Unmarshal(v, &product); err != nil {
+				return fmt.Errorf("解析商品失败: %v", err)
+			}
+			if visible(product, now) {
+				products = append(products, product)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].Name < products[j].Name
+	})
+
+	return products, nil
+}
+
+// UpdateProduct 更新商品
+func (s *BoltStore) UpdateProduct(ctx context.Context, product Product) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		return boltReplace(tx, s.feed, "products", product.ID, product, errNotFound("products", product.ID))
+	})
+}
+
+// DeleteProduct 删除商品
+func (s *BoltStore) DeleteProduct(ctx context.Context, id string) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		return boltDelete(tx, s.feed, "products", id, errNotFound("products", id))
+	})
+}
+
+// CreateComment 创建新评论
+func (s *BoltStore) CreateComment(ctx context.Context, comment Comment) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		return boltInsert(tx, s.feed, "comments", comment.ID, comment, errAlreadyExists("comments", comment.ID))
+	})
+}
+
+// GetComment 获取评论
+func (s *BoltStore) GetComment(ctx context.Context, id string) (Comment, error) {
+	var comment Comment
+	err := s.view(ctx, func(tx *bolt.Tx) error {
+		return boltGet(tx, "comments", id, &comment, errNotFound("comments", id))
+	})
+	return comment, err
+}
+
+// UpdateComment 更新评论
+func (s *BoltStore) UpdateComment(ctx context.Context, comment Comment) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		return boltReplace(tx, s.feed, "comments", comment.ID, comment, errNotFound("comments", comment.ID))
+	})
+}
+
+// DeleteComment 删除评论
+func (s *BoltStore) DeleteComment(ctx context.Context, id string) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		return boltDelete(tx, s.feed, "comments", id, errNotFound("comments", id))
+	})
+}
+
+// List 按 q 查询表 tableName 中的记录。
+// 过滤条件包含索引字段时只读取索引命中的记录，否则扫描整张表；排序和分页在内存中完成。
+func (s *BoltStore) List(ctx context.Context, tableName string, q Query) (Page, error) {
+	tq, err := prepareQuery(tableName, q)
+	if err != nil {
+		return Page{}, err
+	}
+
+	var records []queryRecord
+	err = s.view(ctx, func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(tableName))
+		add := func(id, data []byte) error {
+			record := reflect.New(tq.typ)
+			if err := json.Unmarshal(data, record.Interface()); err != nil {
+				return fmt.Errorf("解析记录 %s/%s 失败: %v", tableName, id, err)
+			}
+			if tq.match(record.Interface()) {
+				records = append(records, queryRecord{id: string(id), record: record.Interface()})
+			}
+			return nil
+		}
+
+		if field, value, ok := tq.indexedFilter(tableName); ok {
+			return boltScanIndex(tx, Index{Table: tableName, Field: field}, indexKey(value), func(id []byte) error {
+				if data := b.Get(id); data != nil {
+					return add(id, data)
+				}
+				return nil
+			})
+		}
+		return b.ForEach(add)
+	})
+	if err != nil {
+		return Page{}, err
+	}
+
+	return tq.page(records)
+}
+
+// Undelete 恢复表 tableName 中被软删除的记录
+func (s *BoltStore) Undelete(ctx context.Context, tableName, id string) error {
+	if !slices.Contains(TableNames, tableName) {
+		return fmt.Errorf("未知的表 %s: %w", tableName, ErrInvalidQuery)
+	}
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(tableName))
+		old := b.Get([]byte(id))
+		if old == nil || rawDeletedAt(old) == 0 {
+			return errNotFound(tableName, id)
+		}
+		record, err := decodeRecord(tableName, old)
+		if err != nil {
+			return fmt.Errorf("%s/%s: %w", tableName, id, err)
+		}
+		return boltPut(tx, s.feed, tableName, id, setDeleted(record, 0))
+	})
+}
+
+// Purge 彻底删除表 tableName 中删除时间早于 before 的墓碑及其索引
+func (s *BoltStore) Purge(ctx context.Context, tableName string, before time.Time) (int, error) {
+	if !slices.Contains(TableNames, tableName) {
+		return 0, fmt.Errorf("未知的表 %s: %w", tableName, ErrInvalidQuery)
+	}
+
+	var purged int
+	err := s.update(ctx, func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(tableName))
+		var ids [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			if at := rawDeletedAt(v); at != 0 && at < before.Unix() {
+				ids = append(ids, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if err := boltIndex(tx, tableName, string(id), b.Get(id), false); err != nil {
+				return err
+			}
+			if err := b.Delete(id); err != nil {
+				return err
+			}
+		}
+		purged = len(ids)
+		return nil
+	})
+	return purged, err
+}
+
+// Expire 彻底删除表 tableName 中在 now 时已过期的记录及其索引
+func (s *BoltStore) Expire(ctx context.Context, tableName string, now time.Time) (int, error) {
+	if !slices.Contains(TableNames, tableName) {
+		return 0, fmt.Errorf("未知的表 %s: %w", tableName, ErrInvalidQuery)
+	}
+
+	var removed int
+	err := s.update(ctx, func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(tableName))
+		var ids [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			if at := rawTimes(v).Expires; at != 0 && at <= now.Unix() {
+				ids = append(ids, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			old := b.Get(id)
+			if err := boltNotify(tx, s.feed, tableName, string(id), old, nil); err != nil {
+				return err
+			}
+			if err := boltIndex(tx, tableName, string(id), old, false); err != nil {
+				return err
+			}
+			if err := b.Delete(id); err != nil {
+				return err
+			}
+		}
+		removed = len(ids)
+		return nil
+	})
+	return removed, err
+}
+
+// Tx 在一个 bbolt 读写事务中执行 fn，fn 返回错误时回滚。
+// bbolt 同一时刻只有一个读写事务，事务之间是串行的。
+func (s *BoltStore) Tx(ctx context.Context, fn func(tx Records) error) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		return fn(&BoltStore{db: s.db, config: s.config, tx: tx, feed: s.feed})
+	})
+}
+
+// Flush bbolt 每个事务提交时已经 fsync，没有需要落盘的数据
+func (s *BoltStore) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Watch 订阅表 tableName 的变化
+func (s *BoltStore) Watch(ctx context.Context, tableName string) (<-chan Change, error) {
+	return s.feed.watch(ctx, tableName)
+}
+
+// Close 关闭 Watch 通道和数据库
+func (s *BoltStore) Close() error {
+	s.feed.close()
+	return s.db.Close()
+}
+
+// update 在读写事务中执行 fn，ctx 已结束时直接返回。
+// 等待其他写事务的时间受 timeout 限制；bbolt 的事务不支持中途取消，
+// fn 执行完时已超时则回滚，不提交超时的写入。
+// s 本身已经在事务中时直接复用该事务。
+func (s *BoltStore) update(ctx context.Context, fn func(tx *bolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	select {
+	case s.writer <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-s.writer }()
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return ctxErr(ctx)
+	})
+}
+
+// view 在只读事务中执行 fn，ctx 已结束时直接返回。
+// bbolt 的只读事务不等待写事务，超时只在事务开始前生效
+func (s *BoltStore) view(ctx context.Context, fn func(tx *bolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+	return s.db.View(fn)
+}
+
+// indexBucket 是索引 idx 的 bucket，键为 indexEntry(字段值, 记录 id)，值为空
+func indexBucket(idx Index) []byte {
+	return []byte(idx.Table + "_by_" + idx.Field)
+}
+
+func indexEntry(value, id string) []byte {
+	return []byte(value + "\x00" + id)
+}
+
+// boltCreateIndex 确保索引的 bucket 存在，新建时用表中已有的记录填充
+func boltCreateIndex(tx *bolt.Tx, idx Index) error {
+	if tx.Bucket(indexBucket(idx)) != nil {
+		return nil
+	}
+	if _, err := tx.CreateBucket(indexBucket(idx)); err != nil {
+		return fmt.Errorf("创建 bucket %s 失败: %v", indexBucket(idx), err)
+	}
+	return tx.Bucket([]byte(idx.Table)).ForEach(func(k, v []byte) error {
+		return boltIndex(tx, idx.Table, string(k), v, true)
+	})
+}
+
+// boltIndex 在表 table 的全部索引中添加（add 为 true）或删除记录 id，data 是记录的 JSON
+func boltIndex(tx *bolt.Tx, table, id string, data []byte, add bool) error {
+	fields := tableIndexes(table)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	typ := tableTypes[table]
+	record := reflect.New(typ)
+	if err := json.Unmarshal(data, record.Interface()); err != nil {
+		return fmt.Errorf("解析记录 %s/%s 失败: %v", table, id, err)
+	}
+
+	queryable := fieldsOf(typ)
+	for _, field := range fields {
+		key := indexEntry(indexKey(scalar(record.Elem().Field(queryable[field]))), id)
+		b := tx.Bucket(indexBucket(Index{Table: table, Field: field}))
+		var err error
+		if add {
+			err = b.Put(key, nil)
+		} else {
+			err = b.Delete(key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// boltScanIndex 按索引 idx 依次对字段值为 value 的记录 id 调用 fn
+func boltScanIndex(tx *bolt.Tx, idx Index, value string, fn func(id []byte) error) error {
+	prefix := indexEntry(value, "")
+	c := tx.Bucket(indexBucket(idx)).Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		if err := fn(k[len(prefix):]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// boltGet 读取一条记录，不存在或已被软删除时返回 notFoundErr
+func boltGet(tx *bolt.Tx, table, id string, v any, notFoundErr error) error {
+	data, ok := boltLive(tx, table, id)
+	if !ok {
+		return notFoundErr
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("解析记录 %s/%s 失败: %v", table, id, err)
+	}
+	return nil
+}
+
+// boltPut 写入一条记录，并更新表上的索引
+func boltPut(tx *bolt.Tx, feed *changeFeed, table, id string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("序列化记录失败: %v", err)
+	}
+
+	b := tx.Bucket([]byte(table))
+	old := b.Get([]byte(id))
+	if err := boltNotify(tx, feed, table, id, old, v); err != nil {
+		return err
+	}
+	if old != nil {
+		if err := boltIndex(tx, table, id, old, false); err != nil {
+			return err
+		}
+	}
+	if err := b.Put([]byte(id), data); err != nil {
+		return err
+	}
+	return boltIndex(tx, table, id, data, true)
+}
+
+// boltLive 返回记录的 JSON，记录不存在、已被软删除或已过期时 ok 为 false
+func boltLive(tx *bolt.Tx, table, id string) (data []byte, ok bool) {
+	data = tx.Bucket([]byte(table)).Get([]byte(id))
+	return data, data != nil && rawVisible(data, time.Now().Unix())
+}
+
+// boltInsert 写入一条新记录，已存在时返回 existsErr。同一主键的墓碑或过期记录被新记录覆盖
+func boltInsert(tx *bolt.Tx, feed *changeFeed, table, id string, v any, existsErr error) error {
+	if _, ok := boltLive(tx, table, id); ok {
+		return existsErr
+	}
+	return boltPut(tx, feed, table, id, v)
+}
+
+// boltReplace 覆盖一条已有记录，不存在、已被软删除或已过期时返回 notFoundErr
+func boltReplace(tx *bolt.Tx, feed *changeFeed, table, id string, v any, notFoundErr error) error {
+	if _, ok := boltLive(tx, table, id); !ok {
+		return notFoundErr
+	}
+	return boltPut(tx, feed, table, id, v)
+}
+
+// boltDelete 软删除一条记录：写入 Deleted 为当前时间的墓碑，不存在或已被软删除时返回 notFoundErr
+func boltDelete(tx *bolt.Tx, feed *changeFeed, table, id string, notFoundErr error) error {
+	old, ok := boltLive(tx, table, id)
+	if !ok {
+		return notFoundErr
+	}
+	record, err := decodeRecord(table, old)
+	if err != nil {
+		return fmt.Errorf("%s/%s: %w", table, id, err)
+	}
+	return boltPut(tx, feed, table, id, setDeleted(record, time.Now().Unix()))
+}
+
+// boltNotify 登记在事务提交后发布的变化。old 是修改前记录的 JSON，新建时为 nil；
+// v 是写入的记录，删除时为 nil。old 只在修改前有效，必须在写入或删除之前调用
+func boltNotify(tx *bolt.Tx, feed *changeFeed, table, id string, old []byte, v any) error {
+	if !feed.watching(table) {
+		return nil
+	}
+
+	var before any
+	if old != nil {
+		var err error
+		if before, err = decodeRecord(table, old); err != nil {
+			return fmt.Errorf("%s/%s: %w", table, id, err)
+		}
+	}
+
+	change, ok := newChange(table, id, before, old != nil, v, v != nil)
+	if !ok {
+		// 清扫已软删除的过期记录时变化前后都不可见，不发布事件
+		return nil
+	}
+	tx.OnCommit(func() { feed.publish(change) })
+	return nil
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/Axpz/store/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTestBoltStore(t *testing.T, path string) *BoltStore {
+	store, err := New(&config.Config{
+		Storage: config.StorageConfig{Type: "bolt", Path: path},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store.(*BoltStore)
+}
+
+func TestBoltStore_UserCRUD(t *testing.T) {
+	store := openTestBoltStore(t, filepath.Join(t.TempDir(), "store.db"))
+
+	user := User{ID: "user-1", Username: "Test User", Email: "test@example.com"}
+	require.NoError(t, store.Create(t.Context(), user))
+	assert.ErrorIs(t, store.Create(t.Context(), user), ErrAlreadyExists)
+
+	user.Username = "Updated User"
+	require.NoError(t, store.Update(t.Context(), user))
+	got, err := store.Get(t.Context(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user, got)
+
+	require.NoError(t, store.Delete(t.Context(), user.ID))
+	_, err = store.Get(t.Context(), user.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.Update(t.Context(), user), ErrNotFound)
+	assert.ErrorIs(t, store.Delete(t.Context(), user.ID), ErrNotFound)
+}
+
+func TestBoltStore_OrderIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	store := openTestBoltStore(t, path)
+
+	older := Order{
+		ID:       "o-1",
+		UserID:   "user-1",
+		Products: []types.OrderProduct{{ID: "p-1", Quantity: 2, Price: 100}},
+		Updated:  1,
+	}
+	newer := Order{ID: "o-2", UserID: "user-1", Updated: 2}
+	// user-10 以 user-1 为前缀，不能出现在 user-1 的订单中
+	other := Order{ID: "o-3", UserID: "user-10", Updated: 3}
+	for _, o := range []Order{older, newer, other} {
+		require.NoError(t, store.CreateOrder(t.Context(), o))
+	}
+	assert.Error(t, store.CreateOrder(t.Context(), older))
+
+	orders, err := store.GetOrdersByUserID(t.Context(), "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, []Order{newer, older}, orders)
+
+	// 修改 user_id 后索引随之迁移
+	newer.UserID = "user-10"
+	require.NoError(t, store.UpdateOrder(t.Context(), newer))
+	require.NoError(t, store.DeleteOrder(t.Context(), other.ID))
+	assert.Error(t, store.DeleteOrder(t.Context(), other.ID))
+
+	// 重新打开数据库，数据和索引保留
+	require.NoError(t, store.Close())
+	store = openTestBoltStore(t, path)
+
+	orders, err = store.GetOrdersByUserID(t.Context(), "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, []Order{older}, orders)
+
+	orders, err = store.GetOrdersByUserID(t.Context(), "user-10")
+	require.NoError(t, err)
+	assert.Equal(t, []Order{newer}, orders)
+}
+
+func TestBoltStore_IndexBackfill(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	store := openTestBoltStore(t, path)
+	require.NoError(t, store.CreateOrder(t.Context(), Order{ID: "o-1", UserID: "user-1", Status: "paid"}))
+
+	// 模拟旧版本的数据库：没有 status 索引
+	require.NoError(t, store.db.Update(func(tx *bolt.Tx) error {
+		return tx.DeleteBucket(indexBucket(Index{Table: "orders", Field: "status"}))
+	}))
+	require.NoError(t, store.Close())
+
+	// 重新打开时新建索引并填充已有记录
+	store = openTestBoltStore(t, path)
+	page, err := store.List(t.Context(), "orders", Query{Filter: map[string]string{"status": "paid"}})
+	require.NoError(t, err)
+	orders, err := DecodePage[Order](page)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"o-1"}, orderIDs(orders))
+}
+
+func TestBoltStore_ProductsAndComments(t *testing.T) {
+	store := openTestBoltStore(t, filepath.Join(t.TempDir(), "store.db"))
+
+	beta := Product{ID: "p-2", Name: "Beta", Content: []string{"x"}}
+	alpha := Product{ID: "p-1", Name: "Alpha"}
+	require.NoError(t, store.CreateProduct(t.Context(), beta))
+	require.NoError(t, store.CreateProduct(t.Context(), alpha))
+	assert.Error(t, store.CreateProduct(t.Context(), alpha))
+
+	products, err := store.GetProducts(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []Product{alpha, beta}, products)
+	assert.Error(t, store.UpdateProduct(t.Context(), Product{ID: "missing"}))
+
+	comment := Comment{ID: "c-1", UserID: "user-1", Content: "hello"}
+	require.NoError(t, store.CreateComment(t.Context(), comment))
+	assert.ErrorIs(t, store.CreateComment(t.Context(), comment), ErrAlreadyExists)
+	require.NoError(t, store.DeleteComment(t.Context(), comment.ID))
+	_, err = store.GetComment(t.Context(), comment.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestBoltStore_Timeout(t *testing.T) {
+	store, err := New(&config.Config{
+		Storage: config.StorageConfig{Type: "bolt", Path: filepath.Join(t.TempDir(), "store.db"), Timeout: 50 * time.Millisecond},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	// 一个长时间运行的事务占住写事务
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- store.Tx(context.Background(), func(tx Records) error {
+			if err := tx.Create(context.Background(), User{ID: "user-2"}); err != nil {
+				return err
+			}
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	start := time.Now()
+	err = store.Create(t.Context(), User{ID: "user-1"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// 超时的事务被回滚
+	close(release)
+	assert.ErrorIs(t, <-done, context.DeadlineExceeded)
+
+	_, err = store.Get(t.Context(), "user-2")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, store.Create(t.Context(), User{ID: "user-1"}))
+	_, err = store.Get(t.Context(), "user-1")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/Axpz/store/internal/config"
+)
+
+// gzipCompression 用 gzip 压缩表文件。读取时按文件头识别压缩格式，与当前配置无关，
+// 修改配置后旧格式的表文件仍可读取，下次写入该表时按新配置重写。
+const gzipCompression = "gzip"
+
+// noCompression 是未压缩表文件在报告中的名称
+const noCompression = "none"
+
+// gzipMagic 是 gzip 文件头的前两个字节
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// tableCompression 返回配置的表文件压缩方式，不压缩时为空
+func tableCompression(cfg config.StorageConfig) string {
+	if cfg.Compression == noCompression {
+		return ""
+	}
+	return cfg.Compression
+}
+
+// compress 按 compression 压缩表文件内容，compression 为空时原样返回
+func compress(compression string, content []byte) ([]byte, error) {
+	switch compression {
+	case "":
+		return content, nil
+	case gzipCompression:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(content); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("不支持的压缩方式: %s", compression)
+	}
+}
+
+// decompress 解压 gzip 压缩的内容，返回解压后的内容和压缩方式；未压缩的内容原样返回
+func decompress(content []byte) ([]byte, string, error) {
+	if !bytes.HasPrefix(content, gzipMagic) {
+		return content, "", nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, "", fmt.Errorf("解压表文件失败: %v", err)
+	}
+	defer zr.Close()
+	plaintext, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, "", fmt.Errorf("解压表文件失败: %v", err)
+	}
+	return plaintext, gzipCompression, nil
+}
+
+// RecompressReport 是一张表按当前压缩配置重写的结果
+type RecompressReport struct {
+	Table string
+	// From 和 To 是重写前后的压缩方式，空表示未压缩
+	From, To string
+	Records  int
+	// Before 和 After 是重写前后表文件的字节数
+	Before, After int
+}
+
+// Recompressor 由以 JSON 表文件保存数据的后端实现
+type Recompressor interface {
+	// Recompress 按当前的 storage.compression 重写全部表文件，
+	// 用于压缩已有的表文件，或在关闭压缩后把表文件恢复为 JSON
+	Recompress(ctx context.Context) ([]RecompressReport, error)
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openCompressedLocalStore(t *testing.T, dir, compression string, keys ...string) *LocalStore {
+	storageCfg := config.StorageConfig{Path: dir, Compression: compression}
+	if len(keys) > 0 {
+		storageCfg.Encryption.Keys = keys[0]
+	}
+	store, err := NewLocalStore(&config.Config{Storage: storageCfg})
+	require.NoError(t, err)
+	return store.(*LocalStore)
+}
+
+func TestCompressAndDecompress(t *testing.T) {
+	content := []byte(`{"schema_version": 1, "records": {}}`)
+
+	compressed, err := compress(gzipCompression, content)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(compressed, gzipMagic))
+
+	plaintext, compression, err := decompress(compressed)
+	require.NoError(t, err)
+	assert.Equal(t, content, plaintext)
+	assert.Equal(t, gzipCompression, compression)
+
+	// 未压缩的内容原样返回
+	plaintext, compression, err = decompress(content)
+	require.NoError(t, err)
+	assert.Equal(t, content, plaintext)
+	assert.Empty(t, compression)
+
+	_, err = compress("zstd", content)
+	assert.Error(t, err)
+}
+
+func TestLocalStore_Compression(t *testing.T) {
+	dir := t.TempDir()
+	key := testKey("k1", 1)
+
+	store := openCompressedLocalStore(t, dir, gzipCompression)
+	require.NoError(t, store.CreateOrder(t.Context(), Order{ID: "o-1", UserID: "user-1"}))
+	require.NoError(t, store.Close())
+
+	content, err := os.ReadFile(filepath.Join(dir, "orders"))
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(content, gzipMagic))
+
+	// 读取时按文件头识别，与配置无关
+	got, err := reopenLocalStore(t, dir).GetOrder(t.Context(), "o-1")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", got.UserID)
+
+	// 先压缩再加密
+	encrypted := openCompressedLocalStore(t, dir, gzipCompression, key)
+	require.NoError(t, encrypted.CreateOrder(t.Context(), Order{ID: "o-2", UserID: "user-1"}))
+	require.NoError(t, encrypted.Close())
+	content, err = os.ReadFile(filepath.Join(dir, "orders"))
+	require.NoError(t, err)
+	k, err := newKeyring(config.EncryptionConfig{Keys: key})
+	require.NoError(t, err)
+	plaintext, keyID, err := k.open("orders", content)
+	require.NoError(t, err)
+	assert.Equal(t, "k1", keyID)
+	assert.True(t, bytes.HasPrefix(plaintext, gzipMagic))
+
+	orders, err := openCompressedLocalStore(t, dir, "", key).GetOrdersByUserID(t.Context(), "user-1")
+	require.NoError(t, err)
+	assert.Len(t, orders, 2)
+}
+
+func TestLocalStore_Recompress(t *testing.T) {
+	dir := t.TempDir()
+
+	plain := reopenLocalStore(t, dir)
+	for i := range 50 {
+		require.NoError(t, plain.CreateOrder(t.Context(), Order{ID: fmt.Sprintf("o-%d", i), UserID: "user-1", Status: "paid"}))
+	}
+	require.NoError(t, plain.Close())
+
+	store := openCompressedLocalStore(t, dir, gzipCompression)
+	reports, err := store.Recompress(t.Context())
+	require.NoError(t, err)
+	orders := findRecompressReport(t, reports, "orders")
+	assert.Equal(t, "", orders.From)
+	assert.Equal(t, gzipCompression, orders.To)
+	assert.Equal(t, 50, orders.Records)
+	assert.Less(t, orders.After, orders.Before)
+	require.NoError(t, store.Close())
+
+	// 关闭压缩后恢复为 JSON
+	store = openCompressedLocalStore(t, dir, noCompression)
+	reports, err = store.Recompress(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, gzipCompression, findRecompressReport(t, reports, "orders").From)
+	assert.Equal(t, "", findRecompressReport(t, reports, "orders").To)
+	require.NoError(t, store.Close())
+
+	content, err := os.ReadFile(filepath.Join(dir, "orders"))
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(content, []byte("{")))
+}
+
+func TestGitHubStore_Compression(t *testing.T) {
+	fake := newFakeGitHub(t)
+	for _, path := range []string{"users", "orders", "products", "comments"} {
+		fake.setTable(t, "tables/"+path+".json", map[string]any{})
+	}
+	plain := fake.newStore(t)
+	require.NoError(t, plain.Create(t.Context(), User{ID: "user-1"}))
+	require.NoError(t, plain.Flush(t.Context()))
+
+	cfg := fake.config()
+	cfg.Storage.Compression = gzipCompression
+	store := fake.open(t, cfg)
+
+	// 已有的表文件在一个提交中重写为压缩格式
+	commits := fake.gitCommits
+	reports, err := store.Recompress(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, commits+1, fake.gitCommits)
+	users := findRecompressReport(t, reports, "users")
+	assert.Equal(t, RecompressReport{Table: "users", From: "", To: gzipCompression, Records: 1, Before: users.Before, After: users.After}, users)
+	assert.NotZero(t, users.After)
+
+	fake.mu.Lock()
+	content := fake.files["tables/users.json"]
+	fake.mu.Unlock()
+	assert.True(t, bytes.HasPrefix(content, gzipMagic))
+
+	// 已是当前格式的表不再提交
+	_, err = store.Recompress(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, commits+1, fake.gitCommits)
+
+	// 之后的写入也按压缩格式提交，未配置压缩的副本仍可读取
+	require.NoError(t, store.CreateOrder(t.Context(), Order{ID: "o-1", UserID: "user-1"}))
+	require.NoError(t, store.Flush(t.Context()))
+	var orders map[string]Order
+	fake.table(t, "tables/orders.json", &orders)
+	assert.Contains(t, orders, "o-1")
+
+	got, err := fake.newStore(t).GetOrder(t.Context(), "o-1")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", got.UserID)
+
+	// records 布局的记录文件不压缩
+	_, err = newFakeGitHub(t).newRecordStore(t, 0).Recompress(t.Context())
+	assert.ErrorIs(t, err, ErrNotSupported)
+}
+
+func findRecompressReport(t *testing.T, reports []RecompressReport, tableName string) RecompressReport {
+	for _, r := range reports {
+		if r.Table == tableName {
+			return r
+		}
+	}
+	t.Fatalf("没有表 %s 的重新压缩结果", tableName)
+	return RecompressReport{}
+}
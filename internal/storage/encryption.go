@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Axpz/store/internal/config"
+)
+
+// encryptionAlgorithm 是加密文件中记录的算法名
+const encryptionAlgorithm = "AES-256-GCM"
+
+// keySize 是 AES-256 的密钥长度
+const keySize = 32
+
+// journalAAD 是日志记录的附加认证数据；表文件以表名作为附加认证数据，
+// 加密后的表文件不能被替换成另一张表或日志中的内容
+const journalAAD = "journal"
+
+// ErrNoEncryptionKey 表示数据已加密，但没有配置对应 ID 的密钥
+var ErrNoEncryptionKey = errors.New("encryption key not configured")
+
+// keyring 是加密表文件和日志使用的密钥。写入总是使用当前密钥，
+// 读取时按文件中记录的密钥 ID 选择密钥，轮换期间新旧密钥都可以解密。
+// nil 表示不加密：写入明文，读取时遇到加密文件返回 ErrNoEncryptionKey。
+type keyring struct {
+	// primary 是当前密钥的 ID
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// sealed 是加密后的文件或日志记录
+type sealed struct {
+	Encryption string `json:"encryption"`
+	KeyID      string `json:"key_id"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// newKeyring 按配置加载密钥，没有配置密钥时返回 nil。
+// 密钥优先取 STORE_ENCRYPTION_KEYS（逗号分隔），否则读取 key_file（每行一个）；
+// 每个密钥的格式为 "<密钥 ID>:<base64 编码的 32 字节密钥>"，第一个为当前密钥。
+func newKeyring(cfg config.EncryptionConfig) (*keyring, error) {
+	var specs []string
+	switch {
+	case cfg.Keys != "":
+		specs = strings.Split(cfg.Keys, ",")
+	case cfg.KeyFile != "":
+		data, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取密钥文件失败: %v", err)
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			specs = append(specs, line)
+		}
+	default:
+		return nil, nil
+	}
+
+	k := &keyring{keys: make(map[string]cipher.AEAD)}
+	for _, spec := range specs {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(spec), ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("密钥格式应为 <密钥 ID>:<base64 密钥>")
+		}
+		if _, exists := k.keys[id]; exists {
+			return nil, fmt.Errorf("密钥 ID %s 重复", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("解码密钥 %s 失败: %v", id, err)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("密钥 %s 的长度为 %d 字节，应为 %d 字节", id, len(key), keySize)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		k.keys[id] = aead
+		if k.primary == "" {
+			k.primary = id
+		}
+	}
+	if k.primary == "" {
+		return nil, fmt.Errorf("没有可用的加密密钥")
+	}
+	return k, nil
+}
+
+// primaryID 返回当前密钥的 ID，不加密时为空
+func (k *keyring) primaryID() string {
+	if k == nil {
+		return ""
+	}
+	return k.primary
+}
+
+// seal 用当前密钥加密 plaintext，不加密时原样返回
+func (k *keyring) seal(aad string, plaintext []byte) ([]byte, error) {
+	if k == nil {
+		return plaintext, nil
+	}
+
+	aead := k.keys[k.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("生成随机数失败: %v", err)
+	}
+	return json.Marshal(sealed{
+		Encryption: encryptionAlgorithm,
+		KeyID:      k.primary,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, []byte(aad)),
+	})
+}
+
+// open 解密 content 并返回明文和所用密钥的 ID；content 未加密时原样返回，密钥 ID 为空
+func (k *keyring) open(aad string, content []byte) ([]byte, string, error) {
+	s, ok := parseSealed(content)
+	if !ok {
+		return content, "", nil
+	}
+	if s.Encryption != encryptionAlgorithm {
+		return nil, "", fmt.Errorf("不支持的加密算法 %s", s.Encryption)
+	}
+
+	var aead cipher.AEAD
+	if k != nil {
+		aead = k.keys[s.KeyID]
+	}
+	if aead == nil {
+		return nil, "", fmt.Errorf("密钥 %s: %w", s.KeyID, ErrNoEncryptionKey)
+	}
+	if len(s.Nonce) != aead.NonceSize() {
+		return nil, "", fmt.Errorf("解密失败: 随机数长度错误")
+	}
+	plaintext, err := aead.Open(nil, s.Nonce, s.Ciphertext, []byte(aad))
+	if err != nil {
+		return nil, "", fmt.Errorf("使用密钥 %s 解密失败: %v", s.KeyID, err)
+	}
+	return plaintext, s.KeyID, nil
+}
+
+// parseSealed 判断 content 是否为加密后的内容。
+// 表文件的顶层对象是 tableEnvelope 或记录 ID → 记录对象，不会恰好只有这四个字段且 encryption 为字符串。
+func parseSealed(content []byte) (sealed, bool) {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(content, &top); err != nil || len(top) != 4 {
+		return sealed{}, false
+	}
+	for _, field := range []string{"encryption", "key_id", "nonce", "ciphertext"} {
+		if _, ok := top[field]; !ok {
+			return sealed{}, false
+		}
+	}
+	var s sealed
+	if err := json.Unmarshal(content, &s); err != nil {
+		return sealed{}, false
+	}
+	return s, true
+}
+
+// decodeTableFile 解密（如果已加密）、解压（如果已压缩）并解析表文件，记录加密所用的密钥和压缩方式
+func (k *keyring) decodeTableFile(tableName string, content []byte) (*tableFile, error) {
+	plaintext, keyID, err := k.open(tableName, content)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, compression, err := decompress(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	file, err := decodeTableFile(tableName, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	file.keyID, file.compression = keyID, compression
+	return file, nil
+}
+
+// encodeTableFile 把表序列化为当前版本的表文件，按 compression 压缩后用当前密钥加密。
+// 压缩时不缩进。
+func (k *keyring) encodeTableFile(tableName string, data any, indent, compression string) ([]byte, error) {
+	if compression != "" {
+		indent = ""
+	}
+	content, err := encodeTableFile(tableName, data, indent)
+	if err != nil {
+		return nil, err
+	}
+	if content, err = compress(compression, content); err != nil {
+		return nil, err
+	}
+	return k.seal(tableName, content)
+}
+
+// ReencryptReport 是一张表重新加密的结果
+type ReencryptReport struct {
+	Table string
+	// From 和 To 是重新加密前后的密钥 ID，空表示明文
+	From, To string
+	Records  int
+}
+
+// Reencrypter 由以 JSON 表文件保存数据的后端实现
+type Reencrypter interface {
+	// Reencrypt 用当前密钥重写全部表文件。轮换密钥时把新密钥放在第一个、保留旧密钥，
+	// 重新加密完成后即可移除旧密钥。
+	Reencrypt(ctx context.Context) ([]ReencryptReport, error)
+}
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKey 返回 ID 为 id 的测试密钥，格式为 "<ID>:<base64 密钥>"
+func testKey(id string, b byte) string {
+	return id + ":" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, keySize))
+}
+
+func openEncryptedLocalStore(t *testing.T, dir string, keys ...string) *LocalStore {
+	store, err := NewLocalStore(&config.Config{Storage: config.StorageConfig{
+		Path:       dir,
+		Encryption: config.EncryptionConfig{Keys: strings.Join(keys, ",")},
+	}})
+	require.NoError(t, err)
+	return store.(*LocalStore)
+}
+
+func TestNewKeyring(t *testing.T) {
+	k, err := newKeyring(config.EncryptionConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, k, "未配置密钥时不加密")
+
+	keyFile := filepath.Join(t.TempDir(), "keys")
+	content := "# 当前密钥\n" + testKey("k2", 2) + "\n\n" + testKey("k1", 1) + "\n"
+	require.NoError(t, os.WriteFile(keyFile, []byte(content), 0600))
+	k, err = newKeyring(config.EncryptionConfig{KeyFile: keyFile})
+	require.NoError(t, err)
+	assert.Equal(t, "k2", k.primaryID())
+	assert.Len(t, k.keys, 2)
+
+	// 环境变量优先于密钥文件
+	k, err = newKeyring(config.EncryptionConfig{KeyFile: keyFile, Keys: testKey("k3", 3)})
+	require.NoError(t, err)
+	assert.Equal(t, "k3", k.primaryID())
+
+	for _, keys := range []string{
+		"k1",
+		"k1:not-base64!",
+		"k1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		testKey("k1", 1) + "," + testKey("k1", 2),
+	} {
+		_, err := newKeyring(config.EncryptionConfig{Keys: keys})
+		assert.Error(t, err, keys)
+	}
+}
+
+func TestKeyring_SealAndOpen(t *testing.T) {
+	k, err := newKeyring(config.EncryptionConfig{Keys: testKey("k1", 1)})
+	require.NoError(t, err)
+
+	content, err := k.seal("users", []byte(`{"user-1":{}}`))
+	require.NoError(t, err)
+	plaintext, keyID, err := k.open("users", content)
+	require.NoError(t, err)
+	assert.Equal(t, `{"user-1":{}}`, string(plaintext))
+	assert.Equal(t, "k1", keyID)
+
+	// 附加认证数据不同（文件被换成另一张表）时解密失败
+	_, _, err = k.open("orders", content)
+	assert.Error(t, err)
+
+	// 明文原样返回
+	plaintext, keyID, err = k.open("users", []byte(`{"user-1":{}}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"user-1":{}}`, string(plaintext))
+	assert.Empty(t, keyID)
+
+	var none *keyring
+	_, _, err = none.open("users", content)
+	assert.ErrorIs(t, err, ErrNoEncryptionKey)
+}
+
+func TestLocalStore_Encryption(t *testing.T) {
+	dir := t.TempDir()
+	user := User{ID: "user-1", Email: "secret@example.com"}
+
+	store := openEncryptedLocalStore(t, dir, testKey("k1", 1))
+	require.NoError(t, store.Create(t.Context(), user))
+
+	// 表文件和日志中都没有明文
+	for _, name := range []string{"users", journalFile} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.NotContains(t, string(content), user.Email, name)
+	}
+
+	// 未压缩的日志在重新打开时解密回放
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "users"), []byte("{}"), 0644))
+	reopened := openEncryptedLocalStore(t, dir, testKey("k1", 1))
+	got, err := reopened.Get(t.Context(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.Email, got.Email)
+	require.NoError(t, reopened.Close())
+
+	// 没有密钥时无法读取
+	_, err = reopenLocalStore(t, dir).Get(t.Context(), user.ID)
+	assert.ErrorIs(t, err, ErrNoEncryptionKey)
+}
+
+func TestLocalStore_Reencrypt(t *testing.T) {
+	dir := t.TempDir()
+
+	// 已有的明文数据在配置密钥后仍可读取，重新加密后变为密文
+	plain := reopenLocalStore(t, dir)
+	require.NoError(t, plain.CreateOrder(t.Context(), Order{ID: "o-1", UserID: "user-1"}))
+	require.NoError(t, plain.Close())
+
+	store := openEncryptedLocalStore(t, dir, testKey("k1", 1))
+	reports, err := store.Reencrypt(t.Context())
+	require.NoError(t, err)
+	orders := findReencryptReport(t, reports, "orders")
+	assert.Equal(t, ReencryptReport{Table: "orders", From: "", To: "k1", Records: 1}, orders)
+	require.NoError(t, store.Close())
+
+	// 轮换：新密钥在前，重新加密后只用新密钥即可读取
+	store = openEncryptedLocalStore(t, dir, testKey("k2", 2), testKey("k1", 1))
+	reports, err = store.Reencrypt(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "k1", findReencryptReport(t, reports, "orders").From)
+	assert.Equal(t, "k2", findReencryptReport(t, reports, "orders").To)
+	require.NoError(t, store.CreateOrder(t.Context(), Order{ID: "o-2", UserID: "user-1"}))
+	require.NoError(t, store.Close())
+
+	rotated := openEncryptedLocalStore(t, dir, testKey("k2", 2))
+	got, err := rotated.GetOrdersByUserID(t.Context(), "user-1")
+	require.NoError(t, err)
+	assert.Len(t, got, 2)
+}
+
+func TestGitHubStore_Encryption(t *testing.T) {
+	fake := newFakeGitHub(t)
+	for _, path := range []string{"users", "orders", "products", "comments"} {
+		fake.setTable(t, "tables/"+path+".json", map[string]any{})
+	}
+
+	cfg := fake.config()
+	cfg.Storage.Encryption.Keys = testKey("k1", 1)
+	store := fake.open(t, cfg)
+	require.NoError(t, store.Create(t.Context(), User{ID: "user-1", Email: "secret@example.com"}))
+	require.NoError(t, store.Flush(t.Context()))
+
+	fake.mu.Lock()
+	content := fake.files["tables/users.json"]
+	fake.mu.Unlock()
+	assert.NotContains(t, string(content), "secret@example.com")
+	s, ok := parseSealed(content)
+	require.True(t, ok)
+	assert.Equal(t, "k1", s.KeyID)
+
+	// 轮换密钥：明文的表和旧密钥加密的表都用新密钥重写
+	cfg = fake.config()
+	cfg.Storage.Encryption.Keys = testKey("k2", 2) + "," + testKey("k1", 1)
+	rotated := fake.open(t, cfg)
+	reports, err := rotated.Reencrypt(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, ReencryptReport{Table: "users", From: "k1", To: "k2", Records: 1}, findReencryptReport(t, reports, "users"))
+	assert.Equal(t, "", findReencryptReport(t, reports, "orders").From)
+
+	head := fake.head
+	_, err = rotated.Reencrypt(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, head, fake.head, "已由当前密钥加密的表不再提交")
+
+	cfg = fake.config()
+	cfg.Storage.Encryption.Keys = testKey("k2", 2)
+	user, err := fake.open(t, cfg).Get(t.Context(), "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "secret@example.com", user.Email)
+}
+
+func findReencryptReport(t *testing.T, reports []ReencryptReport, tableName string) ReencryptReport {
+	for _, r := range reports {
+		if r.Table == tableName {
+			return r
+		}
+	}
+	t.Fatalf("没有表 %s 的重新加密结果", tableName)
+	return ReencryptReport{}
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 存储层的哨兵错误。所有后端和服务都返回包装了这些错误之一的错误，
+// 调用方用 errors.Is 判断类别，接口层据此决定 HTTP 状态码。
+var (
+	// ErrNotFound 记录不存在
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists 创建的记录已存在
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrConflict 并发修改冲突，见 ConflictError
+	ErrConflict = errors.New("conflict")
+	// ErrForbidden 当前用户无权访问该记录
+	ErrForbidden = errors.New("forbidden")
+	// ErrUnauthenticated 请求没有关联的登录用户
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrInvalidArgument 请求的参数无效
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrNotSupported 配置的存储后端不支持该操作
+	ErrNotSupported = errors.New("not supported")
+)
+
+// errNotFound 返回表 tableName 中记录 id 不存在的错误
+func errNotFound(tableName, id string) error {
+	return fmt.Errorf("%s %s: %w", tableName, id, ErrNotFound)
+}
+
+// errAlreadyExists 返回表 tableName 中记录 id 已存在的错误
+func errAlreadyExists(tableName, id string) error {
+	return fmt.Errorf("%s %s: %w", tableName, id, ErrAlreadyExists)
+}
@@ -14,6 +14,14 @@ const (
 	GitHubStorage StorageType = "github"
 	// LocalStorage 表示本地存储
 	LocalStorage StorageType = "local"
+	// MemoryStorage 表示内存存储，用于测试和演示
+	MemoryStorage StorageType = "memory"
+	// SQLiteStorage 表示嵌入式 SQLite 存储
+	SQLiteStorage StorageType = "sqlite"
+	// BoltStorage 表示嵌入式 bbolt 键值存储
+	BoltStorage StorageType = "bolt"
+	// PostgresStorage 表示共享的 PostgreSQL 存储，适用于多副本部署
+	PostgresStorage StorageType = "postgres"
 )
 
 // NewStore 创建一个新的存储实例
@@ -23,6 +31,14 @@ func New(cfg *config.Config) (StoreInterface, error) {
 		return NewLocalStore(cfg)
 	case "github":
 		return NewGitHubStore(cfg)
+	case "memory":
+		return NewMemoryStore(cfg)
+	case "sqlite":
+		return NewSQLiteStore(cfg)
+	case "bolt":
+		return NewBoltStore(cfg)
+	case "postgres":
+		return NewPostgresStore(cfg)
 	default:
 		return nil, fmt.Errorf("不支持的存储类型: %s", cfg.Storage.Type)
 	}
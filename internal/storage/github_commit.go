@@ -0,0 +1,442 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Axpz/store/internal/pkg/writebehind"
+	"github.com/google/go-github/v45/github"
+	"go.uber.org/zap"
+)
+
+// maxMessageIDs 是提交说明中每张表每类修改最多列出的记录 ID 数
+const maxMessageIDs = 20
+
+// tableCommit 是一次提交中一张表的改动
+type tableCommit struct {
+	name    string
+	entries []*github.TreeEntry
+	// diff 是该表相对远端的记录变化，用于生成提交说明
+	diff recordDiff
+	// stale 判断该表在分支最新的目录树中是否已被其他提交修改，只用于事务提交
+	stale func(tree *github.Tree) bool
+	// apply 在提交成功后更新该表的同步状态，调用方持有写锁
+	apply func(parent, commit string)
+	// snapshot、merged 和 conflicts 只用于写回提交：准备提交时的内存表快照，
+	// 合并远端修改后提交的记录，以及合并时远端胜出的冲突记录
+	snapshot  *flushTable
+	merged    map[string]json.RawMessage
+	conflicts []string
+}
+
+// flushTable 是写回提交开始时在锁内取得的一张表的快照，之后的读取远端、合并和提交都在锁外进行
+type flushTable struct {
+	name string
+	t    table
+	// local 是快照时内存表的记录
+	local map[string]json.RawMessage
+	// remote 是快照时远端同步状态的副本，表从未同步过时为 nil
+	remote *remoteTable
+}
+
+// flushTables 是写回队列的批量落盘函数：全部待提交的表合并为一个提交
+func (s *GitHubStore) flushTables(ctx context.Context, tableNames []string) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	err := s.commitTables(ctx, tableNames)
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		// 提交已经成功，冲突记录的本地修改被远端版本覆盖，已作为 Conflict 变化发布；
+		// 写回队列把这一轮当作成功的落盘，不再重试
+		s.Logger().Warn("提交时丢弃了与远端冲突的本地修改", zap.Error(err))
+		return writebehind.Written(err)
+	}
+	return err
+}
+
+// commitTable 提交一张表，调用方不能持有锁
+func (s *GitHubStore) commitTable(ctx context.Context, tableName string) error {
+	return s.commitTables(ctx, []string{tableName})
+}
+
+// commitTables 通过 Git Data API 把 tableNames 中有修改的表作为一个提交写入数据仓库，调用方不能持有锁。
+// 只在取得内存表快照和应用提交结果时短暂加锁，访问 GitHub 和重试退避期间不阻塞读写；
+// 提交之间由 commitMu 串行化。
+//
+// 每次提交前读取分支最新的目录树，远端在上次同步后被修改的表（records 布局下为记录）先按记录 ID 三方合并；
+// 同一条记录两边都被修改时远端版本胜出，提交后返回每张冲突表的 *ConflictError。
+// 分支在提交期间被其他提交推进（非快进）时，以新的分支头重新合并并提交。
+// 提交期间内存表又被写入时，新的写入保留在内存表中，表重新标记为待提交。
+func (s *GitHubStore) commitTables(ctx context.Context, tableNames []string) error {
+	s.commitMu.Lock()
+	defer s.commitMu.Unlock()
+
+	var conflicts map[string][]string
+	for attempt := 0; ; attempt++ {
+		parent, tree, err := s.headTree(ctx)
+		if err != nil {
+			return err
+		}
+		tables, err := s.snapshotTables(tableNames)
+		if err != nil {
+			return err
+		}
+
+		var (
+			prepared []*tableCommit
+			pending  []*tableCommit
+			entries  []*github.TreeEntry
+		)
+		conflicts = make(map[string][]string)
+		for _, ft := range tables {
+			var (
+				tc  *tableCommit
+				ids []string
+			)
+			if s.recordLayout() {
+				tc, ids, err = s.prepareRecords(ctx, ft, parent.GetSHA(), tree)
+			} else {
+				tc, ids, err = s.prepareTableFile(ctx, ft, tree)
+			}
+			if err != nil {
+				return err
+			}
+			tc.conflicts = ids
+			conflicts[ft.name] = ids
+			prepared = append(prepared, tc)
+			if len(tc.entries) > 0 {
+				pending = append(pending, tc)
+				entries = append(entries, tc.entries...)
+			}
+		}
+
+		var commit string
+		if len(entries) > 0 {
+			commit, err = s.createCommit(ctx, parent, entries, commitMessage(pending))
+		}
+		if err == nil {
+			if err := s.finishCommit(prepared, parent.GetSHA(), commit); err != nil {
+				return err
+			}
+			break
+		}
+		if !isNotFastForward(err) || attempt >= maxCommitRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(commitBackoff(attempt)):
+		}
+	}
+
+	var errs []error
+	for _, tableName := range tableNames {
+		if ids := conflicts[tableName]; len(ids) > 0 {
+			sort.Strings(ids)
+			errs = append(errs, &ConflictError{Table: tableName, IDs: slices.Compact(ids)})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// snapshotTables 在读锁内取得 tableNames 的内存表和同步状态的快照
+func (s *GitHubStore) snapshotTables(tableNames []string) ([]*flushTable, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tables := make([]*flushTable, 0, len(tableNames))
+	for _, tableName := range tableNames {
+		t, err := s.table(tableName)
+		if err != nil {
+			return nil, err
+		}
+		local, err := t.records()
+		if err != nil {
+			return nil, fmt.Errorf("序列化 JSON 失败: %v", err)
+		}
+		ft := &flushTable{name: tableName, t: t, local: local}
+		if rt := s.remote[tableName]; rt != nil {
+			remote := *rt
+			ft.remote = &remote
+		}
+		tables = append(tables, ft)
+	}
+	return tables, nil
+}
+
+// finishCommit 在写锁内应用一次写回提交的结果：更新每张表的同步状态，并把合并进来的远端修改写入内存表。
+// commit 为空表示远端已经包含全部修改，没有创建提交。
+// 快照之后内存表又被写入的记录保留本地版本，表重新标记为待提交，由下一轮写回提交。
+func (s *GitHubStore) finishCommit(prepared []*tableCommit, parent, commit string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tc := range prepared {
+		ft := tc.snapshot
+		// 同步状态在提交期间被后台刷新或事务推进时保留较新的状态，下次提交会重新与远端比较
+		if cur := s.remote[tc.name]; cur == nil || ft.remote == nil || cur.sha == ft.remote.sha {
+			tc.apply(parent, commit)
+		}
+
+		// 内存表没有变化时不通过 data 修改，保留查询索引
+		current, err := ft.t.records()
+		if err != nil {
+			return fmt.Errorf("序列化 JSON 失败: %v", err)
+		}
+		next := tc.merged
+		if !recordsEqual(current, ft.local) {
+			var rewritten []string
+			next, rewritten = mergeRecords(ft.local, current, tc.merged)
+			for _, id := range rewritten {
+				if value, ok := current[id]; ok {
+					next[id] = value
+				} else {
+					delete(next, id)
+				}
+			}
+			s.queue.MarkDirty(tc.name)
+		}
+		if recordsEqual(current, next) {
+			continue
+		}
+		if err := s.applyRemote(ft.t, tc.name, ft.t.data(), current, next, tc.conflicts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareTableFile 准备 file 布局下一张表的提交，返回改动和合并时冲突的记录 ID。
+// 目录树中的表文件与上次同步的版本不同时，先按 blob SHA 读取远端版本与快照合并。
+func (s *GitHubStore) prepareTableFile(ctx context.Context, ft *flushTable, tree *github.Tree) (*tableCommit, []string, error) {
+	tableName, local := ft.name, ft.local
+	path := s.config.GetTablePath(tableName)
+	var remoteSHA string
+	for _, entry := range tree.Entries {
+		if entry.GetPath() == path {
+			remoteSHA = entry.GetSHA()
+			break
+		}
+	}
+
+	var (
+		conflicts []string
+		synced    remoteTable
+	)
+	if ft.remote != nil {
+		synced = *ft.remote
+	}
+	if ft.remote == nil || ft.remote.sha != remoteSHA {
+		remote := make(map[string]json.RawMessage)
+		file := &tableFile{}
+		var size int
+		if remoteSHA != "" {
+			content, err := s.readBlob(ctx, remoteSHA)
+			if err != nil {
+				return nil, nil, fmt.Errorf("读取表文件 %s 失败: %w", path, err)
+			}
+			snap := &tableSnapshot{sha: remoteSHA, content: content}
+			if file, remote, err = snap.decode(tableName, s.keys); err != nil {
+				return nil, nil, err
+			}
+			size = len(content)
+		}
+
+		// 表未同步过时以远端当前版本为基准，本地内容整体覆盖
+		if ft.remote != nil {
+			local, conflicts = mergeRecords(ft.remote.base, local, remote)
+		}
+		synced.sha, synced.etag, synced.base = remoteSHA, "", remote
+		synced.synced(file, size)
+	}
+
+	tc := &tableCommit{
+		name:     tableName,
+		snapshot: ft,
+		merged:   local,
+		apply: func(parent, commit string) {
+			s.remote[tableName] = &synced
+		},
+	}
+	if synced.sha != "" && synced.current(tableName, s.keys, s.compression) && recordsEqual(local, synced.base) {
+		return tc, conflicts, nil
+	}
+
+	data := ft.t.empty()
+	if err := setRecords(data, local); err != nil {
+		return nil, nil, fmt.Errorf("应用合并结果失败: %v", err)
+	}
+	content, err := s.keys.encodeTableFile(tableName, data, "  ", s.compression)
+	if err != nil {
+		return nil, nil, fmt.Errorf("序列化 JSON 失败: %v", err)
+	}
+	entry, err := s.fileEntry(ctx, path, content)
+	if err != nil {
+		return nil, nil, err
+	}
+	tc.entries = []*github.TreeEntry{entry}
+	tc.diff = diffRecords(tableName, synced.base, local)
+	tc.apply = func(parent, commit string) {
+		written := synced
+		written.sha, written.etag, written.base = gitBlobSHA(content), "", local
+		written.synced(s.writtenFile(tableName), len(content))
+		s.remote[tableName] = &written
+	}
+	return tc, conflicts, nil
+}
+
+// writtenFile 返回按当前配置写入的表文件的格式
+func (s *GitHubStore) writtenFile(tableName string) *tableFile {
+	return &tableFile{version: SchemaVersion(tableName), keyID: s.keys.primaryID(), compression: s.compression}
+}
+
+// fileEntry 返回把 content 写入文件 p 的目录树项。文本内容直接写在目录树项中；
+// 压缩后未加密的表文件是二进制内容，先以 base64 编码创建 blob。
+func (s *GitHubStore) fileEntry(ctx context.Context, p string, content []byte) (*github.TreeEntry, error) {
+	entry := &github.TreeEntry{
+		Path: github.String(p),
+		Mode: github.String("100644"),
+		Type: github.String("blob"),
+	}
+	if utf8.Valid(content) {
+		entry.Content = github.String(string(content))
+		return entry, nil
+	}
+
+	blob, _, err := s.client.Git.CreateBlob(ctx, s.config.GitHub.Repo.Owner, s.config.GitHub.Repo.Name, &github.Blob{
+		Content:  github.String(base64.StdEncoding.EncodeToString(content)),
+		Encoding: github.String("base64"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建 blob 失败: %w", err)
+	}
+	entry.SHA = blob.SHA
+	return entry, nil
+}
+
+// prepareRecords 准备 records 布局下一张表的提交，返回改动和合并时冲突的记录 ID。
+// 表目录与上次同步的文件不同时，只读取变化的记录与快照合并，其他记录的远端修改不会造成冲突。
+func (s *GitHubStore) prepareRecords(ctx context.Context, ft *flushTable, parent string, tree *github.Tree) (*tableCommit, []string, error) {
+	tableName, local := ft.name, ft.local
+	var (
+		conflicts []string
+		synced    remoteTable
+	)
+	listing := s.recordsInTree(tableName, tree)
+	switch rt := ft.remote; {
+	case rt == nil:
+		// 表未同步过，以远端当前版本为基准，本地内容整体覆盖
+		records, files, err := s.fetchRecords(ctx, tableName, listing, nil, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		synced = remoteTable{base: records, files: files}
+	case !recordFilesEqual(listing, rt.files):
+		remote, files, err := s.fetchRecords(ctx, tableName, listing, rt.files, rt.base)
+		if err != nil {
+			return nil, nil, err
+		}
+		synced = *rt
+		local, conflicts = mergeRecords(rt.base, local, remote)
+		synced.base, synced.files = remote, files
+	default:
+		synced = *rt
+	}
+	synced.sha = parent
+
+	entries, written, err := s.recordChanges(tableName, &synced, local)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &tableCommit{
+		name:     tableName,
+		entries:  entries,
+		diff:     diffRecords(tableName, synced.base, local),
+		snapshot: ft,
+		merged:   local,
+		apply: func(parent, commit string) {
+			if commit != "" {
+				synced.applyCommit(parent, commit, local, written)
+			}
+			s.remote[tableName] = &synced
+		},
+	}, conflicts, nil
+}
+
+// recordDiff 是一张表相对远端的记录变化
+type recordDiff struct {
+	table   string
+	updated []string
+	deleted []string
+}
+
+// diffRecords 比较远端记录 base 与本地记录 local，返回新增或修改、删除的记录 ID。
+// 新写入的墓碑算作删除，远端已是墓碑的记录被清理时也算作删除
+func diffRecords(tableName string, base, local map[string]json.RawMessage) recordDiff {
+	diff := recordDiff{table: tableName}
+	for id, record := range local {
+		old, ok := base[id]
+		if ok && bytes.Equal(old, record) {
+			continue
+		}
+		if rawDeletedAt(record) == 0 {
+			diff.updated = append(diff.updated, id)
+		} else if ok && rawDeletedAt(old) == 0 {
+			diff.deleted = append(diff.deleted, id)
+		}
+	}
+	for id := range base {
+		if _, ok := local[id]; !ok {
+			diff.deleted = append(diff.deleted, id)
+		}
+	}
+	sort.Strings(diff.updated)
+	sort.Strings(diff.deleted)
+	return diff
+}
+
+// String 返回提交说明中该表的一行，例如 "users: updated user-1, user-2; deleted user-3"。
+// 记录内容没有变化（只是升级版本、更换密钥或移动路径）时为 "users: rewritten"。
+func (d recordDiff) String() string {
+	var parts []string
+	if len(d.updated) > 0 {
+		parts = append(parts, "updated "+joinIDs(d.updated))
+	}
+	if len(d.deleted) > 0 {
+		parts = append(parts, "deleted "+joinIDs(d.deleted))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "rewritten")
+	}
+	return d.table + ": " + strings.Join(parts, "; ")
+}
+
+// joinIDs 列出最多 maxMessageIDs 个记录 ID，其余的只给出数量
+func joinIDs(ids []string) string {
+	if len(ids) <= maxMessageIDs {
+		return strings.Join(ids, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(ids[:maxMessageIDs], ", "), len(ids)-maxMessageIDs)
+}
+
+// commitMessage 生成提交说明：标题列出修改的表，正文每张表一行列出变化的记录 ID
+func commitMessage(pending []*tableCommit) string {
+	names := make([]string, len(pending))
+	lines := make([]string, len(pending))
+	for i, tc := range pending {
+		names[i], lines[i] = tc.name, tc.diff.String()
+	}
+	return fmt.Sprintf("Update %s\n\n%s\n", strings.Join(names, ", "), strings.Join(lines, "\n"))
+}
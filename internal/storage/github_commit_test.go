@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubStore_BatchedCommit(t *testing.T) {
+	fake := newFakeGitHub(t)
+	user := User{ID: "user-1", Username: "Original"}
+	fake.setTable(t, "tables/users.json", map[string]User{user.ID: user, "user-2": {ID: "user-2"}})
+	fake.setTable(t, "tables/orders.json", map[string]Order{})
+	fake.setTable(t, "tables/products.json", map[string]Product{})
+
+	store := fake.newStore(t)
+
+	// 一次下单同时修改订单和用户
+	require.NoError(t, store.CreateOrder(t.Context(), Order{ID: "o-1", UserID: user.ID}))
+	user.Username = "Buyer"
+	require.NoError(t, store.Update(t.Context(), user))
+	require.NoError(t, store.Delete(t.Context(), "user-2"))
+
+	// 未加载的表被直接修改，不影响批量提交
+	fake.setTable(t, "tables/products.json", map[string]Product{"p-1": {ID: "p-1"}})
+
+	head := fake.head
+	require.NoError(t, store.Flush(t.Context()))
+	assert.Equal(t, 1, fake.gitCommits, "待提交的表合并为一个提交")
+	assert.Equal(t, head, fake.commits[fake.head].parent)
+	assert.Equal(t, "Update orders, users\n\norders: updated o-1\nusers: updated user-1; deleted user-2\n",
+		fake.commits[fake.head].message)
+
+	var users map[string]User
+	fake.table(t, "tables/users.json", &users)
+	assert.Equal(t, user, users[user.ID])
+	assert.NotZero(t, users["user-2"].Deleted, "删除的用户留下墓碑")
+	var orders map[string]Order
+	fake.table(t, "tables/orders.json", &orders)
+	assert.Contains(t, orders, "o-1")
+	var products map[string]Product
+	fake.table(t, "tables/products.json", &products)
+	assert.Contains(t, products, "p-1")
+
+	// 没有新的修改时不再提交
+	require.NoError(t, store.Flush(t.Context()))
+	assert.Equal(t, 1, fake.gitCommits)
+
+	stats := store.WriteStats()
+	assert.Equal(t, uint64(2), stats.Flushes)
+	assert.Empty(t, stats.Pending)
+}
+
+func TestGitHubStore_BatchedCommitMergesRemoteChanges(t *testing.T) {
+	fake := newFakeGitHub(t)
+	fake.setTable(t, "tables/users.json", map[string]User{})
+	fake.setTable(t, "tables/orders.json", map[string]Order{})
+
+	store := fake.newStore(t)
+	require.NoError(t, store.Create(t.Context(), User{ID: "user-1"}))
+	require.NoError(t, store.CreateOrder(t.Context(), Order{ID: "o-1", UserID: "user-1"}))
+
+	// 其他副本在提交前修改了订单表
+	fake.setTable(t, "tables/orders.json", map[string]Order{"o-2": {ID: "o-2", UserID: "user-2"}})
+
+	require.NoError(t, store.Flush(t.Context()))
+	assert.Equal(t, 1, fake.gitCommits)
+	assert.Equal(t, "Update orders, users\n\norders: updated o-1\nusers: updated user-1\n",
+		fake.commits[fake.head].message, "合并进来的远端记录不算本次修改")
+
+	var orders map[string]Order
+	fake.table(t, "tables/orders.json", &orders)
+	assert.Len(t, orders, 2)
+	got, err := store.GetOrder(t.Context(), "o-2")
+	require.NoError(t, err)
+	assert.Equal(t, "user-2", got.UserID)
+}
+
+func TestGitHubStore_WritesDuringCommit(t *testing.T) {
+	fake := newFakeGitHub(t)
+	fake.setTable(t, "tables/users.json", map[string]User{})
+
+	store := fake.newStore(t)
+	require.NoError(t, store.Create(t.Context(), User{ID: "user-1", Username: "First"}))
+
+	// 提交等待 GitHub 时，读写不被阻塞
+	renamed := User{ID: "user-1", Username: "Second"}
+	fake.beforeUpdateRef = func() {
+		fake.beforeUpdateRef = nil
+		done := make(chan error, 1)
+		go func() { done <- store.Update(context.Background(), renamed) }()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Error("提交期间的写入被阻塞")
+		}
+	}
+	require.NoError(t, store.Flush(t.Context()))
+
+	var users map[string]User
+	fake.table(t, "tables/users.json", &users)
+	assert.Equal(t, "First", users["user-1"].Username, "提交的是开始提交时的快照")
+	got, err := store.Get(t.Context(), "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, renamed, got, "提交期间的写入保留")
+	assert.Equal(t, []string{"users"}, store.WriteStats().Pending)
+
+	require.NoError(t, store.Flush(t.Context()))
+	fake.table(t, "tables/users.json", &users)
+	assert.Equal(t, renamed, users["user-1"])
+}
+
+func TestGitHubStore_QueryDuringFlush(t *testing.T) {
+	fake := newFakeGitHub(t)
+	fake.setTable(t, "tables/orders.json", map[string]Order{})
+
+	store := fake.newStore(t)
+	require.NoError(t, store.CreateOrder(t.Context(), Order{ID: "o-1", UserID: "user-1", Status: "pending"}))
+
+	// 后台提交只读取内存表，与按索引查询并发时没有数据竞争，也不让索引失效
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 10 {
+			order := Order{ID: "o-1", UserID: "user-1", Status: fmt.Sprintf("status-%d", i)}
+			assert.NoError(t, store.UpdateOrder(context.Background(), order))
+			assert.NoError(t, store.Flush(context.Background()))
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			_, err := store.List(t.Context(), "orders", Query{Filter: map[string]string{"user_id": "user-1"}})
+			require.NoError(t, err)
+			assert.True(t, store.orders.indexed, "只读的提交不让索引失效")
+			return
+		default:
+		}
+		page, err := store.List(t.Context(), "orders", Query{Filter: map[string]string{"user_id": "user-1"}})
+		require.NoError(t, err)
+		require.Len(t, page.Items, 1)
+		runtime.Gosched()
+	}
+}
+
+func TestRecordDiff_String(t *testing.T) {
+	assert.Equal(t, "users: rewritten", recordDiff{table: "users"}.String())
+
+	var ids []string
+	for i := range maxMessageIDs + 3 {
+		ids = append(ids, fmt.Sprintf("o-%02d", i))
+	}
+	line := recordDiff{table: "orders", updated: ids, deleted: []string{"o-99"}}.String()
+	assert.True(t, strings.HasPrefix(line, "orders: updated o-00, o-01, "), line)
+	assert.True(t, strings.HasSuffix(line, ", o-19 and 3 more; deleted o-99"), line)
+}
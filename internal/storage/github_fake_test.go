@@ -0,0 +1,456 @@
+package storage
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/google/go-github/v45/github"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGitHub 是一个最小化的 GitHub contents API（只读）和 Git Data API 实现，用于脱网测试 GitHubStore。
+// 仓库只有一个分支，每次写入文件都生成一个新提交。
+type fakeGitHub struct {
+	mu     sync.Mutex
+	files  map[string][]byte
+	shas   map[string]string
+	server *httptest.Server
+
+	// Git Data API 的对象：blob 内容、目录树（路径 → blob SHA）、提交（SHA → 目录树 SHA 和父提交）
+	blobs   map[string][]byte
+	trees   map[string]map[string]string
+	commits map[string]fakeCommit
+	head    string
+	nextID  int
+
+	// gitCommits 统计通过 Git Data API 推进分支的次数
+	gitCommits int
+	// beforeUpdateRef 在处理推进分支的请求之前调用（持有锁），用于模拟并发提交
+	beforeUpdateRef func()
+
+	// notModified 统计命中 ETag 条件请求的次数
+	notModified int
+	// blobReads 统计读取 blob 的次数
+	blobReads int
+	// contentReads 和 treeReads 统计通过 contents API 读取文件和读取目录树的次数
+	contentReads int
+	treeReads    int
+	// delay 每个请求在响应前等待的时间，用于模拟缓慢的 API
+	delay time.Duration
+}
+
+func newFakeGitHub(t *testing.T) *fakeGitHub {
+	f := &fakeGitHub{
+		files:   make(map[string][]byte),
+		shas:    make(map[string]string),
+		blobs:   make(map[string][]byte),
+		trees:   make(map[string]map[string]string),
+		commits: make(map[string]fakeCommit),
+	}
+	f.commit()
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.server.Close)
+
+	// 不等待重试退避
+	backoff := commitBackoff
+	commitBackoff = func(int) time.Duration { return 0 }
+	t.Cleanup(func() { commitBackoff = backoff })
+
+	return f
+}
+
+// newStore 创建一个连接到该 fake 的 GitHubStore
+func (f *fakeGitHub) newStore(t *testing.T) *GitHubStore {
+	return f.open(t, f.config())
+}
+
+// config 返回连接到该 fake 的配置
+func (f *fakeGitHub) config() *config.Config {
+	return &config.Config{
+		GitHub: config.GitHubConfig{
+			Repo: config.RepoConfig{
+				Owner:  "owner",
+				Name:   "repo",
+				Branch: "main",
+				Tables: config.TablesConfig{
+					Path:     "tables",
+					Users:    "users.json",
+					Comments: "comments.json",
+					Orders:   "orders.json",
+					Products: "products.json",
+				},
+			},
+		},
+		Storage: config.StorageConfig{Type: "github"},
+	}
+}
+
+// open 按 cfg 创建一个连接到该 fake 的 GitHubStore
+func (f *fakeGitHub) open(t *testing.T, cfg *config.Config) *GitHubStore {
+	client := github.NewClient(nil)
+	baseURL, err := url.Parse(f.server.URL + "/")
+	require.NoError(t, err)
+	client.BaseURL = baseURL
+
+	store, err := newGitHubStore(cfg, client)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// setTable 模拟直接在数据仓库中编辑表文件
+func (f *fakeGitHub) setTable(t *testing.T, path string, table any) {
+	data, err := encodeTableFile(tableOfPath(path), table, "  ")
+	require.NoError(t, err)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.put(path, data)
+}
+
+// table 读取远端表文件
+func (f *fakeGitHub) table(t *testing.T, path string, table any) {
+	f.mu.Lock()
+	data := f.files[path]
+	f.mu.Unlock()
+
+	data, _, err := decompress(data)
+	require.NoError(t, err)
+	file, err := decodeTableFile(tableOfPath(path), data)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(file.records, table))
+}
+
+// tableOfPath 返回表文件路径对应的表名
+func tableOfPath(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".json")
+}
+
+func (f *fakeGitHub) put(path string, data []byte) string {
+	sha := f.blob(data)
+	f.files[path] = data
+	f.shas[path] = sha
+	f.commit()
+	return sha
+}
+
+type fakeCommit struct {
+	tree    string
+	parent  string
+	message string
+	date    time.Time
+}
+
+// fakeEpoch 是 fake 中第一个提交的时间，之后每个对象（目录树、提交）推进一分钟
+var fakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// commitDate 返回编号为 id 的提交的时间
+func commitDate(id int) time.Time {
+	return fakeEpoch.Add(time.Duration(id) * time.Minute)
+}
+
+// blob 保存内容并返回与 git 相同的 blob SHA
+func (f *fakeGitHub) blob(data []byte) string {
+	sum := sha1.Sum(append([]byte(fmt.Sprintf("blob %d\x00", len(data))), data...))
+	sha := hex.EncodeToString(sum[:])
+	f.blobs[sha] = data
+	return sha
+}
+
+// commit 以当前文件生成一个新提交并移动分支
+func (f *fakeGitHub) commit() {
+	f.nextID++
+	tree := fmt.Sprintf("tree-%d", f.nextID)
+	f.trees[tree] = maps.Clone(f.shas)
+
+	sha := fmt.Sprintf("commit-%d", f.nextID)
+	f.commits[sha] = fakeCommit{tree: tree, parent: f.head, date: commitDate(f.nextID)}
+	f.head = sha
+}
+
+func (f *fakeGitHub) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	delay := f.delay
+	f.mu.Unlock()
+	select {
+	case <-time.After(delay):
+	case <-r.Context().Done():
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if strings.HasPrefix(r.URL.Path, "/repos/owner/repo/git/") {
+		f.handleGit(w, r, strings.TrimPrefix(r.URL.Path, "/repos/owner/repo/git/"))
+		return
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/repos/owner/repo/commits" {
+		f.listCommits(w, r)
+		return
+	}
+
+	const prefix = "/repos/owner/repo/contents/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, prefix)
+
+	switch r.Method {
+	case http.MethodGet:
+		// ref 是提交 SHA 时读取该提交中的文件，否则读取分支上的最新版本
+		data, ok := f.files[path]
+		sha := f.shas[path]
+		if commit, isCommit := f.commits[r.URL.Query().Get("ref")]; isCommit {
+			sha, ok = f.trees[commit.tree][path]
+			data = f.blobs[sha]
+		}
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+			return
+		}
+		f.contentReads++
+		etag := `"` + sha + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			f.notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		writeJSON(w, http.StatusOK, map[string]string{
+			"type":     "file",
+			"encoding": "base64",
+			"path":     path,
+			"sha":      sha,
+			"content":  base64.StdEncoding.EncodeToString(data),
+		})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"message": "Method Not Allowed"})
+	}
+}
+
+// handleGit 实现 Git Data API 中 GitHubStore 用到的部分，调用方持有锁
+func (f *fakeGitHub) handleGit(w http.ResponseWriter, r *http.Request, path string) {
+	switch {
+	case r.Method == http.MethodGet && path == "ref/heads/main":
+		writeJSON(w, http.StatusOK, map[string]any{
+			"ref":    "refs/heads/main",
+			"object": map[string]string{"type": "commit", "sha": f.head},
+		})
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "commits/"):
+		sha := strings.TrimPrefix(path, "commits/")
+		commit, ok := f.commits[sha]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"sha": sha, "tree": map[string]string{"sha": commit.tree}})
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "trees/"):
+		sha := strings.TrimPrefix(path, "trees/")
+		tree, ok := f.trees[sha]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+			return
+		}
+		f.treeReads++
+		var entries []map[string]string
+		for p, blob := range tree {
+			entries = append(entries, map[string]string{"path": p, "mode": "100644", "type": "blob", "sha": blob})
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"sha": sha, "tree": entries})
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "blobs/"):
+		data, ok := f.blobs[strings.TrimPrefix(path, "blobs/")]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+			return
+		}
+		f.blobReads++
+		writeJSON(w, http.StatusOK, map[string]string{
+			"sha":      strings.TrimPrefix(path, "blobs/"),
+			"encoding": "base64",
+			"content":  base64.StdEncoding.EncodeToString(data),
+		})
+	case r.Method == http.MethodPost && path == "blobs":
+		var req struct {
+			Content  string `json:"content"`
+			Encoding string `json:"encoding"`
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		data := []byte(req.Content)
+		if req.Encoding == "base64" {
+			var err error
+			if data, err = base64.StdEncoding.DecodeString(req.Content); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+				return
+			}
+		}
+		writeJSON(w, http.StatusCreated, map[string]string{"sha": f.blob(data)})
+	case r.Method == http.MethodPost && path == "trees":
+		var req struct {
+			BaseTree string `json:"base_tree"`
+			Tree     []struct {
+				Path    string  `json:"path"`
+				SHA     *string `json:"sha"`
+				Content *string `json:"content"`
+			} `json:"tree"`
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		tree := maps.Clone(f.trees[req.BaseTree])
+		for _, entry := range req.Tree {
+			switch {
+			case entry.Content != nil:
+				tree[entry.Path] = f.blob([]byte(*entry.Content))
+			case entry.SHA != nil:
+				tree[entry.Path] = *entry.SHA
+			default:
+				// sha 为 null 表示删除文件
+				delete(tree, entry.Path)
+			}
+		}
+		f.nextID++
+		sha := fmt.Sprintf("tree-%d", f.nextID)
+		f.trees[sha] = tree
+		writeJSON(w, http.StatusCreated, map[string]string{"sha": sha})
+	case r.Method == http.MethodPost && path == "commits":
+		var req struct {
+			Tree    string   `json:"tree"`
+			Parents []string `json:"parents"`
+			Message string   `json:"message"`
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		f.nextID++
+		sha := fmt.Sprintf("commit-%d", f.nextID)
+		f.commits[sha] = fakeCommit{tree: req.Tree, parent: req.Parents[0], message: req.Message, date: commitDate(f.nextID)}
+		writeJSON(w, http.StatusCreated, map[string]string{"sha": sha})
+	case r.Method == http.MethodPatch && path == "refs/heads/main":
+		var req struct {
+			SHA string `json:"sha"`
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if f.beforeUpdateRef != nil {
+			f.beforeUpdateRef()
+		}
+		commit := f.commits[req.SHA]
+		if commit.parent != f.head {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"message": "Update is not a fast forward"})
+			return
+		}
+		f.files = make(map[string][]byte)
+		for p, blob := range f.trees[commit.tree] {
+			f.files[p] = f.blobs[blob]
+		}
+		f.shas = maps.Clone(f.trees[commit.tree])
+		f.head = req.SHA
+		f.gitCommits++
+		writeJSON(w, http.StatusOK, map[string]any{
+			"ref":    "refs/heads/main",
+			"object": map[string]string{"type": "commit", "sha": req.SHA},
+		})
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+	}
+}
+
+// listCommits 实现按路径过滤的提交列表：从分支最新提交沿父提交向前，
+// 列出修改过 path（文件或目录）的提交，支持 until 和分页，调用方持有锁
+func (f *fakeGitHub) listCommits(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	p := q.Get("path")
+	var until time.Time
+	if v := q.Get("until"); v != "" {
+		var err error
+		if until, err = time.Parse(time.RFC3339, v); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+			return
+		}
+	}
+
+	// touched 返回目录树中 path 下的文件
+	touched := func(tree string) map[string]string {
+		files := make(map[string]string)
+		for name, blob := range f.trees[tree] {
+			if p == "" || name == p || strings.HasPrefix(name, p+"/") {
+				files[name] = blob
+			}
+		}
+		return files
+	}
+
+	var list []map[string]any
+	for sha := f.head; sha != ""; sha = f.commits[sha].parent {
+		commit := f.commits[sha]
+		if !until.IsZero() && commit.date.After(until) {
+			continue
+		}
+		var parent map[string]string
+		if commit.parent != "" {
+			parent = touched(f.commits[commit.parent].tree)
+		}
+		if maps.Equal(touched(commit.tree), parent) {
+			continue
+		}
+		signature := map[string]string{"name": "fake", "date": commit.date.Format(time.RFC3339)}
+		list = append(list, map[string]any{
+			"sha": sha,
+			"commit": map[string]any{
+				"message":   commit.message,
+				"tree":      map[string]string{"sha": commit.tree},
+				"author":    signature,
+				"committer": signature,
+			},
+		})
+	}
+
+	perPage, page := 30, 1
+	if v, err := strconv.Atoi(q.Get("per_page")); err == nil && v > 0 {
+		perPage = v
+	}
+	if v, err := strconv.Atoi(q.Get("page")); err == nil && v > 0 {
+		page = v
+	}
+	start, end := min((page-1)*perPage, len(list)), min(page*perPage, len(list))
+	if end < len(list) {
+		next := *r.URL
+		q.Set("page", strconv.Itoa(page+1))
+		next.RawQuery = q.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s%s>; rel="next"`, f.server.URL, next.String()))
+	}
+	writeJSON(w, http.StatusOK, list[start:end])
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// defaultHistoryCommits 是 History 默认检查的提交数，maxHistoryCommits 是调用方可以指定的上限
+const (
+	defaultHistoryCommits = 20
+	maxHistoryCommits     = 100
+)
+
+// Revision 是一条记录在一个提交中的版本
+type Revision struct {
+	Commit  string     `json:"commit"`
+	Time    time.Time  `json:"time"`
+	Author  string     `json:"author"`
+	Message string     `json:"message"`
+	Type    ChangeType `json:"type"`
+	// Before 和 After 是该提交前后的记录，类型与表对应；新建时 Before 为 nil，删除时 After 为 nil
+	Before any `json:"before,omitempty"`
+	After  any `json:"after,omitempty"`
+	// Diff 是变化的字段，按字段名排序
+	Diff []FieldChange `json:"diff"`
+}
+
+// FieldChange 是一个字段在两个版本之间的变化。字段名和值与记录的 JSON 一致，字段不存在的一侧为空
+type FieldChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// HistoryReader 由保留了修改历史的后端实现（GitHubStore）。
+// 历史只包含已经提交的修改，写回队列中尚未提交的写入不在其中。
+type HistoryReader interface {
+	// History 返回记录的各个版本，按时间从新到旧排列。只检查最近 limit 个修改过该记录所在文件的提交，
+	// limit 不大于 0 时为 defaultHistoryCommits，最大 maxHistoryCommits。表不存在时返回 ErrInvalidQuery
+	History(ctx context.Context, tableName, id string, limit int) ([]Revision, error)
+	// GetAt 返回记录在时刻 at 的版本，类型与表对应；当时记录不存在或已被软删除时返回 ErrNotFound
+	GetAt(ctx context.Context, tableName, id string, at time.Time) (any, error)
+}
+
+// History 从数据仓库的提交历史中读出记录的各个版本。
+// 每个提交按路径读取一次记录所在的文件（file 布局是表文件，records 布局是记录文件），
+// 读到的版本按提交缓存，重复查询不再访问 GitHub。
+func (s *GitHubStore) History(ctx context.Context, tableName, id string, limit int) ([]Revision, error) {
+	if !slices.Contains(TableNames, tableName) {
+		return nil, fmt.Errorf("未知的表 %s: %w", tableName, ErrInvalidQuery)
+	}
+	if limit <= 0 {
+		limit = defaultHistoryCommits
+	}
+	limit = min(limit, maxHistoryCommits)
+
+	// 多取一个提交作为最早一个版本的修改前状态
+	commits, err := s.fileCommits(ctx, s.historyPath(tableName, id), time.Time{}, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]json.RawMessage, len(commits))
+	for i, c := range commits {
+		if versions[i], err = s.recordAt(ctx, tableName, id, c.GetSHA()); err != nil {
+			return nil, fmt.Errorf("读取提交 %s 失败: %w", c.GetSHA(), err)
+		}
+	}
+
+	var revisions []Revision
+	for i, c := range commits {
+		var before json.RawMessage
+		if i+1 < len(versions) {
+			before = versions[i+1]
+		} else if len(commits) > limit {
+			// 最早的一个提交只用作修改前状态
+			break
+		}
+		after := versions[i]
+		if bytes.Equal(before, after) {
+			continue
+		}
+
+		rev, err := newRevision(tableName, id, before, after)
+		if err != nil {
+			return nil, err
+		}
+		rev.Commit = c.GetSHA()
+		rev.Time = c.GetCommit().GetCommitter().GetDate()
+		rev.Author = c.GetCommit().GetAuthor().GetName()
+		rev.Message = c.GetCommit().GetMessage()
+		revisions = append(revisions, rev)
+	}
+	return revisions, nil
+}
+
+// GetAt 读取时刻 at 之前最后一个修改过记录所在文件的提交中的记录，记录在 at 时已被删除或已过期时返回 ErrNotFound
+func (s *GitHubStore) GetAt(ctx context.Context, tableName, id string, at time.Time) (any, error) {
+	if !slices.Contains(TableNames, tableName) {
+		return nil, fmt.Errorf("未知的表 %s: %w", tableName, ErrInvalidQuery)
+	}
+
+	commits, err := s.fileCommits(ctx, s.historyPath(tableName, id), at, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, errNotFound(tableName, id)
+	}
+
+	record, err := s.recordAt(ctx, tableName, id, commits[0].GetSHA())
+	if err != nil {
+		return nil, fmt.Errorf("读取提交 %s 失败: %w", commits[0].GetSHA(), err)
+	}
+	if record == nil || !rawVisible(record, at.Unix()) {
+		return nil, errNotFound(tableName, id)
+	}
+	return decodeRecord(tableName, record)
+}
+
+// historyPath 返回记录所在的文件：file 布局是表文件，records 布局是记录文件
+func (s *GitHubStore) historyPath(tableName, id string) string {
+	if s.recordLayout() {
+		return s.recordPath(tableName, id)
+	}
+	return s.config.GetTablePath(tableName)
+}
+
+// fileCommits 按时间从新到旧列出修改过文件 p 的提交，最多 limit 个；until 非零时只列出该时刻及之前的提交
+func (s *GitHubStore) fileCommits(ctx context.Context, p string, until time.Time, limit int) ([]*github.RepositoryCommit, error) {
+	opts := &github.CommitsListOptions{
+		SHA:         s.config.GitHub.Repo.Branch,
+		Path:        p,
+		Until:       until,
+		ListOptions: github.ListOptions{PerPage: min(limit, 100)},
+	}
+	var commits []*github.RepositoryCommit
+	for {
+		page, resp, err := s.client.Repositories.ListCommits(ctx, s.config.GitHub.Repo.Owner, s.config.GitHub.Repo.Name, opts)
+		if err != nil {
+			return nil, fmt.Errorf("列出提交失败: %w", err)
+		}
+		commits = append(commits, page...)
+		if len(commits) >= limit {
+			return commits[:limit], nil
+		}
+		if resp.NextPage == 0 {
+			return commits, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// recordAt 返回提交 commitSHA 中记录 id 的规范化 JSON，记录不存在时返回 nil
+func (s *GitHubStore) recordAt(ctx context.Context, tableName, id, commitSHA string) (json.RawMessage, error) {
+	key := historyKey{commit: commitSHA, table: tableName, id: id}
+	if record, ok := s.history.get(key); ok {
+		return record, nil
+	}
+
+	p := s.historyPath(tableName, id)
+	snap, err := s.fetchContents(ctx, p, commitSHA, "")
+	if isNotFoundResponse(err) {
+		s.history.add(key, nil)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取文件 %s 失败: %w", p, err)
+	}
+
+	var record json.RawMessage
+	if s.recordLayout() {
+		file := recordFile{path: p, sha: snap.sha}
+		if record, err = file.decode(tableName, id, snap.content, s.keys); err != nil {
+			return nil, fmt.Errorf("解析记录文件 %s 失败: %w", p, err)
+		}
+	} else {
+		_, records, err := snap.decode(tableName, s.keys)
+		if err != nil {
+			return nil, err
+		}
+		record = records[id]
+	}
+	s.history.add(key, record)
+	return record, nil
+}
+
+// historyCacheSize 是历史版本缓存的最大条目数，写满后清空重新开始
+const historyCacheSize = 4096
+
+// historyKey 标识一条记录在一个提交中的版本。提交不可变，缓存的版本不会过期
+type historyKey struct {
+	commit, table, id string
+}
+
+// historyCache 缓存记录的历史版本，nil 表示记录在该提交中不存在。零值可以直接使用
+type historyCache struct {
+	mu      sync.Mutex
+	records map[historyKey]json.RawMessage
+}
+
+func (c *historyCache) get(key historyKey) (json.RawMessage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record, ok := c.records[key]
+	return record, ok
+}
+
+func (c *historyCache) add(key historyKey, record json.RawMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.records == nil || len(c.records) >= historyCacheSize {
+		c.records = make(map[historyKey]json.RawMessage)
+	}
+	c.records[key] = record
+}
+
+// newRevision 根据记录修改前后的 JSON 构造一个版本，不存在的一侧为 nil
+func newRevision(tableName, id string, before, after json.RawMessage) (Revision, error) {
+	var values [2]any
+	for i, data := range []json.RawMessage{before, after} {
+		if data == nil {
+			continue
+		}
+		var err error
+		if values[i], err = decodeRecord(tableName, data); err != nil {
+			return Revision{}, fmt.Errorf("%s/%s: %w", tableName, id, err)
+		}
+	}
+	change, _ := newChange(tableName, id, values[0], before != nil, values[1], after != nil)
+
+	diff, err := diffFields(before, after)
+	if err != nil {
+		return Revision{}, fmt.Errorf("%s/%s: %w", tableName, id, err)
+	}
+	return Revision{Type: change.Type, Before: change.Before, After: change.After, Diff: diff}, nil
+}
+
+// diffFields 比较记录两个版本的顶层字段，返回值不同的字段
+func diffFields(before, after json.RawMessage) ([]FieldChange, error) {
+	var fields [2]map[string]json.RawMessage
+	for i, data := range []json.RawMessage{before, after} {
+		if data == nil {
+			continue
+		}
+		if err := json.Unmarshal(data, &fields[i]); err != nil {
+			return nil, fmt.Errorf("解析记录失败: %v", err)
+		}
+	}
+
+	names := make(map[string]struct{})
+	for _, f := range fields {
+		for name := range f {
+			names[name] = struct{}{}
+		}
+	}
+
+	var diff []FieldChange
+	for name := range names {
+		old, value := fields[0][name], fields[1][name]
+		if bytes.Equal(old, value) {
+			continue
+		}
+		diff = append(diff, FieldChange{Field: name, Before: old, After: value})
+	}
+	sort.Slice(diff, func(i, j int) bool { return diff[i].Field < diff[j].Field })
+	return diff, nil
+}
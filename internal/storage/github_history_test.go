@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubStore_History(t *testing.T) {
+	fake := newFakeGitHub(t)
+	for _, path := range []string{"users", "orders", "products", "comments"} {
+		fake.setTable(t, "tables/"+path+".json", map[string]any{})
+	}
+	store := fake.newStore(t)
+
+	created := User{ID: "user-1", Username: "Original", Email: "a@example.com"}
+	require.NoError(t, store.Create(t.Context(), created))
+	require.NoError(t, store.Flush(t.Context()))
+
+	updated := created
+	updated.Username = "Renamed"
+	require.NoError(t, store.Update(t.Context(), updated))
+	require.NoError(t, store.Flush(t.Context()))
+
+	// 修改同一张表中的其他记录不产生该记录的版本
+	require.NoError(t, store.Create(t.Context(), User{ID: "user-2"}))
+	require.NoError(t, store.Flush(t.Context()))
+
+	require.NoError(t, store.Delete(t.Context(), created.ID))
+	require.NoError(t, store.Flush(t.Context()))
+
+	revisions, err := store.History(t.Context(), "users", created.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+
+	deleted, renamed, first := revisions[0], revisions[1], revisions[2]
+	assert.Equal(t, ChangeDelete, deleted.Type)
+	assert.Equal(t, updated, deleted.Before)
+	assert.Nil(t, deleted.After)
+
+	assert.Equal(t, ChangeUpdate, renamed.Type)
+	assert.Equal(t, created, renamed.Before)
+	assert.Equal(t, updated, renamed.After)
+	assert.Equal(t, []FieldChange{{Field: "username", Before: json.RawMessage(`"Original"`), After: json.RawMessage(`"Renamed"`)}}, renamed.Diff)
+	assert.Equal(t, "Update users\n\nusers: updated user-1\n", renamed.Message)
+	assert.Equal(t, fake.commits[renamed.Commit].date, renamed.Time)
+
+	assert.Equal(t, ChangeCreate, first.Type)
+	assert.Equal(t, created, first.After)
+	assert.True(t, first.Time.Before(renamed.Time))
+
+	// 按时间读取历史版本
+	got, err := store.GetAt(t.Context(), "users", created.ID, first.Time)
+	require.NoError(t, err)
+	assert.Equal(t, created, got)
+	got, err = store.GetAt(t.Context(), "users", created.ID, deleted.Time.Add(-time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, updated, got)
+	_, err = store.GetAt(t.Context(), "users", created.ID, first.Time.Add(-time.Second))
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = store.GetAt(t.Context(), "users", created.ID, deleted.Time)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	_, err = store.History(t.Context(), "missing", created.ID, 0)
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+}
+
+func TestGitHubStore_HistoryRecordLayout(t *testing.T) {
+	fake := newFakeGitHub(t)
+	store := fake.newRecordStore(t, 2)
+
+	order := Order{ID: "o-1", UserID: "user-1", Status: "pending"}
+	require.NoError(t, store.CreateOrder(t.Context(), order))
+	require.NoError(t, store.Flush(t.Context()))
+	require.NoError(t, store.CreateOrder(t.Context(), Order{ID: "o-2", UserID: "user-1"}))
+	require.NoError(t, store.Flush(t.Context()))
+
+	paid := order
+	paid.Status = "paid"
+	require.NoError(t, store.UpdateOrder(t.Context(), paid))
+	require.NoError(t, store.Flush(t.Context()))
+
+	revisions, err := store.History(t.Context(), "orders", order.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, ChangeUpdate, revisions[0].Type)
+	assert.Equal(t, paid, revisions[0].After)
+	assert.Equal(t, []FieldChange{{Field: "status", Before: json.RawMessage(`"pending"`), After: json.RawMessage(`"paid"`)}}, revisions[0].Diff)
+	assert.Equal(t, ChangeCreate, revisions[1].Type)
+
+	got, err := store.GetAt(t.Context(), "orders", order.ID, revisions[1].Time)
+	require.NoError(t, err)
+	assert.Equal(t, order, got)
+}
+
+func TestGitHubStore_HistoryReads(t *testing.T) {
+	fake := newFakeGitHub(t)
+	for _, path := range []string{"users", "orders", "products", "comments"} {
+		fake.setTable(t, "tables/"+path+".json", map[string]any{})
+	}
+	store := fake.newStore(t)
+
+	user := User{ID: "user-1", Username: "a"}
+	require.NoError(t, store.Create(t.Context(), user))
+	require.NoError(t, store.Flush(t.Context()))
+	for _, name := range []string{"b", "c", "d"} {
+		user.Username = name
+		require.NoError(t, store.Update(t.Context(), user))
+		require.NoError(t, store.Flush(t.Context()))
+	}
+
+	// 每个提交按路径读取一次表文件，不读取目录树
+	fake.contentReads, fake.treeReads = 0, 0
+	revisions, err := store.History(t.Context(), "users", user.ID, 2)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, user, revisions[0].After)
+	assert.Equal(t, 3, fake.contentReads, "limit 个提交加一个修改前状态")
+	assert.Zero(t, fake.treeReads)
+
+	// 已读过的提交命中缓存
+	fake.contentReads = 0
+	revisions, err = store.History(t.Context(), "users", user.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, revisions, 4)
+	assert.Equal(t, 2, fake.contentReads, "只读取之前没有读过的提交")
+	_, err = store.GetAt(t.Context(), "users", user.ID, revisions[1].Time)
+	require.NoError(t, err)
+	assert.Equal(t, 2, fake.contentReads)
+}
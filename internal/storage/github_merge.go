@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// remoteTable 记录一张表最近一次与远端同步时的版本，
+// 作为下次提交的乐观锁 SHA 以及三方合并的公共祖先。
+type remoteTable struct {
+	sha  string
+	etag string
+	base map[string]json.RawMessage
+	// version 是远端表文件的 schema 版本，低于当前版本时即使记录没有变化也需要重写
+	version int
+	// keyID 是加密远端表文件的密钥 ID，与当前密钥不同时即使记录没有变化也需要重写
+	keyID string
+	// compression 是远端表文件的压缩方式，与配置不同时即使记录没有变化也需要重写
+	compression string
+	// size 是远端表文件的字节数
+	size int
+	// files 是 records 布局下每条记录的远端文件，此时 sha 是最近一次同步的提交
+	files map[string]recordFile
+}
+
+// current 判断远端表文件是否为当前 schema 版本、由当前密钥加密并按 compression 压缩
+func (rt *remoteTable) current(tableName string, keys *keyring, compression string) bool {
+	return rt.version == SchemaVersion(tableName) && rt.keyID == keys.primaryID() && rt.compression == compression
+}
+
+// synced 记录同步到的远端表文件的格式，size 是文件的字节数
+func (rt *remoteTable) synced(file *tableFile, size int) {
+	rt.version, rt.keyID, rt.compression, rt.size = file.version, file.keyID, file.compression, size
+}
+
+// ConflictError 表示同一条记录在本地和远端都被修改。
+// 远端版本胜出，本地对这些记录的修改被丢弃。
+type ConflictError struct {
+	Table string
+	IDs   []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("table %s: conflicting changes to records %s", e.Table, strings.Join(e.IDs, ", "))
+}
+
+// Unwrap 使 errors.Is(err, ErrConflict) 成立
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+// mergeRecords 以 base 为公共祖先，对 local 和 remote 按记录 ID 做三方合并。
+// 只有一方修改的记录取修改方；两方都修改且结果不同的记录取 remote，并返回其 ID。
+func mergeRecords(base, local, remote map[string]json.RawMessage) (map[string]json.RawMessage, []string) {
+	merged := make(map[string]json.RawMessage, len(remote))
+	var conflicts []string
+
+	ids := make(map[string]struct{}, len(base)+len(local)+len(remote))
+	for id := range base {
+		ids[id] = struct{}{}
+	}
+	for id := range local {
+		ids[id] = struct{}{}
+	}
+	for id := range remote {
+		ids[id] = struct{}{}
+	}
+
+	for id := range ids {
+		b, inBase := base[id]
+		l, inLocal := local[id]
+		r, inRemote := remote[id]
+
+		localChanged := inBase != inLocal || !bytes.Equal(b, l)
+		remoteChanged := inBase != inRemote || !bytes.Equal(b, r)
+
+		var (
+			value  json.RawMessage
+			exists bool
+		)
+		switch {
+		case !localChanged:
+			value, exists = r, inRemote
+		case !remoteChanged:
+			value, exists = l, inLocal
+		case inLocal == inRemote && bytes.Equal(l, r):
+			value, exists = l, inLocal
+		default:
+			conflicts = append(conflicts, id)
+			value, exists = r, inRemote
+		}
+
+		if exists {
+			merged[id] = value
+		}
+	}
+
+	sort.Strings(conflicts)
+	return merged, conflicts
+}
+
+// recordsEqual 判断两张表的记录是否完全一致
+func recordsEqual(a, b map[string]json.RawMessage) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id, va := range a {
+		vb, ok := b[id]
+		if !ok || !bytes.Equal(va, vb) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeRecords 将表文件内容解析为按 ID 索引的规范化记录
+func decodeRecords(data []byte) (map[string]json.RawMessage, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	records := make(map[string]json.RawMessage, len(raw))
+	for id, value := range raw {
+		canonical, err := canonicalJSON(value)
+		if err != nil {
+			return nil, fmt.Errorf("解析记录 %s 失败: %v", id, err)
+		}
+		records[id] = canonical
+	}
+	return records, nil
+}
+
+// toRecords 将内存中的表转换为规范化记录
+func toRecords(table any) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(table)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRecords(data)
+}
+
+// setRecords 用合并结果替换内存中的表，table 必须是指向 map 的指针
+func setRecords(table any, records map[string]json.RawMessage) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(table).Elem()
+	v.Set(reflect.MakeMapWithSize(v.Type(), len(records)))
+	return json.Unmarshal(data, table)
+}
+
+// canonicalJSON 将 JSON 重新编码为键有序、无空白的形式，便于逐字节比较
+func canonicalJSON(data []byte) (json.RawMessage, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeRecords(t *testing.T) {
+	raw := func(s string) json.RawMessage { return json.RawMessage(s) }
+
+	base := map[string]json.RawMessage{
+		"same":           raw(`1`),
+		"local-edit":     raw(`1`),
+		"remote-edit":    raw(`1`),
+		"both-same":      raw(`1`),
+		"both-differ":    raw(`1`),
+		"local-delete":   raw(`1`),
+		"remote-delete":  raw(`1`),
+		"delete-vs-edit": raw(`1`),
+	}
+	local := map[string]json.RawMessage{
+		"same":           raw(`1`),
+		"local-edit":     raw(`2`),
+		"remote-edit":    raw(`1`),
+		"both-same":      raw(`2`),
+		"both-differ":    raw(`2`),
+		"remote-delete":  raw(`1`),
+		"delete-vs-edit": raw(`2`),
+		"local-new":      raw(`1`),
+	}
+	remote := map[string]json.RawMessage{
+		"same":         raw(`1`),
+		"local-edit":   raw(`1`),
+		"remote-edit":  raw(`3`),
+		"both-same":    raw(`2`),
+		"both-differ":  raw(`3`),
+		"local-delete": raw(`1`),
+		"remote-new":   raw(`1`),
+	}
+
+	merged, conflicts := mergeRecords(base, local, remote)
+
+	assert.Equal(t, map[string]json.RawMessage{
+		"same":        raw(`1`),
+		"local-edit":  raw(`2`),
+		"remote-edit": raw(`3`),
+		"both-same":   raw(`2`),
+		"both-differ": raw(`3`),
+		"local-new":   raw(`1`),
+		"remote-new":  raw(`1`),
+	}, merged)
+	assert.Equal(t, []string{"both-differ", "delete-vs-edit"}, conflicts)
+}
+
+func TestGitHubStore_MergeConcurrentWrites(t *testing.T) {
+	fake := newFakeGitHub(t)
+	fake.setTable(t, "tables/users.json", map[string]User{})
+
+	a := fake.newStore(t)
+	b := fake.newStore(t)
+
+	// 两个副本都先加载同一版本
+	_, err := a.Get(t.Context(), "missing")
+	require.Error(t, err)
+	_, err = b.Get(t.Context(), "missing")
+	require.Error(t, err)
+
+	userA := User{ID: "user-a", Username: "A"}
+	userB := User{ID: "user-b", Username: "B"}
+	require.NoError(t, a.Create(t.Context(), userA))
+	require.NoError(t, b.Create(t.Context(), userB))
+	require.NoError(t, a.Flush(context.Background()))
+	require.NoError(t, b.Flush(context.Background()))
+
+	var remote map[string]User
+	fake.table(t, "tables/users.json", &remote)
+	assert.Equal(t, map[string]User{userA.ID: userA, userB.ID: userB}, remote)
+
+	// 合并后 b 的内存表也包含 a 的写入
+	got, err := b.Get(t.Context(), userA.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, userA, got)
+}
+
+func TestGitHubStore_ConflictingWrites(t *testing.T) {
+	fake := newFakeGitHub(t)
+	user := User{ID: "user-1", Username: "Original"}
+	fake.setTable(t, "tables/users.json", map[string]User{user.ID: user})
+
+	a := fake.newStore(t)
+	b := fake.newStore(t)
+
+	_, err := a.Get(t.Context(), user.ID)
+	require.NoError(t, err)
+	_, err = b.Get(t.Context(), user.ID)
+	require.NoError(t, err)
+
+	fromA := user
+	fromA.Username = "From A"
+	require.NoError(t, a.Update(t.Context(), fromA))
+	require.NoError(t, a.Flush(context.Background()))
+
+	fromB := user
+	fromB.Username = "From B"
+	require.NoError(t, b.Update(t.Context(), fromB))
+	err = b.Flush(context.Background())
+
+	var conflict *ConflictError
+	require.True(t, errors.As(err, &conflict))
+	assert.Equal(t, "users", conflict.Table)
+	assert.Equal(t, []string{user.ID}, conflict.IDs)
+
+	// 远端版本胜出
+	var remote map[string]User
+	fake.table(t, "tables/users.json", &remote)
+	assert.Equal(t, fromA, remote[user.ID])
+
+	got, err := b.Get(t.Context(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, fromA, got)
+}
+
+func TestGitHubStore_ConflictUnderWriteBehind(t *testing.T) {
+	fake := newFakeGitHub(t)
+	user := User{ID: "user-1", Username: "Original"}
+	fake.setTable(t, "tables/users.json", map[string]User{user.ID: user})
+
+	cfg := fake.config()
+	cfg.GitHub.FlushInterval = 10 * time.Millisecond
+	store := fake.open(t, cfg)
+	_, err := store.Get(t.Context(), user.ID)
+	require.NoError(t, err)
+	changes, err := store.Watch(t.Context(), "users")
+	require.NoError(t, err)
+
+	// 其他副本修改了同一条记录，本地的修改还在写回队列中
+	remote := user
+	remote.Username = "Remote"
+	fake.setTable(t, "tables/users.json", map[string]User{user.ID: remote})
+	local := user
+	local.Username = "Local"
+	require.NoError(t, store.Update(t.Context(), local))
+	require.NoError(t, store.Create(t.Context(), User{ID: "user-2"}))
+
+	// 后台写回提交成功，冲突不算失败，不重试
+	require.Eventually(t, func() bool {
+		stats := store.WriteStats()
+		return stats.Flushes > 0 && len(stats.Pending) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	stats := store.WriteStats()
+	assert.Zero(t, stats.Failures)
+	assert.NoError(t, stats.LastError)
+
+	var users map[string]User
+	fake.table(t, "tables/users.json", &users)
+	assert.Equal(t, remote, users[user.ID], "远端版本胜出")
+	assert.Contains(t, users, "user-2")
+
+	// 被丢弃的本地修改作为冲突变化发布
+	got := nextChanges(t, changes, 3)[2]
+	assert.True(t, got.Conflict)
+	assert.True(t, got.Remote)
+	assert.Equal(t, user.ID, got.ID)
+	assert.Equal(t, local, got.Before)
+	assert.Equal(t, remote, got.After)
+}
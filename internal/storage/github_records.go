@@ -0,0 +1,533 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v45/github"
+	"go.uber.org/zap"
+)
+
+// recordsLayout 是每条记录一个文件的布局：记录保存在 <tables.path>/<表名>/<ID>.json，
+// 配置了 shard_prefix 时保存在 <tables.path>/<表名>/<ID 的 SHA-1 前缀>/<ID>.json。
+// 目录通过 Git Trees API 列出，提交只包含内容变化的记录文件。
+const recordsLayout = "records"
+
+// recordFetchConcurrency 是并发读取记录文件的请求数
+const recordFetchConcurrency = 8
+
+// mixedKeys 表示 records 布局下一张表的记录由不同的密钥加密
+const mixedKeys = "mixed"
+
+// recordFile 是 records 布局下一条记录的远端文件
+type recordFile struct {
+	path string
+	// sha 是文件的 blob SHA，为空表示文件已删除
+	sha     string
+	version int
+	keyID   string
+	// migrated 表示读取时迁移修改了记录内容
+	migrated bool
+}
+
+// recordEnvelope 是记录文件的格式
+type recordEnvelope struct {
+	SchemaVersion int             `json:"schema_version"`
+	Record        json.RawMessage `json:"record"`
+}
+
+// recordLayout 判断是否使用 records 布局
+func (s *GitHubStore) recordLayout() bool {
+	return s.config.GitHub.Repo.Tables.Layout == recordsLayout
+}
+
+// tableDir 返回 records 布局下表的目录
+func (s *GitHubStore) tableDir(tableName string) string {
+	return path.Join(s.config.GitHub.Repo.Tables.Path, tableName)
+}
+
+// recordPath 返回记录文件的路径，ID 经过路径转义（"/" 写作 %2F）
+func (s *GitHubStore) recordPath(tableName, id string) string {
+	dir := s.tableDir(tableName)
+	if n := s.config.GitHub.Repo.Tables.ShardPrefix; n > 0 {
+		sum := sha1.Sum([]byte(id))
+		dir = path.Join(dir, hex.EncodeToString(sum[:])[:n])
+	}
+	return path.Join(dir, url.PathEscape(id)+".json")
+}
+
+// recordIDOf 从记录文件路径解析记录 ID，不是表 tableName 的记录文件时返回 false。
+// 解析不依赖分目录方式，修改 shard_prefix 后旧路径下的记录仍能读取，下次写入时移动到新路径。
+func (s *GitHubStore) recordIDOf(tableName, p string) (string, bool) {
+	if !strings.HasPrefix(p, s.tableDir(tableName)+"/") {
+		return "", false
+	}
+	name, ok := strings.CutSuffix(path.Base(p), ".json")
+	if !ok {
+		return "", false
+	}
+	id, err := url.PathUnescape(name)
+	return id, err == nil
+}
+
+// recordAAD 是记录文件的附加认证数据，加密后的记录不能被移到另一条记录或另一张表
+func recordAAD(tableName, id string) string {
+	return tableName + "/" + id
+}
+
+// decode 解密并解析记录文件，把记录升级到当前版本，返回规范化的记录
+func (f *recordFile) decode(tableName, id string, content []byte, keys *keyring) (json.RawMessage, error) {
+	plaintext, keyID, err := keys.open(recordAAD(tableName, id), content)
+	if err != nil {
+		return nil, err
+	}
+	var env recordEnvelope
+	if err := json.Unmarshal(plaintext, &env); err != nil {
+		return nil, err
+	}
+	if env.Record == nil {
+		return nil, fmt.Errorf("记录文件缺少 record 字段")
+	}
+
+	records, err := json.Marshal(map[string]json.RawMessage{id: env.Record})
+	if err != nil {
+		return nil, err
+	}
+	file := &tableFile{version: env.SchemaVersion, records: records}
+	if err := file.upgrade(tableName); err != nil {
+		return nil, err
+	}
+	upgraded, err := decodeRecords(file.records)
+	if err != nil {
+		return nil, err
+	}
+
+	f.version, f.keyID, f.migrated = env.SchemaVersion, keyID, len(file.changed) > 0
+	return upgraded[id], nil
+}
+
+// encodeRecordFile 把记录序列化为当前版本的记录文件，并用当前密钥加密
+func encodeRecordFile(tableName, id string, record json.RawMessage, keys *keyring) ([]byte, error) {
+	content, err := json.MarshalIndent(recordEnvelope{SchemaVersion: SchemaVersion(tableName), Record: record}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return keys.seal(recordAAD(tableName, id), content)
+}
+
+// gitBlobSHA 返回 git 为 data 计算的 blob SHA
+func gitBlobSHA(data []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// headSHA 返回分支最新提交的 SHA
+func (s *GitHubStore) headSHA(ctx context.Context) (string, error) {
+	ref, _, err := s.client.Git.GetRef(ctx, s.config.GitHub.Repo.Owner, s.config.GitHub.Repo.Name,
+		"refs/heads/"+s.config.GitHub.Repo.Branch)
+	if err != nil {
+		return "", fmt.Errorf("获取分支失败: %w", err)
+	}
+	return ref.GetObject().GetSHA(), nil
+}
+
+// headTree 返回分支最新提交及其完整目录树
+func (s *GitHubStore) headTree(ctx context.Context) (*github.Commit, *github.Tree, error) {
+	owner, repo := s.config.GitHub.Repo.Owner, s.config.GitHub.Repo.Name
+
+	head, err := s.headSHA(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	commit, _, err := s.client.Git.GetCommit(ctx, owner, repo, head)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取提交失败: %w", err)
+	}
+	tree, _, err := s.client.Git.GetTree(ctx, owner, repo, commit.GetTree().GetSHA(), true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取目录树失败: %w", err)
+	}
+	if tree.GetTruncated() {
+		return nil, nil, fmt.Errorf("数据仓库的目录树过大，被 GitHub 截断")
+	}
+	return commit, tree, nil
+}
+
+// recordsInTree 返回目录树中表 tableName 的记录文件（记录 ID → 文件）
+func (s *GitHubStore) recordsInTree(tableName string, tree *github.Tree) map[string]recordFile {
+	files := make(map[string]recordFile)
+	for _, entry := range tree.Entries {
+		if entry.GetType() != "blob" {
+			continue
+		}
+		if id, ok := s.recordIDOf(tableName, entry.GetPath()); ok {
+			files[id] = recordFile{path: entry.GetPath(), sha: entry.GetSHA()}
+		}
+	}
+	return files
+}
+
+// listRecords 列出分支最新提交中表 tableName 的记录文件，返回提交 SHA
+func (s *GitHubStore) listRecords(ctx context.Context, tableName string) (string, map[string]recordFile, error) {
+	commit, tree, err := s.headTree(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	return commit.GetSHA(), s.recordsInTree(tableName, tree), nil
+}
+
+// fetchRecords 读取 listing 中的记录。blob SHA 与 known 中相同的记录直接沿用 base 中的内容，
+// 只有新增或修改过的记录文件才会被读取，读取时最多并发 recordFetchConcurrency 个请求。
+func (s *GitHubStore) fetchRecords(ctx context.Context, tableName string, listing, known map[string]recordFile,
+	base map[string]json.RawMessage) (map[string]json.RawMessage, map[string]recordFile, error) {
+	records := make(map[string]json.RawMessage, len(listing))
+	files := make(map[string]recordFile, len(listing))
+
+	var pending []string
+	for id, f := range listing {
+		if k, ok := known[id]; ok && k.sha == f.sha {
+			if record, ok := base[id]; ok {
+				k.path = f.path
+				records[id], files[id] = record, k
+				continue
+			}
+		}
+		pending = append(pending, id)
+	}
+	sort.Strings(pending)
+
+	type result struct {
+		record json.RawMessage
+		file   recordFile
+		err    error
+	}
+	results := make([]result, len(pending))
+	sem := make(chan struct{}, recordFetchConcurrency)
+	var wg sync.WaitGroup
+	for i, id := range pending {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			file := listing[id]
+			record, err := s.fetchRecord(ctx, tableName, id, &file)
+			results[i] = result{record: record, file: file, err: err}
+		}()
+	}
+	wg.Wait()
+
+	for i, id := range pending {
+		if results[i].err != nil {
+			return nil, nil, results[i].err
+		}
+		records[id], files[id] = results[i].record, results[i].file
+	}
+	return records, files, nil
+}
+
+// fetchRecord 按 blob SHA 读取并解析一条记录
+func (s *GitHubStore) fetchRecord(ctx context.Context, tableName, id string, file *recordFile) (json.RawMessage, error) {
+	content, err := s.readBlob(ctx, file.sha)
+	if err != nil {
+		return nil, fmt.Errorf("读取记录文件 %s 失败: %w", file.path, err)
+	}
+
+	record, err := file.decode(tableName, id, content, s.keys)
+	if err != nil {
+		return nil, fmt.Errorf("解析记录文件 %s 失败: %w", file.path, err)
+	}
+	return record, nil
+}
+
+// readBlob 按 SHA 读取 blob 的内容
+func (s *GitHubStore) readBlob(ctx context.Context, sha string) ([]byte, error) {
+	blob, _, err := s.client.Git.GetBlob(ctx, s.config.GitHub.Repo.Owner, s.config.GitHub.Repo.Name, sha)
+	if err != nil {
+		return nil, err
+	}
+	if blob.GetEncoding() != "base64" {
+		return []byte(blob.GetContent()), nil
+	}
+	content, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(blob.GetContent(), "\n", ""))
+	if err != nil {
+		return nil, fmt.Errorf("解码 blob 失败: %v", err)
+	}
+	return content, nil
+}
+
+// loadRecords 是 records 布局下的 loadTable：列出表目录并读取全部记录
+func (s *GitHubStore) loadRecords(ctx context.Context, tableName string, data any) error {
+	head, listing, err := s.listRecords(ctx, tableName)
+	if err != nil {
+		return err
+	}
+	records, files, err := s.fetchRecords(ctx, tableName, listing, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := setRecords(data, records); err != nil {
+		return fmt.Errorf("解析 JSON 失败: %v", err)
+	}
+	s.remote[tableName] = &remoteTable{sha: head, base: records, files: files}
+	return nil
+}
+
+// recordChanges 比较本地记录与远端文件，返回需要写入或删除的记录文件，以及这些记录提交后的文件。
+// 内容变化、版本或密钥不是当前的、路径与当前 shard_prefix 不符的记录都会重写。
+func (s *GitHubStore) recordChanges(tableName string, rt *remoteTable, local map[string]json.RawMessage) ([]*github.TreeEntry, map[string]recordFile, error) {
+	ids := make([]string, 0, len(local)+len(rt.files))
+	for id := range local {
+		ids = append(ids, id)
+	}
+	for id := range rt.files {
+		if _, ok := local[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	current, keyID := SchemaVersion(tableName), s.keys.primaryID()
+	var entries []*github.TreeEntry
+	written := make(map[string]recordFile)
+	for _, id := range ids {
+		record, inLocal := local[id]
+		old, inRemote := rt.files[id]
+		if !inLocal {
+			entries = append(entries, deleteEntry(old.path))
+			written[id] = recordFile{}
+			continue
+		}
+
+		p := s.recordPath(tableName, id)
+		if inRemote && bytes.Equal(record, rt.base[id]) &&
+			old.version == current && old.keyID == keyID && old.path == p {
+			continue
+		}
+
+		content, err := encodeRecordFile(tableName, id, record, s.keys)
+		if err != nil {
+			return nil, nil, fmt.Errorf("序列化记录 %s/%s 失败: %v", tableName, id, err)
+		}
+		if inRemote && old.path != p {
+			entries = append(entries, deleteEntry(old.path))
+		}
+		entries = append(entries, &github.TreeEntry{
+			Path:    github.String(p),
+			Mode:    github.String("100644"),
+			Type:    github.String("blob"),
+			Content: github.String(string(content)),
+		})
+		written[id] = recordFile{path: p, sha: gitBlobSHA(content), version: current, keyID: keyID}
+	}
+	return entries, written, nil
+}
+
+// deleteEntry 返回删除文件 p 的目录树项
+func deleteEntry(p string) *github.TreeEntry {
+	return &github.TreeEntry{Path: github.String(p), Mode: github.String("100644"), Type: github.String("blob")}
+}
+
+// recordsStale 返回 written 中在远端已被修改（与上次同步的文件不同）的记录
+func recordsStale(rt *remoteTable, written, listing map[string]recordFile) []string {
+	var stale []string
+	for id := range written {
+		old, had := rt.files[id]
+		cur, has := listing[id]
+		if had != has || old.sha != cur.sha {
+			stale = append(stale, id)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// applyCommit 在记录文件提交成功后更新同步状态，records 是提交后的全部记录。
+// 父提交就是上次同步的提交时，rt 与新提交完全一致；否则其他提交修改的记录尚未读取，
+// sha 记为父提交，使后台刷新列出目录树并读取这些记录。
+func (rt *remoteTable) applyCommit(parent, commit string, records map[string]json.RawMessage, written map[string]recordFile) {
+	if rt.sha == parent {
+		rt.sha = commit
+	} else {
+		rt.sha = parent
+	}
+	rt.base = records
+
+	files := maps.Clone(rt.files)
+	for id, f := range written {
+		if f.sha == "" {
+			delete(files, id)
+		} else {
+			files[id] = f
+		}
+	}
+	rt.files = files
+}
+
+// recordFilesEqual 判断两组记录文件的 blob SHA 是否完全一致
+func recordFilesEqual(a, b map[string]recordFile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id, fa := range a {
+		fb, ok := b[id]
+		if !ok || fa.sha != fb.sha {
+			return false
+		}
+	}
+	return true
+}
+
+// createCommit 基于 parent 创建包含 entries 的目录树和提交，并以非强制方式移动分支，返回新提交的 SHA
+func (s *GitHubStore) createCommit(ctx context.Context, parent *github.Commit, entries []*github.TreeEntry, message string) (string, error) {
+	owner, repo := s.config.GitHub.Repo.Owner, s.config.GitHub.Repo.Name
+
+	newTree, _, err := s.client.Git.CreateTree(ctx, owner, repo, parent.GetTree().GetSHA(), entries)
+	if err != nil {
+		return "", fmt.Errorf("创建目录树失败: %w", err)
+	}
+	commit, _, err := s.client.Git.CreateCommit(ctx, owner, repo, &github.Commit{
+		Message: github.String(message),
+		Tree:    newTree,
+		Parents: []*github.Commit{{SHA: parent.SHA}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("创建提交失败: %w", err)
+	}
+
+	_, _, err = s.client.Git.UpdateRef(ctx, owner, repo, &github.Reference{
+		Ref:    github.String("refs/heads/" + s.config.GitHub.Repo.Branch),
+		Object: &github.GitObject{SHA: commit.SHA},
+	}, false)
+	if err != nil {
+		return "", fmt.Errorf("更新分支失败: %w", err)
+	}
+	return commit.GetSHA(), nil
+}
+
+// refreshRecords 是 records 布局下的 refreshTable。分支没有新提交时只消耗一次请求；
+// 有新提交时列出表目录，只读取 blob SHA 变化的记录，再按记录 ID 合并到内存表。
+func (s *GitHubStore) refreshRecords(ctx context.Context, tableName string) error {
+	s.mu.RLock()
+	rt := s.remote[tableName]
+	var (
+		sha   string
+		files map[string]recordFile
+		base  map[string]json.RawMessage
+	)
+	if rt != nil {
+		sha, files, base = rt.sha, rt.files, rt.base
+	}
+	s.mu.RUnlock()
+
+	if rt == nil {
+		return nil
+	}
+
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	head, err := s.headSHA(ctx)
+	if err != nil {
+		return err
+	}
+	if head == sha {
+		return nil
+	}
+	commit, tree, err := s.headTree(ctx)
+	if err != nil {
+		return err
+	}
+	remote, remoteFiles, err := s.fetchRecords(ctx, tableName, s.recordsInTree(tableName, tree), files, base)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rt = s.remote[tableName]
+	if rt == nil || rt.sha != sha {
+		return nil
+	}
+
+	t, err := s.table(tableName)
+	if err != nil {
+		return err
+	}
+	data := t.data()
+	local, err := toRecords(data)
+	if err != nil {
+		return fmt.Errorf("序列化 JSON 失败: %v", err)
+	}
+
+	merged, conflicts := mergeRecords(rt.base, local, remote)
+	for _, id := range conflicts {
+		if value, ok := local[id]; ok {
+			merged[id] = value
+		} else {
+			delete(merged, id)
+		}
+	}
+
+	if err := s.applyRemote(t, tableName, data, local, merged, nil); err != nil {
+		return err
+	}
+
+	if len(conflicts) == 0 {
+		rt.sha, rt.base, rt.files = commit.GetSHA(), remote, remoteFiles
+	}
+
+	s.Logger().Info("已合并远端表的变更",
+		zap.String("table", tableName), zap.String("commit", commit.GetSHA()), zap.Strings("conflicts", conflicts))
+	return nil
+}
+
+// inspectRecords 是 records 布局下的 inspectTable
+func (s *GitHubStore) inspectRecords(ctx context.Context, tableName string) (*remoteSummary, error) {
+	_, listing, err := s.listRecords(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(listing) == 0 {
+		return nil, nil
+	}
+	_, files, err := s.fetchRecords(ctx, tableName, listing, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &remoteSummary{version: SchemaVersion(tableName), records: len(files)}
+	keyIDs := make(map[string]bool)
+	for id, f := range files {
+		summary.version = min(summary.version, f.version)
+		keyIDs[f.keyID] = true
+		if f.migrated {
+			summary.changed = append(summary.changed, id)
+		}
+	}
+	sort.Strings(summary.changed)
+	for keyID := range keyIDs {
+		summary.keyID = keyID
+	}
+	if len(keyIDs) > 1 {
+		summary.keyID = mixedKeys
+	}
+	return summary, nil
+}
@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRecordStore 创建一个使用 records 布局、按 shardPrefix 分目录的 GitHubStore
+func (f *fakeGitHub) newRecordStore(t *testing.T, shardPrefix int) *GitHubStore {
+	cfg := f.config()
+	cfg.GitHub.Repo.Tables.Layout = recordsLayout
+	cfg.GitHub.Repo.Tables.ShardPrefix = shardPrefix
+	return f.open(t, cfg)
+}
+
+// record 读取远端记录文件
+func (f *fakeGitHub) record(t *testing.T, path, tableName, id string, v any) {
+	f.mu.Lock()
+	data, ok := f.files[path]
+	f.mu.Unlock()
+	require.True(t, ok, "没有记录文件 %s", path)
+
+	var file recordFile
+	record, err := file.decode(tableName, id, data, nil)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(record, v))
+}
+
+func TestGitHubStore_RecordLayout(t *testing.T) {
+	fake := newFakeGitHub(t)
+	store := fake.newRecordStore(t, 0)
+
+	alice := User{ID: "alice", Username: "Alice"}
+	bob := User{ID: "team/bob", Username: "Bob"}
+	require.NoError(t, store.Create(t.Context(), alice))
+	require.NoError(t, store.Create(t.Context(), bob))
+	require.NoError(t, store.Flush(t.Context()))
+
+	var got User
+	fake.record(t, "tables/users/alice.json", "users", alice.ID, &got)
+	assert.Equal(t, alice, got)
+	fake.record(t, "tables/users/team%2Fbob.json", "users", bob.ID, &got)
+	assert.Equal(t, bob, got)
+
+	// 修改一条记录只提交这一条记录的文件
+	fake.mu.Lock()
+	before := maps.Clone(fake.shas)
+	fake.mu.Unlock()
+	alice.Username = "Alice 2"
+	require.NoError(t, store.Update(t.Context(), alice))
+	require.NoError(t, store.Flush(t.Context()))
+
+	fake.mu.Lock()
+	after := maps.Clone(fake.shas)
+	fake.mu.Unlock()
+	var changed []string
+	for path, sha := range after {
+		if before[path] != sha {
+			changed = append(changed, path)
+		}
+	}
+	assert.Equal(t, []string{"tables/users/alice.json"}, changed)
+
+	// 软删除保留记录文件，清理墓碑时才删除文件
+	require.NoError(t, store.Delete(t.Context(), bob.ID))
+	require.NoError(t, store.Flush(t.Context()))
+	fake.mu.Lock()
+	_, exists := fake.files["tables/users/team%2Fbob.json"]
+	fake.mu.Unlock()
+	assert.True(t, exists)
+
+	purged, err := store.Purge(t.Context(), "users", time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	require.NoError(t, store.Flush(t.Context()))
+	fake.mu.Lock()
+	_, exists = fake.files["tables/users/team%2Fbob.json"]
+	fake.mu.Unlock()
+	assert.False(t, exists)
+
+	// 新的实例从目录树加载
+	reopened := fake.newRecordStore(t, 0)
+	got, err = reopened.Get(t.Context(), alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, alice, got)
+	_, err = reopened.Get(t.Context(), bob.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestGitHubStore_RecordLayoutShards(t *testing.T) {
+	fake := newFakeGitHub(t)
+	store := fake.newRecordStore(t, 0)
+	require.NoError(t, store.Create(t.Context(), User{ID: "user-1"}))
+	require.NoError(t, store.Create(t.Context(), User{ID: "user-2"}))
+	require.NoError(t, store.Flush(t.Context()))
+
+	// 修改 shard_prefix 后旧路径下的记录仍可读取，下次提交该表时移动到新路径
+	sharded := fake.newRecordStore(t, 2)
+	require.NoError(t, sharded.Update(t.Context(), User{ID: "user-1", Username: "moved"}))
+	require.NoError(t, sharded.Flush(t.Context()))
+
+	assert.Regexp(t, `^tables/users/[0-9a-f]{2}/user-1\.json$`, sharded.recordPath("users", "user-1"))
+	fake.mu.Lock()
+	for _, id := range []string{"user-1", "user-2"} {
+		assert.NotContains(t, fake.files, "tables/users/"+id+".json")
+		assert.Contains(t, fake.files, sharded.recordPath("users", id))
+	}
+	fake.mu.Unlock()
+
+	users, err := fake.newRecordStore(t, 2).List(t.Context(), "users", Query{})
+	require.NoError(t, err)
+	assert.Len(t, users.Items, 2)
+}
+
+func TestGitHubStore_RecordLayoutConcurrentWriters(t *testing.T) {
+	fake := newFakeGitHub(t)
+	seed := fake.newRecordStore(t, 0)
+	require.NoError(t, seed.Create(t.Context(), User{ID: "user-1", Username: "Original"}))
+	require.NoError(t, seed.Create(t.Context(), User{ID: "user-2", Username: "Original"}))
+	require.NoError(t, seed.Flush(t.Context()))
+
+	a := fake.newRecordStore(t, 0)
+	b := fake.newRecordStore(t, 0)
+	_, err := a.Get(t.Context(), "user-1")
+	require.NoError(t, err)
+	_, err = b.Get(t.Context(), "user-1")
+	require.NoError(t, err)
+
+	// 修改不同的记录不冲突
+	require.NoError(t, a.Update(t.Context(), User{ID: "user-1", Username: "From A"}))
+	require.NoError(t, a.Flush(t.Context()))
+	require.NoError(t, b.Update(t.Context(), User{ID: "user-2", Username: "From B"}))
+	require.NoError(t, b.Flush(t.Context()))
+
+	got, err := b.Get(t.Context(), "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "From A", got.Username)
+
+	// 修改同一条记录时远端版本胜出
+	require.NoError(t, a.Update(t.Context(), User{ID: "user-2", Username: "A again"}))
+	err = a.Flush(t.Context())
+	var conflict *ConflictError
+	require.True(t, errors.As(err, &conflict))
+	assert.Equal(t, []string{"user-2"}, conflict.IDs)
+	got, err = a.Get(t.Context(), "user-2")
+	require.NoError(t, err)
+	assert.Equal(t, "From B", got.Username)
+}
+
+func TestGitHubStore_RecordLayoutRefreshFetchesChangedRecords(t *testing.T) {
+	fake := newFakeGitHub(t)
+	writer := fake.newRecordStore(t, 0)
+	for _, id := range []string{"user-1", "user-2", "user-3"} {
+		require.NoError(t, writer.Create(t.Context(), User{ID: id}))
+	}
+	require.NoError(t, writer.Flush(t.Context()))
+
+	reader := fake.newRecordStore(t, 0)
+	_, err := reader.Get(t.Context(), "user-1")
+	require.NoError(t, err)
+
+	// 分支没有变化时不读取任何记录
+	fake.mu.Lock()
+	reads := fake.blobReads
+	fake.mu.Unlock()
+	require.NoError(t, reader.Reload(t.Context(), "users"))
+
+	require.NoError(t, writer.Update(t.Context(), User{ID: "user-2", Username: "changed"}))
+	require.NoError(t, writer.Flush(t.Context()))
+	require.NoError(t, reader.Reload(t.Context(), "users"))
+
+	fake.mu.Lock()
+	assert.Equal(t, reads+1, fake.blobReads, "只读取变化的记录")
+	fake.mu.Unlock()
+	got, err := reader.Get(t.Context(), "user-2")
+	require.NoError(t, err)
+	assert.Equal(t, "changed", got.Username)
+}
+
+func TestGitHubStore_RecordLayoutMigrateAndReencrypt(t *testing.T) {
+	fake := newFakeGitHub(t)
+	fake.mu.Lock()
+	fake.put("tables/users/user-1.json", []byte(`{"schema_version": 0, "record": {"id": "user-1"}}`))
+	fake.mu.Unlock()
+
+	store := fake.newRecordStore(t, 0)
+	reports, err := store.Migrate(t.Context(), true)
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, MigrationReport{Table: "users", From: 0, To: 1, Records: 1, Changed: []string{"user-1"}}, reports[0])
+
+	_, err = store.Migrate(t.Context(), false)
+	require.NoError(t, err)
+	var user User
+	fake.record(t, "tables/users/user-1.json", "users", "user-1", &user)
+	require.NotNil(t, user.Verified)
+
+	cfg := fake.config()
+	cfg.GitHub.Repo.Tables.Layout = recordsLayout
+	cfg.Storage.Encryption.Keys = testKey("k1", 1)
+	encrypted := fake.open(t, cfg)
+	reencrypted, err := encrypted.Reencrypt(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []ReencryptReport{{Table: "users", From: "", To: "k1", Records: 1}}, reencrypted)
+
+	fake.mu.Lock()
+	content := fake.files["tables/users/user-1.json"]
+	fake.mu.Unlock()
+	s, ok := parseSealed(content)
+	require.True(t, ok)
+	assert.Equal(t, "k1", s.KeyID)
+}
+
+func TestGitHubStore_RecordLayoutTx(t *testing.T) {
+	fake := newFakeGitHub(t)
+	store := fake.newRecordStore(t, 0)
+	require.NoError(t, store.Create(t.Context(), User{ID: "user-1"}))
+	require.NoError(t, store.Flush(t.Context()))
+
+	commits := fake.gitCommits
+	err := store.Tx(t.Context(), func(tx Records) error {
+		if err := tx.CreateOrder(t.Context(), Order{ID: "o-1", UserID: "user-1"}); err != nil {
+			return err
+		}
+		return tx.Delete(t.Context(), "user-1")
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.Flush(t.Context()))
+	assert.Equal(t, commits+1, fake.gitCommits, "事务是一个提交")
+
+	fake.mu.Lock()
+	_, orderExists := fake.files["tables/orders/o-1.json"]
+	_, userExists := fake.files["tables/users/user-1.json"]
+	fake.mu.Unlock()
+	assert.True(t, orderExists)
+	assert.True(t, userExists, "软删除的用户留下墓碑")
+	_, err = store.Get(t.Context(), "user-1")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Reload 立即从远端重新读取指定表，并与本地尚未提交的修改合并。
+// 尚未加载的表无需处理，下次访问时会直接读取最新版本。
+func (s *GitHubStore) Reload(ctx context.Context, tableName string) error {
+	return s.refreshTable(ctx, tableName, false)
+}
+
+// refreshLoop 按固定间隔轮询所有已加载的表，直到 ctx 结束
+func (s *GitHubStore) refreshLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.refreshAll(ctx)
+		}
+	}
+}
+
+func (s *GitHubStore) refreshAll(ctx context.Context) {
+	s.mu.RLock()
+	tables := make([]string, 0, len(s.remote))
+	for tableName := range s.remote {
+		tables = append(tables, tableName)
+	}
+	s.mu.RUnlock()
+
+	for _, tableName := range tables {
+		if err := s.refreshTable(ctx, tableName, true); err != nil {
+			s.Logger().Warn("刷新远端表失败", zap.String("table", tableName), zap.Error(err))
+		}
+	}
+}
+
+// refreshTable 检查远端表文件是否变化，有变化时按记录 ID 合并到内存表。
+// conditional 为 true 时使用 ETag 条件请求，未变化的表不消耗 API 配额。
+//
+// 远端请求在锁外执行；合并时若发现本地已经提交或重新加载过，则放弃本轮结果。
+// 本地未提交的修改始终保留：同一条记录两边都改过时保留本地版本，
+// 并且不推进同步版本，使下次提交走冲突检测流程。
+func (s *GitHubStore) refreshTable(ctx context.Context, tableName string, conditional bool) error {
+	if s.recordLayout() {
+		return s.refreshRecords(ctx, tableName)
+	}
+
+	s.mu.RLock()
+	rt := s.remote[tableName]
+	var sha, etag string
+	if rt != nil {
+		sha = rt.sha
+		if conditional {
+			etag = rt.etag
+		}
+	}
+	s.mu.RUnlock()
+
+	if rt == nil {
+		return nil
+	}
+
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	snap, err := s.fetchTable(ctx, tableName, etag)
+	if errors.Is(err, errNotModified) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rt = s.remote[tableName]
+	if rt == nil || rt.sha != sha {
+		return nil
+	}
+
+	rt.etag = snap.etag
+	if snap.sha == rt.sha {
+		return nil
+	}
+
+	file, remote, err := snap.decode(tableName, s.keys)
+	if err != nil {
+		return err
+	}
+
+	t, err := s.table(tableName)
+	if err != nil {
+		return err
+	}
+	data := t.data()
+	local, err := toRecords(data)
+	if err != nil {
+		return fmt.Errorf("序列化 JSON 失败: %v", err)
+	}
+
+	merged, conflicts := mergeRecords(rt.base, local, remote)
+	for _, id := range conflicts {
+		if value, ok := local[id]; ok {
+			merged[id] = value
+		} else {
+			delete(merged, id)
+		}
+	}
+
+	if err := s.applyRemote(t, tableName, data, local, merged, nil); err != nil {
+		return err
+	}
+
+	if len(conflicts) == 0 {
+		rt.sha, rt.base = snap.sha, remote
+		rt.synced(file, len(snap.content))
+	}
+
+	s.Logger().Info("已合并远端表的变更",
+		zap.String("table", tableName), zap.String("sha", snap.sha), zap.Strings("conflicts", conflicts))
+	return nil
+}
+
+// applyRemote 用合并了远端修改的 merged 替换内存表 t（data 是其 map 指针），
+// 并把与替换前的 local 不同的记录作为远端变化发布，conflicts 中的记录标记为冲突。调用方必须持有写锁
+func (s *GitHubStore) applyRemote(t table, tableName string, data any, local, merged map[string]json.RawMessage, conflicts []string) error {
+	diff := diffRecords(tableName, local, merged)
+	changes, err := t.track(append(diff.updated, diff.deleted...), func() error {
+		return setRecords(data, merged)
+	})
+	if err != nil {
+		return fmt.Errorf("应用合并结果失败: %v", err)
+	}
+
+	var remote []Change
+	for _, c := range changes {
+		// 只是 JSON 编码不同的记录不算变化
+		if c.Type == ChangeUpdate && reflect.DeepEqual(c.Before, c.After) {
+			continue
+		}
+		c.Remote = true
+		c.Conflict = slices.Contains(conflicts, c.ID)
+		remote = append(remote, c)
+	}
+	s.feed.publish(remote...)
+	return nil
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubStore_Reload(t *testing.T) {
+	fake := newFakeGitHub(t)
+	kept := Product{ID: "p-1", Name: "Kept"}
+	edited := Product{ID: "p-2", Name: "Before"}
+	fake.setTable(t, "tables/products.json", map[string]Product{kept.ID: kept, edited.ID: edited})
+
+	store := fake.newStore(t)
+	_, err := store.GetProducts(t.Context())
+	require.NoError(t, err)
+
+	// 运维直接修改数据仓库
+	edited.Name = "After"
+	added := Product{ID: "p-3", Name: "Added"}
+	fake.setTable(t, "tables/products.json", map[string]Product{kept.ID: kept, edited.ID: edited, added.ID: added})
+
+	// 本地尚未提交的写入
+	unsaved := Product{ID: "p-4", Name: "Unsaved"}
+	store.mu.Lock()
+	store.products.rows[unsaved.ID] = unsaved
+	store.mu.Unlock()
+
+	require.NoError(t, store.Reload(t.Context(), "products"))
+
+	products, err := store.GetProducts(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []Product{added, edited, kept, unsaved}, products)
+
+	// 本地写入在下次提交时推送到远端
+	require.NoError(t, store.UpdateProduct(t.Context(), unsaved))
+	require.NoError(t, store.Flush(context.Background()))
+
+	var remote map[string]Product
+	fake.table(t, "tables/products.json", &remote)
+	assert.Len(t, remote, 4)
+}
+
+func TestGitHubStore_RefreshUsesETag(t *testing.T) {
+	fake := newFakeGitHub(t)
+	fake.setTable(t, "tables/users.json", map[string]User{})
+
+	store := fake.newStore(t)
+	_, err := store.Get(t.Context(), "missing")
+	require.Error(t, err)
+
+	require.NoError(t, store.refreshTable(t.Context(), "users", true))
+	require.NoError(t, store.refreshTable(t.Context(), "users", true))
+	assert.Equal(t, 2, fake.notModified)
+
+	user := User{ID: "user-1", Username: "Remote"}
+	fake.setTable(t, "tables/users.json", map[string]User{user.ID: user})
+
+	require.NoError(t, store.refreshTable(t.Context(), "users", true))
+	got, err := store.Get(t.Context(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, user, got)
+}
@@ -2,14 +2,19 @@ package storage
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
 
 	"github.com/Axpz/store/internal/config"
+	"github.com/Axpz/store/internal/pkg/writebehind"
 	"github.com/google/go-github/v45/github"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"go.uber.org/zap"
 
 	"golang.org/x/oauth2"
 )
@@ -17,8 +22,22 @@ import (
 // GitHubStore 实现使用 GitHub 作为存储
 type GitHubStore struct {
 	client *github.Client
-
-	Store
+	// remote 记录每张表最近一次与远端同步的版本
+	remote map[string]*remoteTable
+	// stopRefresh 停止后台轮询
+	stopRefresh context.CancelFunc
+	// queue 按表合并写入，在后台提交到数据仓库
+	queue *writebehind.Queue
+	// commitMu 串行化写回、迁移、重新加密和重新压缩的提交，提交期间不持有 mu
+	commitMu sync.Mutex
+	// keys 加密表文件，nil 表示不加密
+	keys *keyring
+	// compression 是写入表文件的压缩方式，空表示不压缩
+	compression string
+	// history 缓存 History 和 GetAt 读到的历史版本
+	history historyCache
+
+	*Store
 }
 
 // NewGitHubStore 创建一个新的 GitHub 存储
@@ -33,268 +52,337 @@ func NewGitHubStore(cfg *config.Config) (StoreInterface, error) {
 	// 创建 GitHub 客户端
 	client := github.NewClient(tc)
 
-	store := &GitHubStore{
-		client: client,
-		Store:  NewStore(cfg),
-	}
-
-	return store, nil
+	return newGitHubStore(cfg, client)
 }
 
-// Create 创建新用户
-func (s *GitHubStore) Create(user User) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	// 确保用户表已加载
-	if err := s.loadUsers(); err != nil {
-		return err
+func newGitHubStore(cfg *config.Config, client *github.Client) (*GitHubStore, error) {
+	keys, err := newKeyring(cfg.Storage.Encryption)
+	if err != nil {
+		return nil, err
 	}
 
-	if _, exists := s.users[user.ID]; exists {
-		return status.Error(codes.AlreadyExists, "user already exists")
+	store := &GitHubStore{
+		client:      client,
+		remote:      make(map[string]*remoteTable),
+		stopRefresh: func() {},
+		keys:        keys,
+		compression: tableCompression(cfg.Storage),
 	}
+	store.Store = NewStore(cfg, store, storageTimeout(cfg, defaultGitHubTimeout))
 
-	s.users[user.ID] = user
-	return s.saveUsers()
-}
-
-// Get 获取用户
-func (s *GitHubStore) Get(id string) (User, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	var result User
-
-	// 确保用户表已加载
-	if err := s.loadUsers(); err != nil {
-		return result, err
+	interval := cfg.GitHub.FlushInterval
+	if interval <= 0 {
+		interval = defaultFlushInterval
 	}
+	store.queue = writebehind.NewBatch(store.flushTables, writebehind.Options{
+		Interval: interval,
+		OnError: func(tableName string, err error) {
+			store.Logger().Error("提交表文件失败，稍后重试",
+				zap.String("table", tableName), zap.Error(err))
+		},
+	})
 
-	result, exists := s.users[id]
-	if !exists {
-		return result, status.Error(codes.NotFound, "user not found")
+	// 定期检查数据仓库中被直接修改的表
+	if interval := cfg.GitHub.RefreshInterval; interval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		store.stopRefresh = cancel
+		go store.refreshLoop(ctx, interval)
 	}
 
-	return result, nil
+	return store, nil
 }
 
-// Update 更新用户
-func (s *GitHubStore) Update(user User) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	// 确保用户表已加载
-	if err := s.loadUsers(); err != nil {
-		return err
+// loadTable 加载指定表的数据，并记录远端版本用于后续的冲突检测
+func (s *GitHubStore) loadTable(ctx context.Context, tableName string, data any) error {
+	if s.recordLayout() {
+		return s.loadRecords(ctx, tableName, data)
 	}
 
-	if _, exists := s.users[user.ID]; !exists {
-		return status.Error(codes.NotFound, "user not found")
+	snap, err := s.fetchTable(ctx, tableName, "")
+	if err != nil {
+		return err
 	}
 
-	s.users[user.ID] = user
-	return s.saveUsers()
-}
-
-// Delete 删除用户
-func (s *GitHubStore) Delete(id string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	// 确保用户表已加载
-	if err := s.loadUsers(); err != nil {
+	file, records, err := snap.decode(tableName, s.keys)
+	if err != nil {
 		return err
 	}
 
-	if _, exists := s.users[id]; !exists {
-		return status.Error(codes.NotFound, "user not found")
+	// 解析 JSON
+	if err := json.Unmarshal(file.records, data); err != nil {
+		return fmt.Errorf("解析 JSON 失败: %v", err)
 	}
 
-	delete(s.users, id)
-	return s.saveUsers()
+	rt := &remoteTable{sha: snap.sha, etag: snap.etag, base: records}
+	rt.synced(file, len(snap.content))
+	s.remote[tableName] = rt
+	return nil
 }
 
-// CreateComment 创建新评论
-func (s *GitHubStore) CreateComment(comment Comment) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// tableSnapshot 表示某一时刻的远端表文件
+type tableSnapshot struct {
+	sha     string
+	etag    string
+	content []byte
+}
 
-	// 确保评论表已加载
-	if err := s.loadComments(); err != nil {
-		return err
+// decode 解密并解析表文件、升级到当前版本，返回文件和规范化的记录
+func (snap *tableSnapshot) decode(tableName string, keys *keyring) (*tableFile, map[string]json.RawMessage, error) {
+	file, err := keys.decodeTableFile(tableName, snap.content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析 JSON 失败: %w", err)
 	}
-
-	if _, exists := s.comments[comment.ID]; exists {
-		return status.Error(codes.AlreadyExists, "comment already exists")
+	records, err := decodeRecords(file.records)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析 JSON 失败: %v", err)
 	}
-
-	s.comments[comment.ID] = comment
-	return s.saveComments()
+	return file, records, nil
 }
 
-// GetComment 获取评论
-func (s *GitHubStore) GetComment(id string) (Comment, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// errNotModified 表示条件请求命中，远端表文件没有变化
+var errNotModified = errors.New("table not modified")
 
-	var result Comment
+// fetchTable 读取远端表文件。etag 非空时发送条件请求，
+// 文件未变化则返回 errNotModified（304 不消耗 API 速率配额）。
+func (s *GitHubStore) fetchTable(ctx context.Context, tableName, etag string) (*tableSnapshot, error) {
+	return s.fetchContents(ctx, s.config.GetTablePath(tableName), s.config.GitHub.Repo.Branch, etag)
+}
 
-	// 确保评论表已加载
-	if err := s.loadComments(); err != nil {
-		return result, err
+// fetchContents 通过 contents API 读取 ref（分支名或提交 SHA）中路径为 p 的文件。
+// 超过 1 MB 的文件 contents API 不返回内容，改为按 blob SHA 读取。
+func (s *GitHubStore) fetchContents(ctx context.Context, p, ref, etag string) (*tableSnapshot, error) {
+	u := fmt.Sprintf("repos/%s/%s/contents/%s?ref=%s",
+		s.config.GitHub.Repo.Owner,
+		s.config.GitHub.Repo.Name,
+		(&url.URL{Path: p}).String(),
+		url.QueryEscape(ref),
+	)
+	req, err := s.client.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("构建请求失败: %v", err)
 	}
-
-	comment, exists := s.comments[id]
-	if !exists {
-		return result, status.Error(codes.NotFound, "comment not found")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
 	}
 
-	result = comment
-	return result, nil
-}
-
-// UpdateComment 更新评论
-func (s *GitHubStore) UpdateComment(comment Comment) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	var content github.RepositoryContent
+	resp, err := s.client.Do(ctx, req, &content)
+	if resp != nil && resp.StatusCode == http.StatusNotModified {
+		return nil, errNotModified
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取文件内容失败: %w", err)
+	}
 
-	// 确保评论表已加载
-	if err := s.loadComments(); err != nil {
-		return err
+	snap := &tableSnapshot{sha: content.GetSHA(), etag: resp.Header.Get("ETag")}
+	if content.GetEncoding() == "none" {
+		if snap.content, err = s.readBlob(ctx, snap.sha); err != nil {
+			return nil, fmt.Errorf("获取文件内容失败: %w", err)
+		}
+		return snap, nil
 	}
 
-	if _, exists := s.comments[comment.ID]; !exists {
-		return status.Error(codes.NotFound, "comment not found")
+	// 解码 base64 内容
+	decoded, err := content.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("解码文件内容失败: %v", err)
 	}
+	snap.content = []byte(decoded)
+	return snap, nil
+}
 
-	s.comments[comment.ID] = comment
-	return s.saveComments()
+// beforePut 写入只修改内存表，提交由写回队列完成
+func (s *GitHubStore) beforePut(ctx context.Context, tableName, id string, value any) error {
+	return nil
 }
 
-// DeleteComment 删除评论
-func (s *GitHubStore) DeleteComment(id string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// beforeDelete 删除只修改内存表，提交由写回队列完成
+func (s *GitHubStore) beforeDelete(ctx context.Context, tableName, id string) error {
+	return nil
+}
 
-	// 确保评论表已加载
-	if err := s.loadComments(); err != nil {
-		return err
-	}
+// saveTable 将表标记为待提交，由写回队列在后台合并提交
+func (s *GitHubStore) saveTable(ctx context.Context, tableName string, data any) error {
+	s.queue.MarkDirty(tableName)
+	return nil
+}
 
-	if _, exists := s.comments[id]; !exists {
-		return status.Error(codes.NotFound, "comment not found")
-	}
+// Flush 同步提交所有待提交的表
+func (s *GitHubStore) Flush(ctx context.Context) error {
+	return s.queue.Flush(ctx)
+}
 
-	delete(s.comments, id)
-	return s.saveComments()
+// Close 停止后台轮询和写回，提交剩余的修改并关闭 Watch 通道
+func (s *GitHubStore) Close() error {
+	s.stopRefresh()
+	defer s.feed.close()
+	return s.queue.Close(context.Background())
 }
 
-// loadTable 加载指定表的数据
-func (s *GitHubStore) loadTable(tableName string, data any) error {
-	content, _, _, err := s.client.Repositories.GetContents(
-		s.ctx,
-		s.config.GitHub.Repo.Owner,
-		s.config.GitHub.Repo.Name,
-		s.config.GetTablePath(tableName),
-		&github.RepositoryContentGetOptions{
-			Ref: s.config.GitHub.Repo.Branch,
-		},
-	)
-	if err != nil {
-		return fmt.Errorf("获取文件内容失败: %v", err)
-	}
+// Migrate 把版本落后的远端表文件重写为当前版本，每张表一个提交。
+// dryRun 时只读取远端表文件，不做任何提交。
+func (s *GitHubStore) Migrate(ctx context.Context, dryRun bool) ([]MigrationReport, error) {
+	var reports []MigrationReport
+	for _, tableName := range TableNames {
+		summary, err := s.inspectTable(ctx, tableName)
+		if err != nil {
+			return nil, err
+		}
+		if summary == nil {
+			continue
+		}
 
-	// 解码 base64 内容
-	decoded, err := base64.StdEncoding.DecodeString(*content.Content)
-	if err != nil {
-		return fmt.Errorf("解码文件内容失败: %v", err)
-	}
+		current := SchemaVersion(tableName)
+		reports = append(reports, MigrationReport{
+			Table: tableName, From: summary.version, To: current, Records: summary.records, Changed: summary.changed,
+		})
+		if dryRun || summary.version >= current {
+			continue
+		}
 
-	// 解析 JSON
-	if err := json.Unmarshal(decoded, data); err != nil {
-		return fmt.Errorf("解析 JSON 失败: %v", err)
+		if err := s.loadForCommit(ctx, tableName); err != nil {
+			return nil, err
+		}
+		if err := s.commitTable(ctx, tableName); err != nil {
+			return nil, err
+		}
 	}
-
-	return nil
+	return reports, nil
 }
 
-// saveTable 保存指定表的数据
-func (s *GitHubStore) saveTable(tableName string, data any) (err error) {
-	doSave := func() error {
-		// 序列化为 JSON
-		jsonData, err := json.MarshalIndent(data, "", "  ")
+// Reencrypt 用当前密钥重写全部远端表文件，每张表一个提交
+func (s *GitHubStore) Reencrypt(ctx context.Context) ([]ReencryptReport, error) {
+	var reports []ReencryptReport
+	for _, tableName := range TableNames {
+		summary, err := s.inspectTable(ctx, tableName)
 		if err != nil {
-			return fmt.Errorf("序列化 JSON 失败: %v", err)
+			return nil, err
 		}
-
-		// 获取当前文件的 SHA
-		content, _, _, err := s.client.Repositories.GetContents(
-			s.ctx,
-			s.config.GitHub.Repo.Owner,
-			s.config.GitHub.Repo.Name,
-			s.config.GetTablePath(tableName),
-			&github.RepositoryContentGetOptions{
-				Ref: s.config.GitHub.Repo.Branch,
-			},
-		)
-		if err != nil {
-			return fmt.Errorf("获取文件 SHA 失败: %v", err)
+		if summary == nil {
+			continue
 		}
 
-		// 创建提交
-		opts := &github.RepositoryContentFileOptions{
-			Message: github.String(fmt.Sprintf("Update %s table", tableName)),
-			Content: jsonData,
-			SHA:     content.SHA,
-			Branch:  github.String(s.config.GitHub.Repo.Branch),
+		if err := s.loadForCommit(ctx, tableName); err != nil {
+			return nil, err
+		}
+		if err := s.commitTable(ctx, tableName); err != nil {
+			return nil, err
 		}
+		reports = append(reports, ReencryptReport{
+			Table: tableName, From: summary.keyID, To: s.keys.primaryID(), Records: summary.records,
+		})
+	}
+	return reports, nil
+}
 
-		_, _, err = s.client.Repositories.CreateFile(
-			s.ctx,
-			s.config.GitHub.Repo.Owner,
-			s.config.GitHub.Repo.Name,
-			s.config.GetTablePath(tableName),
-			opts,
-		)
+// Recompress 按当前压缩配置重写全部远端表文件，所有表合并为一个提交
+func (s *GitHubStore) Recompress(ctx context.Context) ([]RecompressReport, error) {
+	if s.recordLayout() {
+		return nil, fmt.Errorf("records 布局的记录文件不压缩: %w", ErrNotSupported)
+	}
+
+	var (
+		reports []RecompressReport
+		tables  []string
+	)
+	for _, tableName := range TableNames {
+		summary, err := s.inspectTable(ctx, tableName)
 		if err != nil {
-			return fmt.Errorf("创建文件失败: %v", err)
+			return nil, err
+		}
+		if summary == nil {
+			continue
 		}
 
-		return nil
+		if err := s.loadForCommit(ctx, tableName); err != nil {
+			return nil, err
+		}
+		tables = append(tables, tableName)
+		reports = append(reports, RecompressReport{
+			Table: tableName, From: summary.compression, To: s.compression, Records: summary.records, Before: summary.size,
+		})
 	}
 
-	return s.throttlesaver.RequestMustSave(doSave)
-}
+	if err := s.commitTables(ctx, tables); err != nil {
+		return nil, err
+	}
 
-func (s *GitHubStore) loadUsers() error {
-	if s.loaded["users"] {
-		return nil
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := range reports {
+		reports[i].After = s.remote[reports[i].Table].size
 	}
+	return reports, nil
+}
 
-	if err := s.loadTable("users", &s.users); err != nil {
-		return fmt.Errorf("加载用户表失败: %v", err)
+// loadForCommit 在写锁内加载表，使之后的提交以远端当前版本为基准
+func (s *GitHubStore) loadForCommit(ctx context.Context, tableName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.table(tableName)
+	if err != nil {
+		return err
 	}
-	s.loaded["users"] = true
-	return nil
+	return t.ensureLoaded(ctx)
 }
 
-func (s *GitHubStore) saveUsers() error {
-	return s.saveTable("users", s.users)
+// remoteSummary 是远端一张表的概况，用于迁移、重新加密和重新压缩的报告
+type remoteSummary struct {
+	// version 是记录的最低 schema 版本
+	version int
+	// keyID 是加密记录的密钥，records 布局下记录使用不同密钥时为 mixed
+	keyID string
+	// compression 是表文件的压缩方式，size 是表文件的字节数，只用于 file 布局
+	compression string
+	size        int
+	records     int
+	// changed 是读取时迁移修改过的记录 ID
+	changed []string
 }
 
-func (s *GitHubStore) loadComments() error {
-	if s.loaded["comments"] {
-		return nil
+// inspectTable 读取远端表的概况，远端没有该表时返回 nil
+func (s *GitHubStore) inspectTable(ctx context.Context, tableName string) (*remoteSummary, error) {
+	if s.recordLayout() {
+		return s.inspectRecords(ctx, tableName)
 	}
 
-	if err := s.loadTable("comments", &s.comments); err != nil {
-		return fmt.Errorf("加载用户表失败: %v", err)
+	snap, err := s.fetchTable(ctx, tableName, "")
+	if isNotFoundResponse(err) {
+		return nil, nil
 	}
-	s.loaded["comments"] = true
-	return nil
+	if err != nil {
+		return nil, err
+	}
+	file, records, err := snap.decode(tableName, s.keys)
+	if err != nil {
+		return nil, fmt.Errorf("表 %s: %w", tableName, err)
+	}
+	return &remoteSummary{
+		version: file.version, keyID: file.keyID, compression: file.compression, size: len(snap.content),
+		records: len(records), changed: file.changed,
+	}, nil
+}
+
+// WriteStats 返回写回队列的指标
+func (s *GitHubStore) WriteStats() writebehind.Stats {
+	return s.queue.Stats()
+}
+
+// defaultFlushInterval 默认的写回合并窗口
+const defaultFlushInterval = 10 * time.Second
+
+// maxCommitRetries 提交冲突时的最大重试次数
+const maxCommitRetries = 5
+
+// commitBackoff 返回第 attempt 次重试前的等待时间（指数退避加随机抖动）
+var commitBackoff = func(attempt int) time.Duration {
+	base := 200 * time.Millisecond << attempt
+	return base + time.Duration(rand.Int63n(int64(base)))
 }
 
-func (s *GitHubStore) saveComments() error {
-	return s.saveTable("comments", s.comments)
+// isNotFoundResponse 判断错误是否为文件不存在导致的 404
+func isNotFoundResponse(err error) bool {
+	var ghErr *github.ErrorResponse
+	return errors.As(err, &ghErr) && ghErr.Response != nil && ghErr.Response.StatusCode == http.StatusNotFound
 }
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
@@ -8,10 +9,11 @@ import (
 
 	"github.com/Axpz/store/internal/config"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // setupGitHubTestStore 设置测试环境
-func setupGitHubTestStore() (StoreInterface, error) {
+func setupGitHubTestStore(t *testing.T) (StoreInterface, error) {
 	// 创建配置
 	cfg := &config.Config{
 		GitHub: config.GitHubConfig{
@@ -40,10 +42,11 @@ func setupGitHubTestStore() (StoreInterface, error) {
 
 	// 初始化用户表
 	initialUsers := map[string]User{}
-	store.(*GitHubStore).users = initialUsers
+	store.(*GitHubStore).users.rows = initialUsers
 
 	// 保存初始用户表
-	err = store.(*GitHubStore).saveUsers()
+	require.NoError(t, store.(*GitHubStore).saveTable(context.Background(), "users", nil))
+	err = store.Flush(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("保存初始用户表失败: %v", err)
 	}
@@ -52,7 +55,7 @@ func setupGitHubTestStore() (StoreInterface, error) {
 }
 
 func TestGitHubStore_UserCRUD(t *testing.T) {
-	store, err := setupGitHubTestStore()
+	store, err := setupGitHubTestStore(t)
 	if err != nil {
 		t.Fatalf("设置测试环境失败: %v", err)
 	}
@@ -66,28 +69,28 @@ func TestGitHubStore_UserCRUD(t *testing.T) {
 		Created:  time.Now().Unix(),
 		Updated:  time.Now().Unix(),
 	}
-	err = store.Create(user)
+	err = store.Create(t.Context(), user)
 	assert.NoError(t, err)
 
 	// 测试获取用户
-	got, err := store.Get(user.ID)
+	got, err := store.Get(t.Context(), user.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, user, got)
 
 	// 测试更新用户
 	user.Username = "Updated User"
-	err = store.Update(user)
+	err = store.Update(t.Context(), user)
 	assert.NoError(t, err)
 
-	got, err = store.Get(user.ID)
+	got, err = store.Get(t.Context(), user.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, user, got)
 
 	// 测试删除用户
-	err = store.Delete(user.ID)
+	err = store.Delete(t.Context(), user.ID)
 	assert.NoError(t, err)
 
-	_, err = store.Get(user.ID)
+	_, err = store.Get(t.Context(), user.ID)
 	assert.Error(t, err)
 }
 
@@ -107,7 +110,7 @@ func TestGitHubStore_UserCRUD(t *testing.T) {
 // 		Created: time.Now().Unix(),
 // 		Updated: time.Now().Unix(),
 // 	}
-// 	err := store.CreateComment(comment)
+// 	err := store.CreateComment(t.Context(), comment)
 // 	assert.NoError(t, err)
 
 // 	// 设置模拟行为 - 获取评论
@@ -119,7 +122,7 @@ func TestGitHubStore_UserCRUD(t *testing.T) {
 // 	mockClient.On("GetContents", mock.Anything, "owner", "repo", "tables/comments.json", mock.Anything).Return(nil, nil, nil)
 
 // 	// 测试获取评论
-// 	got, err := store.GetComment(comment.ID)
+// 	got, err := store.GetComment(t.Context(), comment.ID)
 // 	assert.NoError(t, err)
 // 	assert.Equal(t, comment, got)
 
@@ -128,7 +131,7 @@ func TestGitHubStore_UserCRUD(t *testing.T) {
 
 // 	// 测试更新评论
 // 	comment.Content = "Updated Comment"
-// 	err = store.UpdateComment(comment)
+// 	err = store.UpdateComment(t.Context(), comment)
 // 	assert.NoError(t, err)
 
 // 	// 设置模拟行为 - 获取更新后的评论
@@ -137,7 +140,7 @@ func TestGitHubStore_UserCRUD(t *testing.T) {
 // 	mockClient.store["tables/comments.json"] = jsonData
 // 	mockClient.On("GetContents", mock.Anything, "owner", "repo", "tables/comments.json", mock.Anything).Return(nil, nil, nil)
 
-// 	got, err = store.GetComment(comment.ID)
+// 	got, err = store.GetComment(t.Context(), comment.ID)
 // 	assert.NoError(t, err)
 // 	assert.Equal(t, comment, got)
 
@@ -145,7 +148,7 @@ func TestGitHubStore_UserCRUD(t *testing.T) {
 // 	mockClient.On("CreateFile", mock.Anything, "owner", "repo", "tables/comments.json", mock.Anything).Return(nil, nil, nil)
 
 // 	// 测试删除评论
-// 	err = store.DeleteComment(comment.ID)
+// 	err = store.DeleteComment(t.Context(), comment.ID)
 // 	assert.NoError(t, err)
 
 // 	// 设置模拟行为 - 获取不存在的评论
@@ -154,7 +157,7 @@ func TestGitHubStore_UserCRUD(t *testing.T) {
 // 	mockClient.store["tables/comments.json"] = jsonData
 // 	mockClient.On("GetContents", mock.Anything, "owner", "repo", "tables/comments.json", mock.Anything).Return(nil, nil, nil)
 
-// 	_, err = store.GetComment(comment.ID)
+// 	_, err = store.GetComment(t.Context(), comment.ID)
 // 	assert.Error(t, err)
 // }
 
@@ -175,7 +178,7 @@ func TestGitHubStore_UserCRUD(t *testing.T) {
 // 		Created:  time.Now().Unix(),
 // 		Updated:  time.Now().Unix(),
 // 	}
-// 	err := store.Create(user)
+// 	err := store.Create(t.Context(), user)
 // 	assert.NoError(t, err)
 
 // 	// 设置模拟行为 - 获取用户
@@ -191,12 +194,12 @@ func TestGitHubStore_UserCRUD(t *testing.T) {
 // 	for i := 0; i < 10; i++ {
 // 		go func() {
 // 			// 读取用户
-// 			_, err := store.Get(user.ID)
+// 			_, err := store.Get(t.Context(), user.ID)
 // 			assert.NoError(t, err)
 
 // 			// 更新用户
 // 			user.Username = "Updated User"
-// 			err = store.Update(user)
+// 			err = store.Update(t.Context(), user)
 // 			assert.NoError(t, err)
 
 // 			done <- true
@@ -220,15 +223,15 @@ func TestGitHubStore_UserCRUD(t *testing.T) {
 // 	mockClient.store["tables/users.json"] = jsonData
 
 // 	// 测试获取不存在的用户
-// 	_, err := store.Get("non-existent")
+// 	_, err := store.Get(t.Context(), "non-existent")
 // 	assert.Error(t, err)
 
 // 	// 测试更新不存在的用户
-// 	err = store.Update(User{ID: "non-existent"})
+// 	err = store.Update(t.Context(), User{ID: "non-existent"})
 // 	assert.Error(t, err)
 
 // 	// 测试删除不存在的用户
-// 	err = store.Delete("non-existent")
+// 	err = store.Delete(t.Context(), "non-existent")
 // 	assert.Error(t, err)
 
 // 	// 设置模拟行为 - 创建用户
@@ -243,7 +246,7 @@ func TestGitHubStore_UserCRUD(t *testing.T) {
 // 		Created:  time.Now().Unix(),
 // 		Updated:  time.Now().Unix(),
 // 	}
-// 	err = store.Create(user)
+// 	err = store.Create(t.Context(), user)
 // 	assert.NoError(t, err)
 
 // 	// 设置模拟行为 - 获取用户
@@ -252,7 +255,7 @@ func TestGitHubStore_UserCRUD(t *testing.T) {
 // 	mockClient.store["tables/users.json"] = jsonData
 // 	mockClient.On("GetContents", mock.Anything, "owner", "repo", "tables/users.json", mock.Anything).Return(nil, nil, nil)
 
-// 	err = store.Create(user)
+// 	err = store.Create(t.Context(), user)
 // 	assert.Error(t, err)
 
 // 	// 设置模拟行为 - 获取不存在的评论
@@ -262,13 +265,13 @@ func TestGitHubStore_UserCRUD(t *testing.T) {
 // 	mockClient.store["tables/comments.json"] = jsonData
 
 // 	// 测试评论相关错误
-// 	_, err = store.GetComment("non-existent")
+// 	_, err = store.GetComment(t.Context(), "non-existent")
 // 	assert.Error(t, err)
 
-// 	err = store.UpdateComment(Comment{ID: "non-existent"})
+// 	err = store.UpdateComment(t.Context(), Comment{ID: "non-existent"})
 // 	assert.Error(t, err)
 
-// 	err = store.DeleteComment("non-existent")
+// 	err = store.DeleteComment(t.Context(), "non-existent")
 // 	assert.Error(t, err)
 
 // 	// 设置模拟行为 - 创建评论
@@ -281,7 +284,7 @@ func TestGitHubStore_UserCRUD(t *testing.T) {
 // 		Created: time.Now().Unix(),
 // 		Updated: time.Now().Unix(),
 // 	}
-// 	err = store.CreateComment(comment)
+// 	err = store.CreateComment(t.Context(), comment)
 // 	assert.NoError(t, err)
 
 // 	// 设置模拟行为 - 获取评论
@@ -290,7 +293,7 @@ func TestGitHubStore_UserCRUD(t *testing.T) {
 // 	mockClient.store["tables/comments.json"] = jsonData
 // 	mockClient.On("GetContents", mock.Anything, "owner", "repo", "tables/comments.json", mock.Anything).Return(nil, nil, nil)
 
-// 	err = store.CreateComment(comment)
+// 	err = store.CreateComment(t.Context(), comment)
 // 	assert.Error(t, err)
 // }
 
@@ -325,7 +328,7 @@ func TestGitHubStore_UserCRUD(t *testing.T) {
 // 	assert.NoError(t, err)
 
 // 	// 验证数据是否正确加载
-// 	user, err := store.Get("test-user-1")
+// 	user, err := store.Get(t.Context(), "test-user-1")
 // 	assert.NoError(t, err)
 // 	assert.Equal(t, testData["test-user-1"], user)
 // }
@@ -349,7 +352,7 @@ func TestGitHubStore_UserCRUD(t *testing.T) {
 // 	}
 
 // 	// 保存用户
-// 	err := store.Create(user)
+// 	err := store.Create(t.Context(), user)
 // 	assert.NoError(t, err)
 
 // 	// 验证数据是否正确保存
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// commitTx 通过 Git Data API 把事务修改的全部表文件作为一个提交写入数据仓库：
+// 先准备每张表的目录树项，再基于分支最新提交创建 tree 和 commit，最后以非强制方式移动分支。
+// 提交说明与写回队列的批量提交相同，逐表列出变化的记录 ID。
+//
+// 调用时 Tx 持有写锁。同步状态在锁内复制之后释放写锁，与写回提交一样在 commitMu 下访问 GitHub，
+// 其他读写不必等待网络往返；返回前重新加写锁，只在锁内更新同步状态。
+//
+// 任意一张表（records 布局下为任意一条被修改的记录）在远端的版本与事务开始时同步的版本不同时
+// 返回 ErrConflict，事务整体放弃，调用方可以在后台刷新合并远端变更之后重试。
+// 分支在提交期间被其他提交推进（非快进）时，以新的分支头重新检查并提交。
+func (s *GitHubStore) commitTx(ctx context.Context, changes []txChange, tables map[string]any) error {
+	names := make([]string, 0, len(tables))
+	for tableName := range tables {
+		names = append(names, tableName)
+	}
+	sort.Strings(names)
+
+	// 同步状态必须与影子表一致，在释放写锁之前复制
+	synced := make(map[string]remoteTable, len(names))
+	for _, tableName := range names {
+		rt := s.remote[tableName]
+		if rt == nil {
+			return fmt.Errorf("表 %s 尚未加载", tableName)
+		}
+		synced[tableName] = *rt
+	}
+
+	s.mu.Unlock()
+	s.commitMu.Lock()
+	pending, parent, commit, err := s.pushTx(ctx, names, tables, synced)
+	s.mu.Lock()
+	defer s.commitMu.Unlock()
+	if err != nil || commit == "" {
+		return err
+	}
+
+	for _, tc := range pending {
+		// 同步状态在提交期间被后台刷新推进时保留较新的状态
+		if cur := s.remote[tc.name]; cur != nil && cur.sha == synced[tc.name].sha {
+			tc.apply(parent, commit)
+		}
+	}
+	return nil
+}
+
+// pushTx 在锁外准备并创建事务的提交，返回每张表的提交、父提交和新提交的 SHA。
+// 没有需要写入的内容时 commit 为空。调用方持有 commitMu。
+func (s *GitHubStore) pushTx(ctx context.Context, names []string, tables map[string]any, synced map[string]remoteTable) ([]*tableCommit, string, string, error) {
+	var (
+		pending []*tableCommit
+		changed []*tableCommit
+		entries []*github.TreeEntry
+	)
+	for _, tableName := range names {
+		var (
+			tc  *tableCommit
+			err error
+		)
+		rt := synced[tableName]
+		if s.recordLayout() {
+			tc, err = s.txRecords(tableName, rt, tables[tableName])
+		} else {
+			tc, err = s.txTableFile(ctx, tableName, rt, tables[tableName])
+		}
+		if err != nil {
+			return nil, "", "", err
+		}
+		// 内容没有变化的表也检查远端是否被修改，事务读到的版本必须仍是最新的
+		pending = append(pending, tc)
+		if len(tc.entries) > 0 {
+			changed = append(changed, tc)
+			entries = append(entries, tc.entries...)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, "", "", nil
+	}
+
+	message := commitMessage(changed)
+	for attempt := 0; ; attempt++ {
+		parent, tree, err := s.headTree(ctx)
+		if err != nil {
+			return nil, "", "", err
+		}
+
+		var stale []string
+		for _, tc := range pending {
+			if tc.stale(tree) {
+				stale = append(stale, tc.name)
+			}
+		}
+		if len(stale) > 0 {
+			return nil, "", "", fmt.Errorf("表 %s 已在远端被修改: %w", strings.Join(stale, ", "), ErrConflict)
+		}
+
+		commit, err := s.createCommit(ctx, parent, entries, message)
+		if err == nil {
+			return pending, parent.GetSHA(), commit, nil
+		}
+		if !isNotFastForward(err) || attempt >= maxCommitRetries {
+			return nil, "", "", err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, "", "", ctx.Err()
+		case <-time.After(commitBackoff(attempt)):
+		}
+	}
+}
+
+// txTableFile 为 file 布局的表准备整个表文件，rt 是事务开始时同步状态的副本
+func (s *GitHubStore) txTableFile(ctx context.Context, tableName string, rt remoteTable, data any) (*tableCommit, error) {
+	content, err := s.keys.encodeTableFile(tableName, data, "  ", s.compression)
+	if err != nil {
+		return nil, fmt.Errorf("序列化 JSON 失败: %v", err)
+	}
+	records, err := toRecords(data)
+	if err != nil {
+		return nil, fmt.Errorf("序列化 JSON 失败: %v", err)
+	}
+
+	path := s.config.GetTablePath(tableName)
+	sha := gitBlobSHA(content)
+	tc := &tableCommit{
+		name: tableName,
+		diff: diffRecords(tableName, rt.base, records),
+		stale: func(tree *github.Tree) bool {
+			for _, entry := range tree.Entries {
+				if entry.GetPath() == path {
+					return entry.GetSHA() != rt.sha
+				}
+			}
+			return rt.sha != ""
+		},
+		apply: func(parent, commit string) {
+			next := rt
+			next.sha, next.etag, next.base = sha, "", records
+			next.synced(s.writtenFile(tableName), len(content))
+			s.remote[tableName] = &next
+		},
+	}
+	if sha != rt.sha {
+		entry, err := s.fileEntry(ctx, path, content)
+		if err != nil {
+			return nil, err
+		}
+		tc.entries = []*github.TreeEntry{entry}
+	}
+	return tc, nil
+}
+
+// txRecords 为 records 布局的表准备变化的记录文件，rt 是事务开始时同步状态的副本
+func (s *GitHubStore) txRecords(tableName string, rt remoteTable, data any) (*tableCommit, error) {
+	records, err := toRecords(data)
+	if err != nil {
+		return nil, fmt.Errorf("序列化 JSON 失败: %v", err)
+	}
+	entries, written, err := s.recordChanges(tableName, &rt, records)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tableCommit{
+		name:    tableName,
+		entries: entries,
+		diff:    diffRecords(tableName, rt.base, records),
+		stale: func(tree *github.Tree) bool {
+			return len(recordsStale(&rt, written, s.recordsInTree(tableName, tree))) > 0
+		},
+		apply: func(parent, commit string) {
+			next := rt
+			next.applyCommit(parent, commit, records, written)
+			s.remote[tableName] = &next
+		},
+	}, nil
+}
+
+// isNotFastForward 判断错误是否为分支已被推进导致的 422
+func isNotFastForward(err error) bool {
+	var ghErr *github.ErrorResponse
+	return errors.As(err, &ghErr) && ghErr.Response != nil &&
+		ghErr.Response.StatusCode == http.StatusUnprocessableEntity
+}
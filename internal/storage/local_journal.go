@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	// journalFile 预写日志文件名，与表文件位于同一目录
+	journalFile = "journal"
+
+	// defaultCompactEvery 默认每追加多少条日志压缩一次
+	defaultCompactEvery = 100
+
+	journalOpPut    = "put"
+	journalOpDelete = "delete"
+	// journalOpTx 表示一个事务，Entries 是事务中的全部修改
+	journalOpTx = "tx"
+)
+
+// journalEntry 表示一次变更记录
+type journalEntry struct {
+	Table string          `json:"table"`
+	Op    string          `json:"op"`
+	ID    string          `json:"id"`
+	Value json.RawMessage `json:"value,omitempty"`
+
+	Entries []journalEntry `json:"entries,omitempty"`
+}
+
+// journal 是 LocalStore 的追加式预写日志。
+// 每次变更先写入日志并 fsync，之后才修改内存表和表文件；
+// 启动时回放日志，压缩时在表文件全部落盘后清空日志。
+type journal struct {
+	path  string
+	file  *os.File
+	count int
+	// keys 加密每条日志记录，nil 表示不加密
+	keys *keyring
+
+	// pending 保存启动时读出、尚未回放到内存表的记录
+	pending map[string][]journalEntry
+}
+
+// openJournal 打开日志文件并读出全部记录。
+// 末尾被截断的记录（写到一半时崩溃）会被丢弃，文件随之截断到最后一条完整记录。
+func openJournal(path string, keys *keyring) (*journal, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开日志文件失败: %v", err)
+	}
+
+	entries, validSize, err := readJournal(file, keys)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if err := file.Truncate(validSize); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("截断日志文件失败: %v", err)
+	}
+	if _, err := file.Seek(validSize, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("定位日志文件失败: %v", err)
+	}
+
+	j := &journal{
+		path:    path,
+		file:    file,
+		count:   len(entries),
+		keys:    keys,
+		pending: make(map[string][]journalEntry),
+	}
+	for _, entry := range entries {
+		for _, e := range entry.flatten() {
+			j.pending[e.Table] = append(j.pending[e.Table], e)
+		}
+	}
+
+	return j, nil
+}
+
+// readJournal 读取并解密日志记录，返回完整记录及其占用的字节数
+func readJournal(r io.Reader, keys *keyring) ([]journalEntry, int64, error) {
+	var (
+		entries []journalEntry
+		offset  int64
+	)
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// 没有换行符的尾部是未写完的记录，直接丢弃
+			return entries, offset, nil
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("读取日志文件失败: %v", err)
+		}
+
+		plaintext, _, err := keys.open(journalAAD, bytes.TrimSpace(line))
+		if err != nil {
+			// 最后一条记录认证失败同样视为写入中断；缺少密钥是配置错误，不能当作中断丢弃
+			if lastLine(reader) && !errors.Is(err, ErrNoEncryptionKey) {
+				return entries, offset, nil
+			}
+			return nil, 0, fmt.Errorf("日志文件在偏移 %d 处解密失败: %w", offset, err)
+		}
+
+		var entry journalEntry
+		if err := json.Unmarshal(plaintext, &entry); err != nil {
+			if lastLine(reader) {
+				// 最后一条记录损坏，视为写入中断
+				return entries, offset, nil
+			}
+			return nil, 0, fmt.Errorf("日志文件在偏移 %d 处损坏: %v", offset, err)
+		}
+
+		entries = append(entries, entry)
+		offset += int64(len(line))
+	}
+}
+
+// lastLine 判断刚读出的一行是否是日志的最后一行
+func lastLine(reader *bufio.Reader) bool {
+	_, err := reader.Peek(1)
+	return errors.Is(err, io.EOF)
+}
+
+// append 追加记录并 fsync，返回后即视为写入已确认
+func (j *journal) append(entries ...journalEntry) error {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("序列化日志记录失败: %v", err)
+		}
+		if data, err = j.keys.seal(journalAAD, data); err != nil {
+			return fmt.Errorf("加密日志记录失败: %v", err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	// 写入或同步失败时截断到写入之前，不在日志中间留下半条记录
+	offset, err := j.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("定位日志文件失败: %v", err)
+	}
+	if _, err := j.file.Write(buf.Bytes()); err != nil {
+		return j.rewind(offset, fmt.Errorf("写入日志文件失败: %v", err))
+	}
+	if err := j.file.Sync(); err != nil {
+		return j.rewind(offset, fmt.Errorf("同步日志文件失败: %v", err))
+	}
+
+	j.count += len(entries)
+	return nil
+}
+
+// rewind 把日志文件截断到 offset 并定位到末尾，返回 cause 以及截断失败的错误
+func (j *journal) rewind(offset int64, cause error) error {
+	if err := j.file.Truncate(offset); err != nil {
+		return errors.Join(cause, fmt.Errorf("截断日志文件失败: %v", err))
+	}
+	if _, err := j.file.Seek(offset, io.SeekStart); err != nil {
+		return errors.Join(cause, fmt.Errorf("定位日志文件失败: %v", err))
+	}
+	return cause
+}
+
+// put 记录一次写入
+func (j *journal) put(table, id string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("序列化记录失败: %v", err)
+	}
+	return j.append(journalEntry{Table: table, Op: journalOpPut, ID: id, Value: data})
+}
+
+// delete 记录一次删除
+func (j *journal) delete(table, id string) error {
+	return j.append(journalEntry{Table: table, Op: journalOpDelete, ID: id})
+}
+
+// tx 把事务中的全部修改记录为一条日志
+func (j *journal) tx(changes []txChange) error {
+	entries := make([]journalEntry, 0, len(changes))
+	for _, c := range changes {
+		if c.value == nil {
+			entries = append(entries, journalEntry{Table: c.table, Op: journalOpDelete, ID: c.id})
+			continue
+		}
+
+		data, err := json.Marshal(c.value)
+		if err != nil {
+			return fmt.Errorf("序列化记录失败: %v", err)
+		}
+		entries = append(entries, journalEntry{Table: c.table, Op: journalOpPut, ID: c.id, Value: data})
+	}
+
+	return j.append(journalEntry{Op: journalOpTx, Entries: entries})
+}
+
+// flatten 展开事务记录，其他记录原样返回
+func (e journalEntry) flatten() []journalEntry {
+	if e.Op == journalOpTx {
+		return e.Entries
+	}
+	return []journalEntry{e}
+}
+
+// takePending 取出某张表待回放的记录
+func (j *journal) takePending(table string) []journalEntry {
+	entries := j.pending[table]
+	delete(j.pending, table)
+	return entries
+}
+
+// reset 在所有表文件落盘后清空日志
+func (j *journal) reset() error {
+	if err := j.file.Truncate(0); err != nil {
+		return fmt.Errorf("清空日志文件失败: %v", err)
+	}
+	if _, err := j.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("定位日志文件失败: %v", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("同步日志文件失败: %v", err)
+	}
+
+	j.count = 0
+	return nil
+}
+
+// replayJournal 将日志记录应用到内存表，data 指向 map
+func replayJournal(data any, entries []journalEntry) error {
+	records, err := toRecords(data)
+	if err != nil {
+		return fmt.Errorf("序列化 JSON 失败: %v", err)
+	}
+
+	for _, entry := range entries {
+		switch entry.Op {
+		case journalOpPut:
+			value, err := canonicalJSON(entry.Value)
+			if err != nil {
+				return fmt.Errorf("回放日志记录 %s/%s 失败: %v", entry.Table, entry.ID, err)
+			}
+			records[entry.ID] = value
+		case journalOpDelete:
+			delete(records, entry.ID)
+		default:
+			return fmt.Errorf("未知的日志操作: %s", entry.Op)
+		}
+	}
+
+	if err := setRecords(data, records); err != nil {
+		return fmt.Errorf("回放日志失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reopenLocalStore(t *testing.T, dir string) *LocalStore {
+	store, err := NewLocalStore(&config.Config{Storage: config.StorageConfig{Path: dir}})
+	require.NoError(t, err)
+	return store.(*LocalStore)
+}
+
+func TestLocalStore_JournalReplay(t *testing.T) {
+	store, tempDir, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	user := User{ID: "test-user-1", Username: "Test User"}
+	require.NoError(t, store.Create(t.Context(), user))
+
+	// 模拟表文件写入前崩溃：表文件回到旧内容，日志仍在
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "users"), []byte("{}"), 0644))
+
+	reopened := reopenLocalStore(t, tempDir)
+	got, err := reopened.Get(t.Context(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, user, got)
+
+	// 压缩后表文件包含回放的数据，日志被清空
+	require.NoError(t, reopened.compact(t.Context()))
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "users"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), user.ID)
+
+	info, err := os.Stat(filepath.Join(tempDir, journalFile))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+}
+
+func TestLocalStore_TornJournalTail(t *testing.T) {
+	store, tempDir, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	user := User{ID: "test-user-1", Username: "Test User"}
+	require.NoError(t, store.Create(t.Context(), user))
+
+	// 模拟追加日志时崩溃，留下半条记录
+	journalPath := filepath.Join(tempDir, journalFile)
+	f, err := os.OpenFile(journalPath, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"table":"users","op":"put","id":"test-us`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	reopened := reopenLocalStore(t, tempDir)
+	got, err := reopened.Get(t.Context(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, user, got)
+
+	// 截断后可以继续追加
+	user.Username = "Updated User"
+	require.NoError(t, reopened.Update(t.Context(), user))
+
+	again := reopenLocalStore(t, tempDir)
+	got, err = again.Get(t.Context(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, user, got)
+}
+
+func TestLocalStore_JournalCompaction(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewLocalStore(&config.Config{
+		Storage: config.StorageConfig{Path: tempDir, CompactEvery: 3},
+	})
+	require.NoError(t, err)
+
+	for _, id := range []string{"c-1", "c-2", "c-3"} {
+		require.NoError(t, store.CreateComment(t.Context(), Comment{ID: id}))
+	}
+
+	info, err := os.Stat(filepath.Join(tempDir, journalFile))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	reopened := reopenLocalStore(t, tempDir)
+	_, err = reopened.GetComment(t.Context(), "c-3")
+	assert.NoError(t, err)
+}
+
+func TestLocalStore_TornEncryptedJournalTail(t *testing.T) {
+	for name, tear := range map[string]func(line string) string{
+		// 最后一行只写入了一半，换行符已落盘
+		"truncated": func(line string) string { return line[:len(line)/2] },
+		// 最后一行的密文损坏，JSON 仍然完整，认证失败
+		"corrupted": func(line string) string {
+			i := strings.Index(line, `"ciphertext":"`) + len(`"ciphertext":"`)
+			c := byte('A')
+			if line[i] == c {
+				c = 'B'
+			}
+			return line[:i] + string(c) + line[i+1:]
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			key := testKey("k1", 1)
+			first := User{ID: "user-1", Username: "Alice"}
+			second := User{ID: "user-2", Username: "Bob"}
+
+			store := openEncryptedLocalStore(t, dir, key)
+			require.NoError(t, store.Create(t.Context(), first))
+			require.NoError(t, store.Create(t.Context(), second))
+
+			// 模拟追加第二条日志时崩溃：表文件回到旧内容，日志最后一行损坏
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "users"), []byte("{}"), 0644))
+			journalPath := filepath.Join(dir, journalFile)
+			content, err := os.ReadFile(journalPath)
+			require.NoError(t, err)
+			lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+			require.Len(t, lines, 2)
+			lines[1] = tear(lines[1])
+			require.NoError(t, os.WriteFile(journalPath, []byte(strings.Join(lines, "\n")+"\n"), 0644))
+
+			reopened := openEncryptedLocalStore(t, dir, key)
+			got, err := reopened.Get(t.Context(), first.ID)
+			require.NoError(t, err)
+			assert.Equal(t, first, got)
+			_, err = reopened.Get(t.Context(), second.ID)
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			// 缺少密钥仍然是错误，不当作写入中断
+			_, err = NewLocalStore(&config.Config{Storage: config.StorageConfig{Path: dir}})
+			assert.ErrorIs(t, err, ErrNoEncryptionKey)
+		})
+	}
+}
+
+func TestLocalStore_JournalRewindsFailedAppend(t *testing.T) {
+	store, tempDir, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	user := User{ID: "test-user-1", Username: "Test User"}
+	require.NoError(t, store.Create(t.Context(), user))
+
+	// 模拟写入一半后出错：rewind 截断到写入之前，后续追加接在完整记录之后
+	j := store.journal
+	offset, err := j.file.Seek(0, io.SeekCurrent)
+	require.NoError(t, err)
+	_, err = j.file.WriteString(`{"table":"users","op":"put","id":"test-us`)
+	require.NoError(t, err)
+	cause := errors.New("写入日志文件失败")
+	assert.Equal(t, cause, j.rewind(offset, cause))
+
+	info, err := os.Stat(filepath.Join(tempDir, journalFile))
+	require.NoError(t, err)
+	assert.Equal(t, offset, info.Size())
+
+	second := User{ID: "test-user-2", Username: "Second User"}
+	require.NoError(t, store.Create(t.Context(), second))
+	reopened := reopenLocalStore(t, tempDir)
+	for _, want := range []User{user, second} {
+		got, err := reopened.Get(t.Context(), want.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+}
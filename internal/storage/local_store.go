@@ -1,296 +1,396 @@
 package storage
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/Axpz/store/internal/config"
+	"go.uber.org/zap"
 )
 
 // LocalStore 实现本地文件存储
 type LocalStore struct {
-	Store
+	*Store
+
+	journal *journal
+	// keys 加密表文件和日志，nil 表示不加密
+	keys *keyring
+	// compression 是写入表文件的压缩方式，空表示不压缩
+	compression string
+	// dirty 记录表文件落后于日志、需要在压缩时重写的表
+	dirty map[string]bool
 }
 
 // NewLocalStore 创建一个新的本地存储
 func NewLocalStore(cfg *config.Config) (StoreInterface, error) {
-	store := &LocalStore{
-		Store: NewStore(cfg),
+	if err := os.MkdirAll(cfg.Storage.Path, 0755); err != nil {
+		return nil, fmt.Errorf("创建存储目录失败: %v", err)
 	}
 
-	return store, nil
-}
-
-// Create 创建新用户
-func (s *LocalStore) Create(user User) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	keys, err := newKeyring(cfg.Storage.Encryption)
+	if err != nil {
+		return nil, err
+	}
 
-	// 确保用户表已加载
-	if err := s.loadUsers(); err != nil {
-		return err
+	j, err := openJournal(filepath.Join(cfg.Storage.Path, journalFile), keys)
+	if err != nil {
+		return nil, err
 	}
 
-	if _, exists := s.users[user.ID]; exists {
-		return fmt.Errorf("用户已存在")
+	store := &LocalStore{
+		journal:     j,
+		keys:        keys,
+		compression: tableCompression(cfg.Storage),
+		dirty:       make(map[string]bool),
 	}
+	store.Store = NewStore(cfg, store, storageTimeout(cfg, defaultLocalTimeout))
 
-	s.users[user.ID] = user
-	return s.saveUsers()
+	return store, nil
 }
 
-// Get 获取用户
-func (s *LocalStore) Get(id string) (User, error) {
+// Flush 将日志中的变更全部写入表文件并清空日志
+func (s *LocalStore) Flush(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	var result User
-
-	// 确保用户表已加载
-	if err := s.loadUsers(); err != nil {
-		return result, err
-	}
-
-	user, exists := s.users[id]
-	if !exists {
-		return result, fmt.Errorf("用户不存在")
-	}
-
-	result = user
-	return result, nil
+	return s.compact(ctx)
 }
 
-// Update 更新用户
-func (s *LocalStore) Update(user User) error {
+// Close 关闭 Watch 通道，压缩日志并关闭日志文件
+func (s *LocalStore) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if err := s.loadUsers(); err != nil {
+	s.feed.close()
+	if err := s.compact(context.Background()); err != nil {
 		return err
 	}
+	return s.journal.file.Close()
+}
 
-	if _, exists := s.users[user.ID]; !exists {
-		return fmt.Errorf("用户不存在")
+// loadTable 读取表文件，并回放启动时日志中该表尚未落盘的变更
+func (s *LocalStore) loadTable(ctx context.Context, tableName string, data any) error {
+	if err := s.readTable(tableName, data); err != nil {
+		return err
 	}
 
-	s.users[user.ID] = user
-	return s.saveUsers()
-}
-
-// Delete 删除用户
-func (s *LocalStore) Delete(id string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	entries := s.journal.takePending(tableName)
+	if len(entries) == 0 {
+		return nil
+	}
 
-	if err := s.loadUsers(); err != nil {
+	if err := replayJournal(data, entries); err != nil {
 		return err
 	}
 
-	if _, exists := s.users[id]; !exists {
-		return fmt.Errorf("用户不存在")
+	// 表文件落后于日志，压缩时重写
+	s.dirty[tableName] = true
+	return nil
+}
+
+// readTable 读取表文件，文件不存在时创建空表
+func (s *LocalStore) readTable(tableName string, data any) error {
+	// 构建文件路径
+	filePath := filepath.Join(s.config.Storage.Path, tableName)
+
+	// 检查文件是否存在
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		// 如果文件不存在，创建一个空文件
+		emptyData := make(map[string]any)
+		if err := s.writeTable(tableName, emptyData); err != nil {
+			return fmt.Errorf("创建空文件失败: %v", err)
+		}
+		return nil
 	}
 
-	delete(s.users, id)
-	return s.saveUsers()
-}
+	// 读取文件内容
+	fileData, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("读取文件失败: %v", err)
+	}
 
-// CreateOrder 创建新订单
-func (s *LocalStore) CreateOrder(order Order) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	// 解析 JSON，旧版本的记录升级到当前版本；文件在下次写入该表时重写
+	file, err := s.keys.decodeTableFile(tableName, fileData)
+	if err != nil {
+		return fmt.Errorf("解析 JSON 失败: %w", err)
+	}
+	if err := json.Unmarshal(file.records, data); err != nil {
+		return fmt.Errorf("解析 JSON 失败: %v", err)
+	}
 
 	return nil
 }
 
-// GetOrder 获取订单
-func (s *LocalStore) GetOrder(id string) (Order, error) {
-	return Order{}, nil
-}
+// writeTable 原子地写入表文件
+func (s *LocalStore) writeTable(tableName string, data any) error {
+	// 构建文件路径
+	filePath := filepath.Join(s.config.Storage.Path, tableName)
 
-// GetOrdersByUserID 获取用户订单
-func (s *LocalStore) GetOrdersByUserID(userID string) ([]Order, error) {
-	return nil, nil
-}
+	// 序列化为 JSON
+	jsonData, err := s.keys.encodeTableFile(tableName, data, " ", s.compression)
+	if err != nil {
+		return fmt.Errorf("序列化 JSON 失败: %v", err)
+	}
 
-// UpdateOrder 更新订单
-func (s *LocalStore) UpdateOrder(order Order) error {
-	return nil
-}
+	// 写入文件
+	if err := writeFileAtomic(filePath, jsonData, 0644); err != nil {
+		return fmt.Errorf("写入文件失败: %v", err)
+	}
 
-// DeleteOrder 删除订单
-func (s *LocalStore) DeleteOrder(id string) error {
 	return nil
 }
 
-func (s *LocalStore) CreateProduct(product Product) error {
-	return nil
+// beforePut 先把写入记录到日志
+func (s *LocalStore) beforePut(ctx context.Context, tableName, id string, value any) error {
+	return s.journal.put(tableName, id, value)
 }
 
-func (s *LocalStore) GetProduct(id string) (Product, error) {
-	return Product{}, nil
+// beforeDelete 先把删除记录到日志
+func (s *LocalStore) beforeDelete(ctx context.Context, tableName, id string) error {
+	return s.journal.delete(tableName, id)
 }
 
-func (s *LocalStore) GetProducts() ([]Product, error) {
-	return nil, nil
+// commitTx 把事务的全部修改作为一条日志记录写入，只 fsync 一次。
+// 崩溃时这条记录要么完整保留并在启动时整体回放，要么被当作未写完的尾部丢弃。
+func (s *LocalStore) commitTx(ctx context.Context, changes []txChange, tables map[string]any) error {
+	return s.journal.tx(changes)
 }
 
-func (s *LocalStore) UpdateProduct(product Product) error {
-	return nil
-}
+// saveTable 在变更写入日志之后调用，只把表标记为 dirty，不重写表文件。
+// 每次写入只追加日志并 fsync 一次；表文件在日志达到 compact_every 条或 Flush、Close 时的压缩中重写。
+func (s *LocalStore) saveTable(ctx context.Context, tableName string, data any) error {
+	s.dirty[tableName] = true
+
+	if s.journal.count >= s.compactEvery() {
+		if err := s.compact(ctx); err != nil {
+			s.Logger().Warn("压缩日志失败", zap.Error(err))
+		}
+	}
 
-func (s *LocalStore) DeleteProduct(id string) error {
 	return nil
 }
 
-// CreateComment 创建新评论
-func (s *LocalStore) CreateComment(comment Comment) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if err := s.loadComments(); err != nil {
-		return err
+// compact 将所有表文件落盘后清空日志
+func (s *LocalStore) compact(ctx context.Context) error {
+	// 尚未加载的表先加载，加载时会回放并落盘
+	for tableName := range s.journal.pending {
+		t, err := s.table(tableName)
+		if err != nil {
+			return err
+		}
+		if err := t.ensureLoaded(ctx); err != nil {
+			return err
+		}
 	}
 
-	if _, exists := s.comments[comment.ID]; exists {
-		return fmt.Errorf("评论已存在")
+	for tableName := range s.dirty {
+		t, err := s.table(tableName)
+		if err != nil {
+			return err
+		}
+		if err := s.writeTable(tableName, t.data()); err != nil {
+			return err
+		}
+		delete(s.dirty, tableName)
 	}
 
-	s.comments[comment.ID] = comment
-	return s.saveComments()
+	return s.journal.reset()
 }
 
-// GetComment 获取评论
-func (s *LocalStore) GetComment(id string) (Comment, error) {
+// Migrate 把版本落后的表文件重写为当前版本。
+// dryRun 时在数据目录的临时副本上执行，原数据不受影响。
+func (s *LocalStore) Migrate(ctx context.Context, dryRun bool) ([]MigrationReport, error) {
+	if dryRun {
+		return s.migrateCopy(ctx)
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	var result Comment
+	var reports []MigrationReport
+	for _, tableName := range TableNames {
+		content, err := os.ReadFile(filepath.Join(s.config.Storage.Path, tableName))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取文件失败: %v", err)
+		}
 
-	if err := s.loadComments(); err != nil {
-		return result, err
-	}
+		file, err := s.keys.decodeTableFile(tableName, content)
+		if err != nil {
+			return nil, fmt.Errorf("解析表 %s 失败: %w", tableName, err)
+		}
+		report, err := file.report(tableName)
+		if err != nil {
+			return nil, fmt.Errorf("解析表 %s 失败: %v", tableName, err)
+		}
+		reports = append(reports, report)
+		if !file.outdated(tableName) {
+			continue
+		}
 
-	result, exists := s.comments[id]
-	if !exists {
-		return result, fmt.Errorf("评论不存在")
+		t, err := s.table(tableName)
+		if err != nil {
+			return nil, err
+		}
+		if err := t.ensureLoaded(ctx); err != nil {
+			return nil, err
+		}
+		if err := s.writeTable(tableName, t.data()); err != nil {
+			return nil, err
+		}
 	}
-
-	return result, nil
+	return reports, nil
 }
 
-// UpdateComment 更新评论
-func (s *LocalStore) UpdateComment(comment Comment) error {
+// Reencrypt 用当前密钥重写全部表文件，再压缩日志，使日志中旧密钥加密的记录也被清除
+func (s *LocalStore) Reencrypt(ctx context.Context) ([]ReencryptReport, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if err := s.loadComments(); err != nil {
-		return err
-	}
+	var reports []ReencryptReport
+	for _, tableName := range TableNames {
+		content, err := os.ReadFile(filepath.Join(s.config.Storage.Path, tableName))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取文件失败: %v", err)
+		}
 
-	if _, exists := s.comments[comment.ID]; !exists {
-		return fmt.Errorf("评论不存在")
+		file, err := s.keys.decodeTableFile(tableName, content)
+		if err != nil {
+			return nil, fmt.Errorf("解析表 %s 失败: %w", tableName, err)
+		}
+		report, err := file.report(tableName)
+		if err != nil {
+			return nil, fmt.Errorf("解析表 %s 失败: %v", tableName, err)
+		}
+
+		t, err := s.table(tableName)
+		if err != nil {
+			return nil, err
+		}
+		if err := t.ensureLoaded(ctx); err != nil {
+			return nil, err
+		}
+		if err := s.writeTable(tableName, t.data()); err != nil {
+			return nil, err
+		}
+		delete(s.dirty, tableName)
+		reports = append(reports, ReencryptReport{
+			Table: tableName, From: file.keyID, To: s.keys.primaryID(), Records: report.Records,
+		})
 	}
 
-	s.comments[comment.ID] = comment
-	return s.saveComments()
+	if err := s.compact(ctx); err != nil {
+		return nil, err
+	}
+	return reports, nil
 }
 
-// DeleteComment 删除评论
-func (s *LocalStore) DeleteComment(id string) error {
+// Recompress 按当前压缩配置重写全部表文件
+func (s *LocalStore) Recompress(ctx context.Context) ([]RecompressReport, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if err := s.loadComments(); err != nil {
-		return err
-	}
-
-	if _, exists := s.comments[id]; !exists {
-		return fmt.Errorf("评论不存在")
-	}
+	var reports []RecompressReport
+	for _, tableName := range TableNames {
+		filePath := filepath.Join(s.config.Storage.Path, tableName)
+		content, err := os.ReadFile(filePath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取文件失败: %v", err)
+		}
 
-	delete(s.comments, id)
-	return s.saveComments()
-}
+		file, err := s.keys.decodeTableFile(tableName, content)
+		if err != nil {
+			return nil, fmt.Errorf("解析表 %s 失败: %w", tableName, err)
+		}
+		report, err := file.report(tableName)
+		if err != nil {
+			return nil, fmt.Errorf("解析表 %s 失败: %v", tableName, err)
+		}
 
-// loadTable 加载指定表的数据
-func (s *LocalStore) loadTable(tableName string, data any) error {
-	// 构建文件路径
-	filePath := filepath.Join(s.config.Storage.Path, tableName)
+		t, err := s.table(tableName)
+		if err != nil {
+			return nil, err
+		}
+		if err := t.ensureLoaded(ctx); err != nil {
+			return nil, err
+		}
+		if err := s.writeTable(tableName, t.data()); err != nil {
+			return nil, err
+		}
 
-	// 检查文件是否存在
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		// 如果文件不存在，创建一个空文件
-		emptyData := make(map[string]any)
-		if err := s.saveTable(tableName, emptyData); err != nil {
-			return fmt.Errorf("创建空文件失败: %v", err)
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("读取文件失败: %v", err)
 		}
-		return nil
+		reports = append(reports, RecompressReport{
+			Table: tableName, From: file.compression, To: s.compression, Records: report.Records,
+			Before: len(content), After: int(info.Size()),
+		})
 	}
+	return reports, nil
+}
 
-	// 读取文件内容
-	fileData, err := os.ReadFile(filePath)
+// migrateCopy 把数据目录（表文件和日志）复制到临时目录，在副本上执行迁移
+func (s *LocalStore) migrateCopy(ctx context.Context) ([]MigrationReport, error) {
+	dir, err := os.MkdirTemp("", "store-migrate-")
 	if err != nil {
-		return fmt.Errorf("读取文件失败: %v", err)
-	}
-
-	// 解析 JSON
-	if err := json.Unmarshal(fileData, data); err != nil {
-		return fmt.Errorf("解析 JSON 失败: %v", err)
+		return nil, fmt.Errorf("创建临时目录失败: %v", err)
 	}
+	defer os.RemoveAll(dir)
 
-	return nil
-}
-
-// saveTable 保存指定表的数据
-func (s *LocalStore) saveTable(tableName string, data any) error {
-	// 构建文件路径
-	filePath := filepath.Join(s.config.Storage.Path, tableName)
-
-	// 序列化为 JSON
-	jsonData, err := json.MarshalIndent(data, "", " ")
+	s.mu.Lock()
+	err = copyDir(s.config.Storage.Path, dir)
+	s.mu.Unlock()
 	if err != nil {
-		return fmt.Errorf("序列化 JSON 失败: %v", err)
+		return nil, fmt.Errorf("复制数据目录失败: %v", err)
 	}
 
-	// 写入文件
-	if err := os.WriteFile(filePath, jsonData, 0644); err != nil {
-		return fmt.Errorf("写入文件失败: %v", err)
+	cfg := *s.config
+	cfg.Storage.Path = dir
+	replica, err := NewLocalStore(&cfg)
+	if err != nil {
+		return nil, err
 	}
+	defer replica.Close()
 
-	return nil
+	return replica.(*LocalStore).Migrate(ctx, false)
 }
 
-func (s *LocalStore) loadUsers() error {
-	if s.loaded["users"] {
-		return nil
+// copyDir 复制 src 目录下的普通文件到 dst，不递归子目录
+func copyDir(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
 	}
-
-	if err := s.loadTable("users", &s.users); err != nil {
-		return fmt.Errorf("加载用户表失败: %v", err)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(src, entry.Name()))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dst, entry.Name()), data, 0644); err != nil {
+			return err
+		}
 	}
-
-	s.loaded["users"] = true
 	return nil
 }
 
-func (s *LocalStore) saveUsers() error {
-	return s.saveTable("users", s.users)
-}
-
-func (s *LocalStore) loadComments() error {
-	if s.loaded["comments"] {
-		return nil
+func (s *LocalStore) compactEvery() int {
+	if s.config.Storage.CompactEvery > 0 {
+		return s.config.Storage.CompactEvery
 	}
-
-	if err := s.loadTable("comments", &s.comments); err != nil {
-		return fmt.Errorf("加载评论表失败: %v", err)
-	}
-	s.loaded["comments"] = true
-	return nil
-}
-
-func (s *LocalStore) saveComments() error {
-	return s.saveTable("comments", s.comments)
+	return defaultCompactEvery
 }
@@ -46,28 +46,28 @@ func TestLocalStore_UserCRUD(t *testing.T) {
 		ID:       "test-user-1",
 		Username: "Test User",
 	}
-	err := store.Create(user)
+	err := store.Create(t.Context(), user)
 	assert.NoError(t, err)
 
 	// 测试获取用户
-	got, err := store.Get(user.ID)
+	got, err := store.Get(t.Context(), user.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, user, got)
 
 	// // 测试更新用户
 	user.Username = "Updated User"
-	err = store.Update(user)
+	err = store.Update(t.Context(), user)
 	assert.NoError(t, err)
 
-	got, err = store.Get(user.ID)
+	got, err = store.Get(t.Context(), user.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, user, got)
 
 	// 测试删除用户
-	err = store.Delete(user.ID)
+	err = store.Delete(t.Context(), user.ID)
 	assert.NoError(t, err)
 
-	_, err = store.Get(user.ID)
+	_, err = store.Get(t.Context(), user.ID)
 	assert.Error(t, err)
 }
 
@@ -81,31 +81,129 @@ func TestLocalStore_CommentCRUD(t *testing.T) {
 		Content: "Test Comment",
 		UserID:  "test-user-1",
 	}
-	err := store.CreateComment(comment)
+	err := store.CreateComment(t.Context(), comment)
 	assert.NoError(t, err)
 
 	// 测试获取评论
-	got, err := store.GetComment(comment.ID)
+	got, err := store.GetComment(t.Context(), comment.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, comment, got)
 
 	// 测试更新评论
 	comment.Content = "Updated Comment"
-	err = store.UpdateComment(comment)
+	err = store.UpdateComment(t.Context(), comment)
 	assert.NoError(t, err)
 
-	got, err = store.GetComment(comment.ID)
+	got, err = store.GetComment(t.Context(), comment.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, comment, got)
 
 	// 测试删除评论
-	err = store.DeleteComment(comment.ID)
+	err = store.DeleteComment(t.Context(), comment.ID)
 	assert.NoError(t, err)
 
-	_, err = store.GetComment(comment.ID)
+	_, err = store.GetComment(t.Context(), comment.ID)
 	assert.Error(t, err)
 }
 
+func TestLocalStore_OrderCRUD(t *testing.T) {
+	store, _, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	// 测试创建订单
+	order := Order{
+		ID:      "test-order-1",
+		UserID:  "test-user-1",
+		Status:  "pending",
+		Created: 1,
+		Updated: 1,
+	}
+	err := store.CreateOrder(t.Context(), order)
+	assert.NoError(t, err)
+
+	err = store.CreateOrder(t.Context(), order)
+	assert.Error(t, err)
+
+	// 测试获取订单
+	got, err := store.GetOrder(t.Context(), order.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, order, got)
+
+	// 测试按用户查询订单，按更新时间倒序
+	newer := Order{ID: "test-order-2", UserID: "test-user-1", Updated: 2}
+	other := Order{ID: "test-order-3", UserID: "test-user-2", Updated: 3}
+	assert.NoError(t, store.CreateOrder(t.Context(), newer))
+	assert.NoError(t, store.CreateOrder(t.Context(), other))
+
+	orders, err := store.GetOrdersByUserID(t.Context(), "test-user-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []Order{newer, order}, orders)
+
+	// 测试更新订单
+	order.Status = "paid"
+	err = store.UpdateOrder(t.Context(), order)
+	assert.NoError(t, err)
+
+	got, err = store.GetOrder(t.Context(), order.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "paid", got.Status)
+
+	// 测试删除订单
+	err = store.DeleteOrder(t.Context(), order.ID)
+	assert.NoError(t, err)
+
+	_, err = store.GetOrder(t.Context(), order.ID)
+	assert.Error(t, err)
+
+	err = store.UpdateOrder(t.Context(), order)
+	assert.Error(t, err)
+
+	err = store.DeleteOrder(t.Context(), order.ID)
+	assert.Error(t, err)
+}
+
+func TestLocalStore_ProductCRUD(t *testing.T) {
+	store, tempDir, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	// 测试创建商品
+	products := []Product{
+		{ID: "p-2", Name: "Beta", Price: 200},
+		{ID: "p-1", Name: "Alpha", Price: 100},
+	}
+	for _, p := range products {
+		assert.NoError(t, store.CreateProduct(t.Context(), p))
+	}
+	assert.Error(t, store.CreateProduct(t.Context(), products[0]))
+
+	// 测试获取商品列表，按名称排序
+	got, err := store.GetProducts(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, []Product{products[1], products[0]}, got)
+
+	// 测试更新商品
+	products[0].Price = 250
+	assert.NoError(t, store.UpdateProduct(t.Context(), products[0]))
+
+	product, err := store.GetProduct(t.Context(), products[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(250), product.Price)
+
+	// 测试重新打开后数据仍然存在
+	reopened, err := NewLocalStore(&config.Config{Storage: config.StorageConfig{Path: tempDir}})
+	require.NoError(t, err)
+
+	product, err = reopened.GetProduct(t.Context(), products[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, products[0], product)
+
+	// 测试删除商品
+	assert.NoError(t, store.DeleteProduct(t.Context(), products[0].ID))
+	_, err = store.GetProduct(t.Context(), products[0].ID)
+	assert.Error(t, err)
+	assert.Error(t, store.UpdateProduct(t.Context(), products[0]))
+}
+
 func TestLocalStore_ConcurrentAccess(t *testing.T) {
 	store, _, cleanup := setupTestStore(t)
 	defer cleanup()
@@ -115,7 +213,7 @@ func TestLocalStore_ConcurrentAccess(t *testing.T) {
 		ID:       "test-user-1",
 		Username: "Test User",
 	}
-	err := store.Create(user)
+	err := store.Create(t.Context(), user)
 	assert.NoError(t, err)
 
 	// 并发读取和更新
@@ -123,12 +221,12 @@ func TestLocalStore_ConcurrentAccess(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		go func() {
 			// 读取用户
-			_, err := store.Get(user.ID)
+			_, err := store.Get(t.Context(), user.ID)
 			assert.NoError(t, err)
 
 			// 更新用户
 			user.Username = "Updated User"
-			err = store.Update(user)
+			err = store.Update(t.Context(), user)
 			assert.NoError(t, err)
 
 			done <- true
@@ -150,7 +248,7 @@ func TestLocalStore_FileOperations(t *testing.T) {
 		ID:       "test-user-1",
 		Username: "Test User",
 	}
-	err := store.Create(user)
+	err := store.Create(t.Context(), user)
 	assert.NoError(t, err)
 
 	// 验证文件是否存在
@@ -158,16 +256,23 @@ func TestLocalStore_FileOperations(t *testing.T) {
 	_, err = os.Stat(userFile)
 	assert.NoError(t, err)
 
-	// 测试文件内容
+	// 写入只追加日志，表文件在压缩时重写
 	content, err := os.ReadFile(userFile)
 	assert.NoError(t, err)
+	assert.NotContains(t, string(content), user.ID)
+	require.NoError(t, store.Flush(t.Context()))
+
+	// 测试文件内容
+	content, err = os.ReadFile(userFile)
+	assert.NoError(t, err)
 	assert.Contains(t, string(content), user.ID)
 	assert.Contains(t, string(content), user.Username)
 
 	// 测试文件更新
 	user.Username = "Updated User"
-	err = store.Update(user)
+	err = store.Update(t.Context(), user)
 	assert.NoError(t, err)
+	require.NoError(t, store.Flush(t.Context()))
 
 	content, err = os.ReadFile(userFile)
 	assert.NoError(t, err)
@@ -179,15 +284,15 @@ func TestLocalStore_ErrorCases(t *testing.T) {
 	defer cleanup()
 
 	// 测试获取不存在的用户
-	_, err := store.Get("non-existent")
+	_, err := store.Get(t.Context(), "non-existent")
 	assert.Error(t, err)
 
 	// 测试更新不存在的用户
-	err = store.Update(User{ID: "non-existent"})
+	err = store.Update(t.Context(), User{ID: "non-existent"})
 	assert.Error(t, err)
 
 	// 测试删除不存在的用户
-	err = store.Delete("non-existent")
+	err = store.Delete(t.Context(), "non-existent")
 	assert.Error(t, err)
 
 	// 测试创建重复用户
@@ -195,20 +300,20 @@ func TestLocalStore_ErrorCases(t *testing.T) {
 		ID:       "test-user-1",
 		Username: "Test User",
 	}
-	err = store.Create(user)
+	err = store.Create(t.Context(), user)
 	assert.NoError(t, err)
 
-	err = store.Create(user)
+	err = store.Create(t.Context(), user)
 	assert.Error(t, err)
 
 	// 测试评论相关错误
-	_, err = store.GetComment("non-existent")
+	_, err = store.GetComment(t.Context(), "non-existent")
 	assert.Error(t, err)
 
-	err = store.UpdateComment(Comment{ID: "non-existent"})
+	err = store.UpdateComment(t.Context(), Comment{ID: "non-existent"})
 	assert.Error(t, err)
 
-	err = store.DeleteComment("non-existent")
+	err = store.DeleteComment(t.Context(), "non-existent")
 	assert.Error(t, err)
 
 	comment := Comment{
@@ -216,9 +321,9 @@ func TestLocalStore_ErrorCases(t *testing.T) {
 		Content: "Test Comment",
 		UserID:  "test-user-1",
 	}
-	err = store.CreateComment(comment)
+	err = store.CreateComment(t.Context(), comment)
 	assert.NoError(t, err)
 
-	err = store.CreateComment(comment)
+	err = store.CreateComment(t.Context(), comment)
 	assert.Error(t, err)
 }
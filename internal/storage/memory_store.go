@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Axpz/store/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// MemoryStore 实现纯内存存储，用于测试和演示，进程退出后数据丢失
+type MemoryStore struct {
+	*Store
+}
+
+// Fixture 是内存存储的种子数据，也是 Snapshot/Restore 的数据格式。
+// 每张表与表文件的格式相同：以记录 ID 为键的对象。
+type Fixture struct {
+	Users    map[string]User    `json:"users,omitempty"`
+	Orders   map[string]Order   `json:"orders,omitempty"`
+	Products map[string]Product `json:"products,omitempty"`
+	Comments map[string]Comment `json:"comments,omitempty"`
+}
+
+// NewMemoryStore 创建一个新的内存存储，配置了 storage.fixture 时加载种子数据
+func NewMemoryStore(cfg *config.Config) (StoreInterface, error) {
+	store := &MemoryStore{}
+	store.Store = NewStore(cfg, store, storageTimeout(cfg, 0))
+
+	if cfg.Storage.Fixture != "" {
+		fixture, err := LoadFixture(cfg.Storage.Fixture)
+		if err != nil {
+			return nil, err
+		}
+		store.Restore(fixture)
+	}
+
+	return store, nil
+}
+
+// LoadFixture 从 JSON 或 YAML 文件读取种子数据
+func LoadFixture(path string) (Fixture, error) {
+	var fixture Fixture
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fixture, fmt.Errorf("读取种子数据失败: %v", err)
+	}
+
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		// 模型只有 json 标签，先解析为通用结构再按 JSON 解码
+		var raw any
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return fixture, fmt.Errorf("解析 YAML 失败: %v", err)
+		}
+		if data, err = json.Marshal(raw); err != nil {
+			return fixture, fmt.Errorf("转换 YAML 失败: %v", err)
+		}
+	}
+
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return fixture, fmt.Errorf("解析 JSON 失败: %v", err)
+	}
+
+	return fixture, nil
+}
+
+// Snapshot 返回当前全部数据的深拷贝
+func (s *MemoryStore) Snapshot() Fixture {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return cloneFixture(Fixture{
+		Users:    s.users.rows,
+		Orders:   s.orders.rows,
+		Products: s.products.rows,
+		Comments: s.comments.rows,
+	})
+}
+
+// Restore 用 fixture 的深拷贝替换全部数据
+func (s *MemoryStore) Restore(fixture Fixture) {
+	fixture = cloneFixture(fixture)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users.replace(&fixture.Users)
+	s.orders.replace(&fixture.Orders)
+	s.products.replace(&fixture.Products)
+	s.comments.replace(&fixture.Comments)
+}
+
+// cloneFixture 通过 JSON 往返深拷贝，并保证每张表都是非 nil 的 map
+func cloneFixture(fixture Fixture) Fixture {
+	var clone Fixture
+
+	data, err := json.Marshal(fixture)
+	if err == nil {
+		err = json.Unmarshal(data, &clone)
+	}
+	if err != nil {
+		// 模型都是可序列化的普通结构，这里不会失败
+		panic(fmt.Sprintf("复制种子数据失败: %v", err))
+	}
+
+	if clone.Users == nil {
+		clone.Users = make(map[string]User)
+	}
+	if clone.Orders == nil {
+		clone.Orders = make(map[string]Order)
+	}
+	if clone.Products == nil {
+		clone.Products = make(map[string]Product)
+	}
+	if clone.Comments == nil {
+		clone.Comments = make(map[string]Comment)
+	}
+	return clone
+}
+
+// loadTable 内存表创建时即为空表，无需加载
+func (s *MemoryStore) loadTable(ctx context.Context, tableName string, data any) error {
+	return nil
+}
+
+func (s *MemoryStore) beforePut(ctx context.Context, tableName, id string, value any) error {
+	return nil
+}
+
+func (s *MemoryStore) beforeDelete(ctx context.Context, tableName, id string) error {
+	return nil
+}
+
+func (s *MemoryStore) saveTable(ctx context.Context, tableName string, data any) error {
+	return nil
+}
+
+func (s *MemoryStore) commitTx(ctx context.Context, changes []txChange, tables map[string]any) error {
+	return nil
+}
+
+// Flush 内存存储没有需要落盘的数据
+func (s *MemoryStore) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close 关闭 Watch 通道，内存存储没有其他需要释放的资源
+func (s *MemoryStore) Close() error {
+	s.feed.close()
+	return nil
+}
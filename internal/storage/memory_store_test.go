@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testFixtureYAML = `
+users:
+  user-1:
+    id: user-1
+    username: Fixture User
+    email: user@example.com
+products:
+  p-1:
+    id: p-1
+    name: Starter
+    price: 1999
+    content: [a, b]
+orders:
+  o-1:
+    id: o-1
+    user_id: user-1
+    status: pending
+    total_amount: 1999
+    products:
+      - id: p-1
+        quantity: 1
+`
+
+func newTestMemoryStore(t *testing.T) *MemoryStore {
+	path := filepath.Join(t.TempDir(), "fixture.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testFixtureYAML), 0644))
+
+	store, err := New(&config.Config{
+		Storage: config.StorageConfig{Type: "memory", Fixture: path},
+	})
+	require.NoError(t, err)
+	return store.(*MemoryStore)
+}
+
+func TestMemoryStore_Fixture(t *testing.T) {
+	store := newTestMemoryStore(t)
+
+	user, err := store.Get(t.Context(), "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Fixture User", user.Username)
+
+	orders, err := store.GetOrdersByUserID(t.Context(), "user-1")
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, int64(1999), orders[0].TotalAmount)
+	assert.Equal(t, "p-1", orders[0].Products[0].ID)
+
+	product, err := store.GetProduct(t.Context(), "p-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, product.Content)
+
+	// 未出现在种子数据中的表也可以写入
+	assert.NoError(t, store.CreateComment(t.Context(), Comment{ID: "c-1"}))
+}
+
+func TestMemoryStore_ErrorSemantics(t *testing.T) {
+	store := newTestMemoryStore(t)
+
+	_, err := store.Get(t.Context(), "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.Create(t.Context(), User{ID: "user-1"}), ErrAlreadyExists)
+	assert.ErrorIs(t, store.Update(t.Context(), User{ID: "missing"}), ErrNotFound)
+	assert.ErrorIs(t, store.Delete(t.Context(), "missing"), ErrNotFound)
+
+	assert.Error(t, store.CreateOrder(t.Context(), Order{ID: "o-1"}))
+	assert.Error(t, store.UpdateOrder(t.Context(), Order{ID: "missing"}))
+	assert.Error(t, store.DeleteProduct(t.Context(), "missing"))
+	_, err = store.GetComment(t.Context(), "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryStore_SnapshotRestore(t *testing.T) {
+	store := newTestMemoryStore(t)
+	snapshot := store.Snapshot()
+
+	require.NoError(t, store.Delete(t.Context(), "user-1"))
+	require.NoError(t, store.CreateProduct(t.Context(), Product{ID: "p-2", Name: "Added"}))
+
+	// 快照是深拷贝，不受后续写入影响
+	assert.Contains(t, snapshot.Users, "user-1")
+	assert.NotContains(t, snapshot.Products, "p-2")
+
+	store.Restore(snapshot)
+
+	_, err := store.Get(t.Context(), "user-1")
+	assert.NoError(t, err)
+	_, err = store.GetProduct(t.Context(), "p-2")
+	assert.Error(t, err)
+}
@@ -16,4 +16,8 @@ type Comment struct {
 	Content string `json:"content"`
 	Created int64  `json:"created"`
 	Updated int64  `json:"updated"`
+	// Deleted 软删除时间（时间戳），0 表示未删除
+	Deleted int64 `json:"deleted,omitempty"`
+	// Expires 过期时间（时间戳），到期后记录被自动删除，0 表示不过期
+	Expires int64 `json:"expires,omitempty"`
 }
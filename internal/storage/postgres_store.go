@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+const (
+	defaultPostgresMaxOpenConns    = 20
+	defaultPostgresMaxIdleConns    = 5
+	defaultPostgresConnMaxLifetime = 30 * time.Minute
+
+	// postgresMigrationLockID 是迁移使用的 advisory lock 键，同一数据库内的所有副本共用
+	postgresMigrationLockID = "7243001"
+)
+
+// PostgresStore 实现基于 PostgreSQL 的共享存储，多个副本连接同一个数据库，数据不会在副本之间分叉
+type PostgresStore struct {
+	sqlStore
+}
+
+// NewPostgresStore 连接 storage.dsn 指定的数据库，配置连接池，并在启动时执行结构迁移
+func NewPostgresStore(cfg *config.Config) (StoreInterface, error) {
+	db, err := sql.Open("pgx", cfg.Storage.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库失败: %v", err)
+	}
+
+	db.SetMaxOpenConns(cmp.Or(cfg.Storage.MaxOpenConns, defaultPostgresMaxOpenConns))
+	db.SetMaxIdleConns(cmp.Or(cfg.Storage.MaxIdleConns, defaultPostgresMaxIdleConns))
+	db.SetConnMaxLifetime(cmp.Or(cfg.Storage.ConnMaxLifetime, defaultPostgresConnMaxLifetime))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("连接数据库失败: %v", err)
+	}
+
+	dialect := sqlDialect{
+		numbered: true,
+		rowLocks: true,
+		// 事务级 advisory lock，事务结束时自动释放
+		migrationLock:          "SELECT pg_advisory_xact_lock(" + postgresMigrationLockID + ")",
+		txIsolation:            sql.LevelSerializable,
+		isSerializationFailure: isPostgresSerializationFailure,
+	}
+	if err := migrate(db, dialect, postgresMigrations); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &PostgresStore{sqlStore{db: db, dialect: dialect, config: cfg, timeout: storageTimeout(cfg, defaultSQLTimeout), feed: newChangeFeed()}}, nil
+}
+
+// isPostgresSerializationFailure 判断错误是否为可串行化事务冲突（40001）或死锁（40P01）
+func isPostgresSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01")
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/Axpz/store/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// postgresTestDSN 返回一个独立 schema 的连接串，测试结束后删除该 schema。
+// 未设置 STORE_TEST_POSTGRES_DSN 时跳过测试，可用 make test-postgres 在本地启动数据库运行。
+func postgresTestDSN(t *testing.T) string {
+	dsn := os.Getenv("STORE_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("STORE_TEST_POSTGRES_DSN is not set")
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("store_test_%d", time.Now().UnixNano())
+	_, err = admin.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	u, err := url.Parse(dsn)
+	require.NoError(t, err)
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func openTestPostgresStore(t *testing.T, dsn string) *PostgresStore {
+	store, err := New(&config.Config{
+		Storage: config.StorageConfig{Type: "postgres", DSN: dsn},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store.(*PostgresStore)
+}
+
+func TestPostgresStore_UserCRUD(t *testing.T) {
+	store := openTestPostgresStore(t, postgresTestDSN(t))
+
+	verified := true
+	user := User{ID: "user-1", Username: "Test User", Email: "test@example.com", Verified: &verified}
+	require.NoError(t, store.Create(t.Context(), user))
+	assert.ErrorIs(t, store.Create(t.Context(), user), ErrAlreadyExists)
+
+	got, err := store.Get(t.Context(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user, got)
+
+	user.Username = "Updated User"
+	require.NoError(t, store.Update(t.Context(), user))
+	got, err = store.Get(t.Context(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user, got)
+
+	require.NoError(t, store.Delete(t.Context(), user.ID))
+	_, err = store.Get(t.Context(), user.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.Update(t.Context(), user), ErrNotFound)
+}
+
+func TestPostgresStore_OrdersAndProducts(t *testing.T) {
+	store := openTestPostgresStore(t, postgresTestDSN(t))
+
+	older := Order{
+		ID:       "o-1",
+		UserID:   "user-1",
+		Products: []types.OrderProduct{{ID: "p-1", Quantity: 2, Price: 100}},
+		Updated:  1,
+	}
+	newer := Order{ID: "o-2", UserID: "user-1", Updated: 2}
+	for _, o := range []Order{older, newer} {
+		require.NoError(t, store.CreateOrder(t.Context(), o))
+	}
+	assert.Error(t, store.CreateOrder(t.Context(), older))
+
+	orders, err := store.GetOrdersByUserID(t.Context(), "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, []Order{newer, older}, orders)
+
+	older.Status = "paid"
+	require.NoError(t, store.UpdateOrder(t.Context(), older))
+	got, err := store.GetOrder(t.Context(), older.ID)
+	require.NoError(t, err)
+	assert.Equal(t, older, got)
+	assert.Error(t, store.UpdateOrder(t.Context(), Order{ID: "missing"}))
+
+	beta := Product{ID: "p-2", Name: "Beta", Content: []string{"x"}}
+	alpha := Product{ID: "p-1", Name: "Alpha"}
+	require.NoError(t, store.CreateProduct(t.Context(), beta))
+	require.NoError(t, store.CreateProduct(t.Context(), alpha))
+	products, err := store.GetProducts(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []Product{alpha, beta}, products)
+
+	comment := Comment{ID: "c-1", UserID: "user-1", Content: "hello"}
+	require.NoError(t, store.CreateComment(t.Context(), comment))
+	require.NoError(t, store.DeleteComment(t.Context(), comment.ID))
+	_, err = store.GetComment(t.Context(), comment.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestPostgresStore_Replicas(t *testing.T) {
+	dsn := postgresTestDSN(t)
+
+	// 多个副本同时启动，迁移只执行一次
+	replicas := make([]*PostgresStore, 3)
+	var wg sync.WaitGroup
+	for i := range replicas {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store, err := NewPostgresStore(&config.Config{
+				Storage: config.StorageConfig{Type: "postgres", DSN: dsn},
+			})
+			if assert.NoError(t, err) {
+				replicas[i] = store.(*PostgresStore)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, r := range replicas {
+		require.NotNil(t, r)
+		t.Cleanup(func() { r.Close() })
+	}
+
+	// 一个副本写入，其他副本立即可见
+	require.NoError(t, replicas[0].CreateOrder(t.Context(), Order{ID: "o-1", UserID: "user-1"}))
+
+	for i, r := range replicas {
+		wg.Add(1)
+		go func(i int, r *PostgresStore) {
+			defer wg.Done()
+			assert.NoError(t, r.UpdateOrder(t.Context(), Order{ID: "o-1", UserID: "user-1", Updated: int64(i + 1)}))
+		}(i, r)
+	}
+	wg.Wait()
+
+	got, err := replicas[1].GetOrder(t.Context(), "o-1")
+	require.NoError(t, err)
+	assert.Contains(t, []int64{1, 2, 3}, got.Updated)
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Axpz/store/internal/config"
+	"go.uber.org/zap"
+)
+
+// defaultPurgeInterval 是清理墓碑的默认间隔
+const defaultPurgeInterval = time.Hour
+
+// Purger 定期彻底删除超过保留时间的墓碑
+type Purger struct {
+	store  StoreInterface
+	cfg    config.TombstoneConfig
+	logger *zap.Logger
+	// now 返回当前时间，测试中可替换
+	now func() time.Time
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// NewPurger 创建墓碑的定时清理，cfg.Retention 必须大于 0，调用 Start 后开始运行
+func NewPurger(store StoreInterface, cfg config.TombstoneConfig, logger *zap.Logger) *Purger {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultPurgeInterval
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Purger{store: store, cfg: cfg, logger: logger, now: time.Now}
+}
+
+// Start 在后台按间隔清理墓碑
+func (p *Purger) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.stop, p.done = cancel, make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(p.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				purged, err := p.RunOnce(ctx)
+				if err != nil {
+					p.logger.Error("清理墓碑失败", zap.Error(err))
+					continue
+				}
+				if purged > 0 {
+					p.logger.Info("清理墓碑完成", zap.Int("purged", purged))
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止定时清理，等待正在进行的清理结束
+func (p *Purger) Stop() {
+	if p.stop == nil {
+		return
+	}
+	p.stop()
+	<-p.done
+}
+
+// RunOnce 清理每张表中删除时间早于保留时间的墓碑，返回删除的记录总数
+func (p *Purger) RunOnce(ctx context.Context) (int, error) {
+	before := p.now().Add(-p.cfg.Retention)
+
+	var total int
+	for _, tableName := range TableNames {
+		n, err := p.store.Purge(ctx, tableName, before)
+		if err != nil {
+			return total, fmt.Errorf("清理表 %s 失败: %w", tableName, err)
+		}
+		total += n
+	}
+	return total, nil
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurger_Retention(t *testing.T) {
+	store, err := NewMemoryStore(&config.Config{})
+	require.NoError(t, err)
+
+	require.NoError(t, store.Create(t.Context(), User{ID: "user-1"}))
+	require.NoError(t, store.CreateOrder(t.Context(), Order{ID: "o-1", UserID: "user-1"}))
+	require.NoError(t, store.CreateOrder(t.Context(), Order{ID: "o-2", UserID: "user-1"}))
+	require.NoError(t, store.Delete(t.Context(), "user-1"))
+	require.NoError(t, store.DeleteOrder(t.Context(), "o-1"))
+
+	purger := NewPurger(store, config.TombstoneConfig{Retention: 24 * time.Hour}, nil)
+
+	// 保留期内的墓碑不清理
+	purged, err := purger.RunOnce(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 0, purged)
+	require.NoError(t, store.Undelete(t.Context(), "users", "user-1"))
+	require.NoError(t, store.Delete(t.Context(), "user-1"))
+
+	purger.now = func() time.Time { return time.Now().Add(25 * time.Hour) }
+	purged, err = purger.RunOnce(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 2, purged)
+	assert.True(t, errors.Is(store.Undelete(t.Context(), "users", "user-1"), ErrNotFound))
+	page, err := store.List(t.Context(), "orders", Query{WithDeleted: true})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 1, "未删除的记录保留")
+}
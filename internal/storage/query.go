@@ -0,0 +1,404 @@
+package storage
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidQuery 表示查询引用了不存在或不支持的表、字段，或游标无效
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Index 是一个二级索引：按 Field 的值查找 Table 中的记录。
+// Field 是记录的 JSON 字段名，与数据库列名一致。
+type Index struct {
+	Table string
+	Field string
+}
+
+// Indexes 是全部二级索引，各后端在写入时维护，List 按索引字段过滤时使用。
+// 新增索引时 SQL 后端还需要追加一个建索引的迁移。
+var Indexes = []Index{
+	{Table: "orders", Field: "user_id"},
+	{Table: "orders", Field: "status"},
+	{Table: "users", Field: "email"},
+	{Table: "products", Field: "type"},
+}
+
+// Query 描述 List 的过滤、排序和分页条件
+type Query struct {
+	// Filter 按字段等值过滤，键为 JSON 字段名，多个条件同时满足
+	Filter map[string]string
+	// Sort 是排序字段，前缀 "-" 表示倒序；为空时按 id 排序。排序值相同时按 id 排序
+	Sort string
+	// Limit 是每页最多返回的记录数，0 表示不限制
+	Limit int
+	// Cursor 是上一页返回的 Page.Next，为空表示第一页
+	Cursor string
+	// WithDeleted 为 true 时结果包含墓碑（软删除的记录）和尚未清扫的过期记录，
+	// 供备份、复制等需要完整数据的场景使用
+	WithDeleted bool
+}
+
+// Page 是 List 返回的一页记录
+type Page struct {
+	// Items 是记录的 JSON，可用 DecodePage 解码
+	Items []json.RawMessage
+	// Next 是下一页的游标，为空表示没有更多记录
+	Next string
+}
+
+// DecodePage 把一页记录解码为 T
+func DecodePage[T any](p Page) ([]T, error) {
+	result := make([]T, 0, len(p.Items))
+	for _, item := range p.Items {
+		var v T
+		if err := json.Unmarshal(item, &v); err != nil {
+			return nil, fmt.Errorf("解析记录失败: %v", err)
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+// tableTypes 是每张表的记录类型
+var tableTypes = map[string]reflect.Type{
+	"users":    reflect.TypeFor[User](),
+	"orders":   reflect.TypeFor[Order](),
+	"products": reflect.TypeFor[Product](),
+	"comments": reflect.TypeFor[Comment](),
+}
+
+// decodeRecord 把表 tableName 中一条记录的 JSON 解析为对应类型的值（User、Order 等）
+func decodeRecord(tableName string, data []byte) (any, error) {
+	typ, ok := tableTypes[tableName]
+	if !ok {
+		return nil, fmt.Errorf("未知的表 %s: %w", tableName, ErrInvalidQuery)
+	}
+	record := reflect.New(typ)
+	if err := json.Unmarshal(data, record.Interface()); err != nil {
+		return nil, fmt.Errorf("解析记录失败: %v", err)
+	}
+	return record.Elem().Interface(), nil
+}
+
+// tableIndexes 返回表上的全部索引字段
+func tableIndexes(tableName string) []string {
+	var fields []string
+	for _, idx := range Indexes {
+		if idx.Table == tableName {
+			fields = append(fields, idx.Field)
+		}
+	}
+	return fields
+}
+
+// queryFields 是一张表中可以用于过滤和排序的标量字段（JSON 字段名 → 结构体字段下标）
+type queryFields map[string]int
+
+// fieldsOf 按 JSON 标签列出类型 t 的标量字段
+func fieldsOf(t reflect.Type) queryFields {
+	fields := make(queryFields)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		kind := f.Type.Kind()
+		if kind == reflect.Pointer {
+			kind = f.Type.Elem().Kind()
+		}
+		switch kind {
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int64:
+			fields[name] = i
+		}
+	}
+	return fields
+}
+
+// tableQuery 是针对某张表检查过的查询
+type tableQuery struct {
+	Query
+
+	typ    reflect.Type
+	fields queryFields
+	// filter 是解析为字段类型的过滤值
+	filter map[string]any
+	// sortField 和 desc 是解析后的排序条件
+	sortField string
+	desc      bool
+	// after 是游标位置：排序值和 id，cursor 为 false 表示第一页
+	cursor     bool
+	afterValue any
+	afterID    string
+	// now 是查询开始的时间（Unix 秒），用于判断记录是否过期
+	now int64
+}
+
+// prepareQuery 检查查询引用的表和字段，并解析过滤值和游标
+func prepareQuery(tableName string, q Query) (*tableQuery, error) {
+	typ, ok := tableTypes[tableName]
+	if !ok {
+		return nil, fmt.Errorf("未知的表 %s: %w", tableName, ErrInvalidQuery)
+	}
+
+	tq := &tableQuery{Query: q, typ: typ, fields: fieldsOf(typ), filter: make(map[string]any), now: time.Now().Unix()}
+	if q.Limit < 0 {
+		return nil, fmt.Errorf("limit 不能为负数: %w", ErrInvalidQuery)
+	}
+
+	for field, value := range q.Filter {
+		v, err := tq.parse(field, value)
+		if err != nil {
+			return nil, err
+		}
+		tq.filter[field] = v
+	}
+
+	tq.sortField, tq.desc = strings.CutPrefix(q.Sort, "-")
+	tq.sortField = cmp.Or(tq.sortField, "id")
+	if _, ok := tq.fields[tq.sortField]; !ok {
+		return nil, fmt.Errorf("表 %s 不能按字段 %s 排序: %w", tableName, tq.sortField, ErrInvalidQuery)
+	}
+
+	if q.Cursor != "" {
+		if err := tq.decodeCursor(q.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	return tq, nil
+}
+
+// parse 把字符串形式的过滤值解析为字段的类型
+func (tq *tableQuery) parse(field, value string) (any, error) {
+	i, ok := tq.fields[field]
+	if !ok {
+		return nil, fmt.Errorf("不能按字段 %s 过滤: %w", field, ErrInvalidQuery)
+	}
+
+	t := tq.typ.Field(i).Type
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("字段 %s 的值 %q 不是布尔值: %w", field, value, ErrInvalidQuery)
+		}
+		return b, nil
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("字段 %s 的值 %q 不是整数: %w", field, value, ErrInvalidQuery)
+		}
+		return n, nil
+	default:
+		return value, nil
+	}
+}
+
+// value 返回记录 record 中字段 field 的值，整数统一为 int64，nil 指针为 nil
+func (tq *tableQuery) value(record any, field string) any {
+	v := reflect.ValueOf(record)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	return scalar(v.Field(tq.fields[field]))
+}
+
+func scalar(v reflect.Value) any {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int64:
+		return v.Int()
+	default:
+		return v.Interface()
+	}
+}
+
+// match 判断记录是否满足全部过滤条件，未指定 WithDeleted 时墓碑和过期记录不满足
+func (tq *tableQuery) match(record any) bool {
+	if !tq.WithDeleted && !visible(record, tq.now) {
+		return false
+	}
+	for field, want := range tq.filter {
+		if compareValues(tq.value(record, field), want) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// compareValues 比较两个同一字段的值，nil 排在最前
+func compareValues(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b)
+		}
+	case int64:
+		if b, ok := b.(int64); ok {
+			return cmp.Compare(a, b)
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			switch {
+			case a == b:
+				return 0
+			case !a:
+				return -1
+			default:
+				return 1
+			}
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// queryCursor 是游标的内容：上一页最后一条记录的排序值和 id
+type queryCursor struct {
+	Value json.RawMessage `json:"v"`
+	ID    string          `json:"id"`
+}
+
+// encodeCursor 返回位于 record 之后的游标
+func (tq *tableQuery) encodeCursor(id string, record any) (string, error) {
+	value, err := json.Marshal(tq.value(record, tq.sortField))
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(queryCursor{Value: value, ID: id})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func (tq *tableQuery) decodeCursor(cursor string) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return fmt.Errorf("游标格式错误: %w", ErrInvalidQuery)
+	}
+	var c queryCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return fmt.Errorf("游标格式错误: %w", ErrInvalidQuery)
+	}
+
+	tq.cursor, tq.afterID = true, c.ID
+	if bytes.Equal(c.Value, []byte("null")) {
+		return nil
+	}
+	ptr := reflect.New(tq.typ.Field(tq.fields[tq.sortField]).Type)
+	if err := json.Unmarshal(c.Value, ptr.Interface()); err != nil {
+		return fmt.Errorf("游标与排序字段不匹配: %w", ErrInvalidQuery)
+	}
+	tq.afterValue = scalar(ptr.Elem())
+	return nil
+}
+
+// queryRecord 是内存中执行查询时的一条候选记录
+type queryRecord struct {
+	id     string
+	record any
+}
+
+// page 对已经过滤的记录排序、按游标定位并截取一页
+func (tq *tableQuery) page(records []queryRecord) (Page, error) {
+	less := func(a, b queryRecord) int {
+		c := compareValues(tq.value(a.record, tq.sortField), tq.value(b.record, tq.sortField))
+		if c == 0 {
+			c = strings.Compare(a.id, b.id)
+		}
+		if tq.desc {
+			c = -c
+		}
+		return c
+	}
+	sort.Slice(records, func(i, j int) bool { return less(records[i], records[j]) < 0 })
+
+	start := 0
+	if tq.cursor {
+		after := func(r queryRecord) bool {
+			c := compareValues(tq.value(r.record, tq.sortField), tq.afterValue)
+			if c == 0 {
+				c = strings.Compare(r.id, tq.afterID)
+			}
+			if tq.desc {
+				c = -c
+			}
+			return c > 0
+		}
+		start = sort.Search(len(records), func(i int) bool { return after(records[i]) })
+	}
+
+	return tq.slice(records[start:])
+}
+
+// slice 取 records 中的前 Limit 条作为一页，records 已按查询排序并从游标位置开始
+func (tq *tableQuery) slice(records []queryRecord) (Page, error) {
+	end := len(records)
+	if tq.Limit > 0 && tq.Limit < end {
+		end = tq.Limit
+	}
+
+	var p Page
+	for _, r := range records[:end] {
+		data, err := json.Marshal(r.record)
+		if err != nil {
+			return Page{}, fmt.Errorf("序列化记录失败: %v", err)
+		}
+		p.Items = append(p.Items, data)
+	}
+	if end < len(records) {
+		next, err := tq.encodeCursor(records[end-1].id, records[end-1].record)
+		if err != nil {
+			return Page{}, fmt.Errorf("生成游标失败: %v", err)
+		}
+		p.Next = next
+	}
+	return p, nil
+}
+
+// indexedFilter 返回查询中第一个有索引的过滤字段及其值，没有时 ok 为 false
+func (tq *tableQuery) indexedFilter(tableName string) (field string, value any, ok bool) {
+	for _, f := range tableIndexes(tableName) {
+		if v, exists := tq.filter[f]; exists {
+			return f, v, true
+		}
+	}
+	return "", nil, false
+}
+
+// indexKey 是索引中字段值的字符串形式
+func indexKey(value any) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
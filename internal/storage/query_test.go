@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestList_FilterSortAndPaginate(t *testing.T) {
+	for name, open := range txBackends() {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			for i := range 5 {
+				status := "pending"
+				if i%2 == 1 {
+					status = "completed"
+				}
+				require.NoError(t, store.CreateOrder(t.Context(), Order{
+					ID: fmt.Sprintf("o-%d", i), UserID: "user-1", Status: status, Updated: int64(100 - i),
+				}))
+			}
+			require.NoError(t, store.CreateOrder(t.Context(), Order{ID: "o-x", UserID: "user-2", Status: "pending"}))
+
+			// 索引字段过滤 + 倒序 + 分页
+			var ids []string
+			q := Query{Filter: map[string]string{"user_id": "user-1"}, Sort: "-updated", Limit: 2}
+			for pages := 0; ; pages++ {
+				require.Less(t, pages, 3)
+				page, err := store.List(t.Context(), "orders", q)
+				require.NoError(t, err)
+				orders, err := DecodePage[Order](page)
+				require.NoError(t, err)
+				for _, o := range orders {
+					ids = append(ids, o.ID)
+				}
+				if page.Next == "" {
+					break
+				}
+				q.Cursor = page.Next
+			}
+			assert.Equal(t, []string{"o-0", "o-1", "o-2", "o-3", "o-4"}, ids)
+
+			// 多个过滤条件
+			page, err := store.List(t.Context(), "orders", Query{
+				Filter: map[string]string{"user_id": "user-1", "status": "completed"},
+			})
+			require.NoError(t, err)
+			orders, err := DecodePage[Order](page)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"o-1", "o-3"}, orderIDs(orders))
+			assert.Empty(t, page.Next)
+
+			// 修改和删除后索引同步更新
+			require.NoError(t, store.UpdateOrder(t.Context(), Order{ID: "o-1", UserID: "user-1", Status: "pending", Updated: 99}))
+			require.NoError(t, store.DeleteOrder(t.Context(), "o-3"))
+			page, err = store.List(t.Context(), "orders", Query{Filter: map[string]string{"status": "completed"}})
+			require.NoError(t, err)
+			assert.Empty(t, page.Items)
+
+			byUser, err := store.GetOrdersByUserID(t.Context(), "user-1")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"o-0", "o-1", "o-2", "o-4"}, orderIDs(byUser))
+
+			// 非索引字段过滤
+			require.NoError(t, store.CreateProduct(t.Context(), Product{ID: "p-1", Name: "B", Type: "book"}))
+			require.NoError(t, store.CreateProduct(t.Context(), Product{ID: "p-2", Name: "A", Type: "book"}))
+			require.NoError(t, store.CreateProduct(t.Context(), Product{ID: "p-3", Name: "C", Type: "video"}))
+			page, err = store.List(t.Context(), "products", Query{Filter: map[string]string{"type": "book"}, Sort: "name"})
+			require.NoError(t, err)
+			products, err := DecodePage[Product](page)
+			require.NoError(t, err)
+			require.Len(t, products, 2)
+			assert.Equal(t, "p-2", products[0].ID)
+			assert.Equal(t, "p-1", products[1].ID)
+		})
+	}
+}
+
+func TestList_InvalidQuery(t *testing.T) {
+	for name, open := range txBackends() {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+
+			for _, q := range []Query{
+				{Filter: map[string]string{"no_such_field": "x"}},
+				{Filter: map[string]string{"products": "x"}},
+				{Sort: "-no_such_field"},
+				{Limit: -1},
+				{Cursor: "not a cursor"},
+			} {
+				_, err := store.List(t.Context(), "orders", q)
+				assert.ErrorIs(t, err, ErrInvalidQuery, "%+v", q)
+			}
+
+			_, err := store.List(t.Context(), "no_such_table", Query{})
+			assert.ErrorIs(t, err, ErrInvalidQuery)
+		})
+	}
+}
+
+func orderIDs(orders []Order) []string {
+	ids := make([]string, 0, len(orders))
+	for _, o := range orders {
+		ids = append(ids, o.ID)
+	}
+	return ids
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Migration 把一张表的记录从 Version-1 升级到 Version
+type Migration struct {
+	Table       string
+	Version     int
+	Description string
+	// Up 就地修改一条记录，记录是解码后的 JSON 对象（数字为 json.Number）
+	Up func(record map[string]any) error
+}
+
+// Migrations 是 JSON 表文件的迁移，同一张表的版本从 1 开始连续递增。
+// 读取表文件时按文件中的 schema_version 依次执行之后的迁移；写入时总是写当前版本。
+var Migrations = []Migration{
+	{
+		Table:       "users",
+		Version:     1,
+		Description: "没有 verified 字段的用户早于邮箱验证功能，标记为已验证",
+		Up: func(record map[string]any) error {
+			if _, ok := record["verified"]; !ok {
+				record["verified"] = true
+			}
+			return nil
+		},
+	},
+}
+
+// SchemaVersion 返回表 tableName 的当前版本，没有迁移的表为 0
+func SchemaVersion(tableName string) int {
+	version := 0
+	for _, m := range Migrations {
+		if m.Table == tableName && m.Version > version {
+			version = m.Version
+		}
+	}
+	return version
+}
+
+// tableMigrations 返回表 tableName 中版本高于 from 的迁移，按版本排序
+func tableMigrations(tableName string, from int) []Migration {
+	var result []Migration
+	for _, m := range Migrations {
+		if m.Table == tableName && m.Version > from {
+			result = append(result, m)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result
+}
+
+// tableEnvelope 是表文件的格式。没有 schema_version 的旧文件整个就是 records，版本为 0。
+type tableEnvelope struct {
+	SchemaVersion int             `json:"schema_version"`
+	Records       json.RawMessage `json:"records"`
+}
+
+// tableFile 是解析并升级后的表文件
+type tableFile struct {
+	// version 是文件中记录的版本
+	version int
+	// records 是升级到当前版本的全部记录（JSON 对象）
+	records json.RawMessage
+	// changed 是迁移修改过的记录 ID
+	changed []string
+	// keyID 是加密文件所用密钥的 ID，明文文件为空
+	keyID string
+	// compression 是文件的压缩方式，未压缩为空
+	compression string
+}
+
+// outdated 判断文件是否需要重写为当前版本
+func (f *tableFile) outdated(tableName string) bool {
+	return f.version < SchemaVersion(tableName)
+}
+
+// decodeTableFile 解析表文件并把记录升级到当前版本。
+// 文件版本高于程序支持的版本时返回错误，避免旧程序读取并覆盖新格式的数据。
+func decodeTableFile(tableName string, content []byte) (*tableFile, error) {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(content, &top); err != nil {
+		return nil, err
+	}
+
+	f := &tableFile{records: content}
+	if isEnvelope(top) {
+		var env tableEnvelope
+		if err := json.Unmarshal(content, &env); err != nil {
+			return nil, err
+		}
+		f.version, f.records = env.SchemaVersion, env.Records
+	}
+
+	if err := f.upgrade(tableName); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// UpgradeRecords 把表 tableName 中版本为 version 的记录（ID → 记录的 JSON 对象）升级到当前版本
+func UpgradeRecords(tableName string, version int, records json.RawMessage) (json.RawMessage, error) {
+	f := &tableFile{version: version, records: records}
+	if err := f.upgrade(tableName); err != nil {
+		return nil, err
+	}
+	return f.records, nil
+}
+
+// upgrade 依次执行 f.version 之后的迁移，记录被修改的 ID
+func (f *tableFile) upgrade(tableName string) error {
+	current := SchemaVersion(tableName)
+	if f.version > current {
+		return fmt.Errorf("表 %s 的版本 %d 高于程序支持的版本 %d", tableName, f.version, current)
+	}
+	migrations := tableMigrations(tableName, f.version)
+	if len(migrations) == 0 {
+		return nil
+	}
+
+	var records map[string]map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(f.records))
+	decoder.UseNumber()
+	if err := decoder.Decode(&records); err != nil {
+		return err
+	}
+
+	for id, record := range records {
+		before, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if err := m.Up(record); err != nil {
+				return fmt.Errorf("迁移记录 %s/%s 到版本 %d 失败: %v", tableName, id, m.Version, err)
+			}
+		}
+		after, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(before, after) {
+			f.changed = append(f.changed, id)
+		}
+	}
+	sort.Strings(f.changed)
+
+	upgraded, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	f.records = upgraded
+	return nil
+}
+
+// isEnvelope 判断表文件的顶层对象是否为 tableEnvelope。
+// 记录都是 JSON 对象，因此即使有 ID 为 schema_version 的记录也不会误判。
+func isEnvelope(top map[string]json.RawMessage) bool {
+	version, ok := top["schema_version"]
+	if !ok || len(top) != 2 {
+		return false
+	}
+	if _, ok := top["records"]; !ok {
+		return false
+	}
+	var n int
+	return json.Unmarshal(version, &n) == nil
+}
+
+// encodeTableFile 把表序列化为当前版本的表文件，indent 为空时输出紧凑的 JSON
+func encodeTableFile(tableName string, data any, indent string) ([]byte, error) {
+	records, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	env := tableEnvelope{SchemaVersion: SchemaVersion(tableName), Records: records}
+	if indent == "" {
+		return json.Marshal(env)
+	}
+	return json.MarshalIndent(env, "", indent)
+}
+
+// MigrationReport 是一张表的迁移结果
+type MigrationReport struct {
+	Table string
+	// From 和 To 是迁移前后的版本，相同表示无需迁移
+	From, To int
+	// Records 是表中的记录数
+	Records int
+	// Changed 是迁移修改过的记录 ID
+	Changed []string
+}
+
+// report 返回表文件的迁移结果
+func (f *tableFile) report(tableName string) (MigrationReport, error) {
+	var records map[string]json.RawMessage
+	if err := json.Unmarshal(f.records, &records); err != nil {
+		return MigrationReport{}, err
+	}
+	return MigrationReport{
+		Table:   tableName,
+		From:    f.version,
+		To:      SchemaVersion(tableName),
+		Records: len(records),
+		Changed: f.changed,
+	}, nil
+}
+
+// Migrator 由以 JSON 表文件保存数据的后端实现
+type Migrator interface {
+	// Migrate 把全部表文件重写为当前版本，dryRun 为 true 时只返回将要进行的迁移
+	Migrate(ctx context.Context, dryRun bool) ([]MigrationReport, error)
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// legacyUsers 是没有 schema_version 的旧版用户表：user-1 早于邮箱验证功能
+const legacyUsers = `{
+  "user-1": {"id": "user-1", "username": "old", "created": 1700000000},
+  "user-2": {"id": "user-2", "username": "new", "verified": false}
+}`
+
+func TestDecodeTableFile(t *testing.T) {
+	file, err := decodeTableFile("users", []byte(legacyUsers))
+	require.NoError(t, err)
+	assert.Equal(t, 0, file.version)
+	assert.True(t, file.outdated("users"))
+	assert.Equal(t, []string{"user-1"}, file.changed)
+
+	var users map[string]User
+	require.NoError(t, json.Unmarshal(file.records, &users))
+	require.NotNil(t, users["user-1"].Verified)
+	assert.True(t, *users["user-1"].Verified)
+	assert.Equal(t, int64(1700000000), users["user-1"].Created)
+	assert.False(t, *users["user-2"].Verified)
+
+	// 写入当前版本后再读取，不再迁移
+	data, err := encodeTableFile("users", users, "  ")
+	require.NoError(t, err)
+	file, err = decodeTableFile("users", data)
+	require.NoError(t, err)
+	assert.Equal(t, SchemaVersion("users"), file.version)
+	assert.False(t, file.outdated("users"))
+	assert.Empty(t, file.changed)
+
+	// ID 为 schema_version 的旧版记录不会被当作版本号
+	file, err = decodeTableFile("comments", []byte(`{"schema_version": {"id": "schema_version"}, "records": {"id": "records"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, 0, file.version)
+	assert.Contains(t, string(file.records), `"schema_version"`)
+
+	// 新版本程序写入的文件不能被旧程序读取
+	_, err = decodeTableFile("users", []byte(`{"schema_version": 99, "records": {}}`))
+	assert.Error(t, err)
+}
+
+func TestLocalStore_Migrate(t *testing.T) {
+	dir := t.TempDir()
+	usersPath := filepath.Join(dir, "users")
+	require.NoError(t, os.WriteFile(usersPath, []byte(legacyUsers), 0644))
+
+	store := reopenLocalStore(t, dir)
+	defer store.Close()
+
+	// 读取时升级，文件保持不变
+	user, err := store.Get(t.Context(), "user-1")
+	require.NoError(t, err)
+	require.NotNil(t, user.Verified)
+	assert.True(t, *user.Verified)
+
+	// dry-run 在副本上执行，报告将要修改的记录
+	reports, err := store.Migrate(t.Context(), true)
+	require.NoError(t, err)
+	report := findReport(t, reports, "users")
+	assert.Equal(t, 0, report.From)
+	assert.Equal(t, SchemaVersion("users"), report.To)
+	assert.Equal(t, 2, report.Records)
+	assert.Equal(t, []string{"user-1"}, report.Changed)
+
+	content, err := os.ReadFile(usersPath)
+	require.NoError(t, err)
+	assert.Equal(t, legacyUsers, string(content))
+
+	// 正式迁移重写表文件
+	_, err = store.Migrate(t.Context(), false)
+	require.NoError(t, err)
+	content, err = os.ReadFile(usersPath)
+	require.NoError(t, err)
+	file, err := decodeTableFile("users", content)
+	require.NoError(t, err)
+	assert.False(t, file.outdated("users"))
+
+	reports, err = store.Migrate(t.Context(), true)
+	require.NoError(t, err)
+	report = findReport(t, reports, "users")
+	assert.Equal(t, report.To, report.From)
+	assert.Empty(t, report.Changed)
+}
+
+func TestGitHubStore_Migrate(t *testing.T) {
+	fake := newFakeGitHub(t)
+	for _, path := range []string{"orders", "products", "comments"} {
+		fake.setTable(t, "tables/"+path+".json", map[string]any{})
+	}
+	fake.mu.Lock()
+	fake.put("tables/users.json", []byte(legacyUsers))
+	fake.mu.Unlock()
+
+	store := fake.newStore(t)
+
+	head := fake.head
+	reports, err := store.Migrate(t.Context(), true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-1"}, findReport(t, reports, "users").Changed)
+	assert.Equal(t, head, fake.head, "dry-run 不提交")
+
+	_, err = store.Migrate(t.Context(), false)
+	require.NoError(t, err)
+	assert.NotEqual(t, head, fake.head)
+
+	var users map[string]User
+	fake.table(t, "tables/users.json", &users)
+	require.NotNil(t, users["user-1"].Verified)
+	assert.True(t, *users["user-1"].Verified)
+
+	// 已是当前版本的表不再提交
+	head = fake.head
+	_, err = store.Migrate(t.Context(), false)
+	require.NoError(t, err)
+	assert.Equal(t, head, fake.head)
+}
+
+func findReport(t *testing.T, reports []MigrationReport, tableName string) MigrationReport {
+	for _, r := range reports {
+		if r.Table == tableName {
+			return r
+		}
+	}
+	t.Fatalf("没有表 %s 的迁移结果", tableName)
+	return MigrationReport{}
+}
@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration 是一次数据库结构变更，version 单调递增，已执行的版本记录在 schema_migrations 表中
+type migration struct {
+	version    int
+	statements []string
+}
+
+// sqliteMigrations 是 SQLite 存储的全部结构变更，只能追加，不能修改已发布的版本
+var sqliteMigrations = []migration{
+	{
+		version: 1,
+		statements: []string{
+			`CREATE TABLE users (
+				id         TEXT PRIMARY KEY,
+				username   TEXT NOT NULL DEFAULT '',
+				password   TEXT NOT NULL DEFAULT '',
+				email      TEXT NOT NULL DEFAULT '',
+				plan       TEXT NOT NULL DEFAULT '',
+				created    INTEGER NOT NULL DEFAULT 0,
+				updated    INTEGER NOT NULL DEFAULT 0,
+				last_login INTEGER NOT NULL DEFAULT 0,
+				verified   INTEGER
+			)`,
+			`CREATE INDEX idx_users_email ON users (email)`,
+			`CREATE TABLE orders (
+				id           TEXT PRIMARY KEY,
+				user_id      TEXT NOT NULL DEFAULT '',
+				status       TEXT NOT NULL DEFAULT '',
+				currency     TEXT NOT NULL DEFAULT '',
+				products     TEXT NOT NULL DEFAULT 'null',
+				total_amount INTEGER NOT NULL DEFAULT 0,
+				paid_amount  INTEGER NOT NULL DEFAULT 0,
+				description  TEXT NOT NULL DEFAULT '',
+				created      INTEGER NOT NULL DEFAULT 0,
+				updated      INTEGER NOT NULL DEFAULT 0
+			)`,
+			`CREATE INDEX idx_orders_user_id ON orders (user_id, updated DESC)`,
+			`CREATE TABLE products (
+				id          TEXT PRIMARY KEY,
+				name        TEXT NOT NULL DEFAULT '',
+				type        TEXT NOT NULL DEFAULT '',
+				image       TEXT NOT NULL DEFAULT '',
+				description TEXT NOT NULL DEFAULT '',
+				price       INTEGER NOT NULL DEFAULT 0,
+				currency    TEXT NOT NULL DEFAULT '',
+				status      TEXT NOT NULL DEFAULT '',
+				content     TEXT NOT NULL DEFAULT 'null',
+				created     INTEGER NOT NULL DEFAULT 0,
+				updated     INTEGER NOT NULL DEFAULT 0
+			)`,
+			`CREATE INDEX idx_products_name ON products (name)`,
+			`CREATE TABLE comments (
+				id      TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL DEFAULT '',
+				content TEXT NOT NULL DEFAULT '',
+				created INTEGER NOT NULL DEFAULT 0,
+				updated INTEGER NOT NULL DEFAULT 0
+			)`,
+			`CREATE INDEX idx_comments_user_id ON comments (user_id)`,
+		},
+	},
+	{
+		// 与 Indexes 对应的二级索引
+		version: 2,
+		statements: []string{
+			`CREATE INDEX idx_orders_status ON orders (status)`,
+			`CREATE INDEX idx_products_type ON products (type)`,
+		},
+	},
+	{
+		// 软删除：deleted 是删除时间，0 表示未删除
+		version: 3,
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN deleted INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE orders ADD COLUMN deleted INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE products ADD COLUMN deleted INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE comments ADD COLUMN deleted INTEGER NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		// 记录过期：expires 是过期时间，0 表示永不过期
+		version: 4,
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN expires INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE orders ADD COLUMN expires INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE products ADD COLUMN expires INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE comments ADD COLUMN expires INTEGER NOT NULL DEFAULT 0`,
+			`CREATE INDEX idx_users_expires ON users (expires)`,
+			`CREATE INDEX idx_orders_expires ON orders (expires)`,
+			`CREATE INDEX idx_products_expires ON products (expires)`,
+			`CREATE INDEX idx_comments_expires ON comments (expires)`,
+		},
+	},
+}
+
+// postgresMigrations 是 PostgreSQL 存储的全部结构变更，只能追加，不能修改已发布的版本
+var postgresMigrations = []migration{
+	{
+		version: 1,
+		statements: []string{
+			`CREATE TABLE users (
+				id         TEXT PRIMARY KEY,
+				username   TEXT NOT NULL DEFAULT '',
+				password   TEXT NOT NULL DEFAULT '',
+				email      TEXT NOT NULL DEFAULT '',
+				plan       TEXT NOT NULL DEFAULT '',
+				created    BIGINT NOT NULL DEFAULT 0,
+				updated    BIGINT NOT NULL DEFAULT 0,
+				last_login BIGINT NOT NULL DEFAULT 0,
+				verified   BOOLEAN
+			)`,
+			`CREATE INDEX idx_users_email ON users (email)`,
+			`CREATE TABLE orders (
+				id           TEXT PRIMARY KEY,
+				user_id      TEXT NOT NULL DEFAULT '',
+				status       TEXT NOT NULL DEFAULT '',
+				currency     TEXT NOT NULL DEFAULT '',
+				products     TEXT NOT NULL DEFAULT 'null',
+				total_amount BIGINT NOT NULL DEFAULT 0,
+				paid_amount  BIGINT NOT NULL DEFAULT 0,
+				description  TEXT NOT NULL DEFAULT '',
+				created      BIGINT NOT NULL DEFAULT 0,
+				updated      BIGINT NOT NULL DEFAULT 0
+			)`,
+			`CREATE INDEX idx_orders_user_id ON orders (user_id, updated DESC)`,
+			`CREATE TABLE products (
+				id          TEXT PRIMARY KEY,
+				name        TEXT NOT NULL DEFAULT '',
+				type        TEXT NOT NULL DEFAULT '',
+				image       TEXT NOT NULL DEFAULT '',
+				description TEXT NOT NULL DEFAULT '',
+				price       BIGINT NOT NULL DEFAULT 0,
+				currency    TEXT NOT NULL DEFAULT '',
+				status      TEXT NOT NULL DEFAULT '',
+				content     TEXT NOT NULL DEFAULT 'null',
+				created     BIGINT NOT NULL DEFAULT 0,
+				updated     BIGINT NOT NULL DEFAULT 0
+			)`,
+			`CREATE INDEX idx_products_name ON products (name)`,
+			`CREATE TABLE comments (
+				id      TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL DEFAULT '',
+				content TEXT NOT NULL DEFAULT '',
+				created BIGINT NOT NULL DEFAULT 0,
+				updated BIGINT NOT NULL DEFAULT 0
+			)`,
+			`CREATE INDEX idx_comments_user_id ON comments (user_id)`,
+		},
+	},
+	{
+		// 与 Indexes 对应的二级索引
+		version: 2,
+		statements: []string{
+			`CREATE INDEX idx_orders_status ON orders (status)`,
+			`CREATE INDEX idx_products_type ON products (type)`,
+		},
+	},
+	{
+		// 软删除：deleted 是删除时间，0 表示未删除
+		version: 3,
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN deleted BIGINT NOT NULL DEFAULT 0`,
+			`ALTER TABLE orders ADD COLUMN deleted BIGINT NOT NULL DEFAULT 0`,
+			`ALTER TABLE products ADD COLUMN deleted BIGINT NOT NULL DEFAULT 0`,
+			`ALTER TABLE comments ADD COLUMN deleted BIGINT NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		// 记录过期：expires 是过期时间，0 表示永不过期
+		version: 4,
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN expires BIGINT NOT NULL DEFAULT 0`,
+			`ALTER TABLE orders ADD COLUMN expires BIGINT NOT NULL DEFAULT 0`,
+			`ALTER TABLE products ADD COLUMN expires BIGINT NOT NULL DEFAULT 0`,
+			`ALTER TABLE comments ADD COLUMN expires BIGINT NOT NULL DEFAULT 0`,
+			`CREATE INDEX idx_users_expires ON users (expires)`,
+			`CREATE INDEX idx_orders_expires ON orders (expires)`,
+			`CREATE INDEX idx_products_expires ON products (expires)`,
+			`CREATE INDEX idx_comments_expires ON comments (expires)`,
+		},
+	},
+}
+
+// migrate 按版本顺序执行尚未执行的结构变更，每个版本在独立事务中执行
+func migrate(db *sql.DB, dialect sqlDialect, migrations []migration) error {
+	err := migrationTx(db, dialect, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			applied BIGINT NOT NULL
+		)`)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("创建迁移记录表失败: %v", err)
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("读取迁移版本失败: %v", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := migrationTx(db, dialect, func(tx *sql.Tx) error { return applyMigration(tx, dialect, m) }); err != nil {
+			return fmt.Errorf("执行迁移 %d 失败: %v", m.version, err)
+		}
+	}
+
+	return nil
+}
+
+// migrationTx 在事务中执行 fn。方言提供 migrationLock 时先获取该锁，
+// 多个副本同时启动时依次执行迁移，后执行的副本会看到已记录的版本并跳过
+func migrationTx(db *sql.DB, dialect sqlDialect, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if dialect.migrationLock != "" {
+		if _, err := tx.Exec(dialect.migrationLock); err != nil {
+			return err
+		}
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func applyMigration(tx *sql.Tx, dialect sqlDialect, m migration) error {
+	var applied int
+	if err := tx.QueryRow(dialect.rebind(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`),
+		m.version).Scan(&applied); err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	for _, stmt := range m.statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec(dialect.rebind(`INSERT INTO schema_migrations (version, applied) VALUES (?, ?)`),
+		m.version, time.Now().Unix())
+	return err
+}
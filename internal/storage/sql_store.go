@@ -0,0 +1,768 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Axpz/store/internal/config"
+)
+
+// sqlStore 是基于 database/sql 的通用存储实现，SQLiteStore 和 PostgresStore 共用，
+// 方言差异由 sqlDialect 描述。查询统一使用 ? 占位符，执行前按方言转换。
+type sqlStore struct {
+	db *sql.DB
+	// tx 非 nil 时所有语句都在该事务中执行，见 Tx
+	tx      *sql.Tx
+	dialect sqlDialect
+	config  *config.Config
+	// timeout 单次读写的超时，0 表示不限制
+	timeout time.Duration
+	// feed 分发通过本实例写入的变化
+	feed *changeFeed
+	// pending 非 nil 时收集事务中的变化，事务提交后再发布，见 notify
+	pending *[]Change
+}
+
+// sqlDialect 描述不同数据库之间的语法差异
+type sqlDialect struct {
+	// numbered 为 true 时占位符写作 $1, $2 ...
+	numbered bool
+	// rowLocks 为 true 时支持 SELECT ... FOR UPDATE 行锁
+	rowLocks bool
+	// migrationLock 是迁移事务开始时执行的加锁语句，为空表示不加锁
+	migrationLock string
+	// txIsolation 是 Tx 使用的隔离级别
+	txIsolation sql.IsolationLevel
+	// isSerializationFailure 判断事务是否因并发冲突被数据库中止，为 nil 表示不会发生
+	isSerializationFailure func(err error) bool
+}
+
+// rebind 把查询中的 ? 占位符转换为方言的形式
+func (d sqlDialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+const (
+	userColumns    = "id, username, password, email, plan, created, updated, last_login, verified, deleted, expires"
+	orderColumns   = "id, user_id, status, currency, products, total_amount, paid_amount, description, created, updated, deleted, expires"
+	productColumns = "id, name, type, image, description, price, currency, status, content, created, updated, deleted, expires"
+	commentColumns = "id, user_id, content, created, updated, deleted, expires"
+)
+
+// liveCondition 筛选当前可见（未被软删除、未过期）的行，占位符是当前时间（Unix 秒）
+const liveCondition = "deleted = 0 AND (expires = 0 OR expires > ?)"
+
+// sqlTables 是每张表的列和行解析函数，供 List 使用
+var sqlTables = map[string]struct {
+	columns string
+	scan    func(row rowScanner) (any, error)
+}{
+	"users":    {userColumns, func(row rowScanner) (any, error) { return scanUser(row) }},
+	"orders":   {orderColumns, func(row rowScanner) (any, error) { return scanOrder(row) }},
+	"products": {productColumns, func(row rowScanner) (any, error) { return scanProduct(row) }},
+	"comments": {commentColumns, func(row rowScanner) (any, error) { return scanComment(row) }},
+}
+
+// rowScanner 是 *sql.Row 和 *sql.Rows 的公共接口
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// sqlConn 是 *sql.DB 和 *sql.Tx 的公共接口
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Create 创建新用户
+func (s *sqlStore) Create(ctx context.Context, user User) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return s.insert(ctx, "users", userColumns, userArgs(user), user, errAlreadyExists("users", user.ID))
+}
+
+// Get 获取用户
+func (s *sqlStore) Get(ctx context.Context, id string) (User, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	row := s.queryRow(ctx, "SELECT "+userColumns+" FROM users WHERE id = ? AND "+liveCondition, id, time.Now().Unix())
+	user, err := scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, errNotFound("users", id)
+	}
+	return user, err
+}
+
+// Update 更新用户
+func (s *sqlStore) Update(ctx context.Context, user User) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return s.update(ctx, "users", userColumns, userArgs(user), user, errNotFound("users", user.ID))
+}
+
+// Delete 删除用户
+func (s *sqlStore) Delete(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return s.delete(ctx, "users", id, errNotFound("users", id))
+}
+
+// CreateOrder 创建新订单
+func (s *sqlStore) CreateOrder(ctx context.Context, order Order) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	args, err := orderArgs(order)
+	if err != nil {
+		return err
+	}
+	return s.insert(ctx, "orders", orderColumns, args, order, errAlreadyExists("orders", order.ID))
+}
+
+// GetOrder 获取订单
+func (s *sqlStore) GetOrder(ctx context.Context, id string) (Order, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	row := s.queryRow(ctx, "SELECT "+orderColumns+" FROM orders WHERE id = ? AND "+liveCondition, id, time.Now().Unix())
+	order, err := scanOrder(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Order{}, errNotFound("orders", id)
+	}
+	return order, err
+}
+
+// GetOrdersByUserID 获取用户订单，按更新时间倒序排列
+func (s *sqlStore) GetOrdersByUserID(ctx context.Context, userID string) ([]Order, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.query(ctx, "SELECT "+orderColumns+" FROM orders WHERE user_id = ? AND "+liveCondition+" ORDER BY updated DESC",
+		userID, time.Now().Unix())
+	if err != nil {
+		return nil, fmt.Errorf("查询订单失败: %v", err)
+	}
+	defer rows.Close()
+
+	var result []Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, order)
+	}
+	return result, rows.Err()
+}
+
+// UpdateOrder 更新订单。支持行锁的数据库在事务中先用 SELECT ... FOR UPDATE 锁住该行，
+// 多个副本同时更新同一订单时依次执行，存在检查和写入不会被其他事务穿插
+func (s *sqlStore) UpdateOrder(ctx context.Context, order Order) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	args, err := orderArgs(order)
+	if err != nil {
+		return err
+	}
+	if !s.dialect.rowLocks {
+		return s.update(ctx, "orders", orderColumns, args, order, errNotFound("orders", order.ID))
+	}
+
+	return s.inTx(ctx, nil, func(s *sqlStore) error {
+		return s.replaceRow(ctx, "orders", orderColumns, args, order, errNotFound("orders", order.ID))
+	})
+}
+
+// DeleteOrder 删除订单
+func (s *sqlStore) DeleteOrder(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return s.delete(ctx, "orders", id, errNotFound("orders", id))
+}
+
+// CreateProduct 创建新商品
+func (s *sqlStore) CreateProduct(ctx context.Context, product Product) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	args, err := productArgs(product)
+	if err != nil {
+		return err
+	}
+	return s.insert(ctx, "products", productColumns, args, product, errAlreadyExists("products", product.ID))
+}
+
+// GetProduct 获取商品
+func (s *sqlStore) GetProduct(ctx context.Context, id string) (Product, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	row := s.queryRow(ctx, "SELECT "+productColumns+" FROM products WHERE id = ? AND "+liveCondition, id, time.Now().Unix())
+	product, err := scanProduct(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Product{}, errNotFound("products", id)
+	}
+	return product, err
+}
+
+// GetProducts 获取所有商品，按名称排序
+func (s *sqlStore) GetProducts(ctx context.Context) ([]Product, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.query(ctx, "SELECT "+productColumns+" FROM products WHERE "+liveCondition+" ORDER BY name", time.Now().Unix())
+	if err != nil {
+		return nil, fmt.Errorf("查询商品失败: %v", err)
+	}
+	defer rows.Close()
+
+	var products []Product
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	return products, rows.Err()
+}
+
+// UpdateProduct 更新商品
+func (s *sqlStore) UpdateProduct(ctx context.Context, product Product) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	args, err := productArgs(product)
+	if err != nil {
+		return err
+	}
+	return s.update(ctx, "products", productColumns, args, product, errNotFound("products", product.ID))
+}
+
+// DeleteProduct 删除商品
+func (s *sqlStore) DeleteProduct(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return s.delete(ctx, "products", id, errNotFound("products", id))
+}
+
+// CreateComment 创建新评论
+func (s *sqlStore) CreateComment(ctx context.Context, comment Comment) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return s.insert(ctx, "comments", commentColumns, commentArgs(comment), comment, errAlreadyExists("comments", comment.ID))
+}
+
+// GetComment 获取评论
+func (s *sqlStore) GetComment(ctx context.Context, id string) (Comment, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	row := s.queryRow(ctx, "SELECT "+commentColumns+" FROM comments WHERE id = ? AND "+liveCondition, id, time.Now().Unix())
+	comment, err := scanComment(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Comment{}, errNotFound("comments", id)
+	}
+	return comment, err
+}
+
+// UpdateComment 更新评论
+func (s *sqlStore) UpdateComment(ctx context.Context, comment Comment) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return s.update(ctx, "comments", commentColumns, commentArgs(comment), comment, errNotFound("comments", comment.ID))
+}
+
+// DeleteComment 删除评论
+func (s *sqlStore) DeleteComment(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return s.delete(ctx, "comments", id, errNotFound("comments", id))
+}
+
+// List 按 q 查询表 tableName 中的记录，过滤、排序和分页都在数据库中执行。
+// 分页使用 (排序字段, id) 的行比较，配合索引无需扫描之前的页。
+func (s *sqlStore) List(ctx context.Context, tableName string, q Query) (Page, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tq, err := prepareQuery(tableName, q)
+	if err != nil {
+		return Page{}, err
+	}
+	t := sqlTables[tableName]
+
+	var (
+		where []string
+		args  []any
+	)
+	fields := make([]string, 0, len(tq.filter))
+	for field := range tq.filter {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		where = append(where, field+" = ?")
+		args = append(args, tq.filter[field])
+	}
+	if !tq.WithDeleted {
+		where = append(where, liveCondition)
+		args = append(args, tq.now)
+	}
+
+	order, op := "ASC", ">"
+	if tq.desc {
+		order, op = "DESC", "<"
+	}
+	if tq.cursor {
+		where = append(where, fmt.Sprintf("(%s, id) %s (?, ?)", tq.sortField, op))
+		args = append(args, tq.afterValue, tq.afterID)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", t.columns, tableName)
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s", tq.sortField, order, order)
+	if tq.Limit > 0 {
+		// 多取一条，用于判断是否还有下一页
+		query += " LIMIT ?"
+		args = append(args, tq.Limit+1)
+	}
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return Page{}, fmt.Errorf("查询 %s 失败: %v", tableName, err)
+	}
+	defer rows.Close()
+
+	var records []queryRecord
+	for rows.Next() {
+		record, err := t.scan(rows)
+		if err != nil {
+			return Page{}, err
+		}
+		records = append(records, queryRecord{id: tq.value(record, "id").(string), record: record})
+	}
+	if err := rows.Err(); err != nil {
+		return Page{}, err
+	}
+
+	return tq.slice(records)
+}
+
+// Undelete 恢复表 tableName 中被软删除的记录
+func (s *sqlStore) Undelete(ctx context.Context, tableName, id string) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, ok := sqlTables[tableName]; !ok {
+		return fmt.Errorf("未知的表 %s: %w", tableName, ErrInvalidQuery)
+	}
+	notFoundErr := errNotFound(tableName, id)
+	query := fmt.Sprintf("UPDATE %s SET deleted = 0 WHERE id = ? AND deleted <> 0", tableName)
+	if !s.feed.watching(tableName) {
+		return s.execOne(ctx, notFoundErr, query, id)
+	}
+
+	return s.inTx(ctx, nil, func(s *sqlStore) error {
+		if err := s.execOne(ctx, notFoundErr, query, id); err != nil {
+			return err
+		}
+		after, err := s.lookup(ctx, tableName, id, notFoundErr)
+		if err != nil {
+			return err
+		}
+
+		s.notify(Change{Table: tableName, ID: id, Type: ChangeCreate, After: after})
+		return nil
+	})
+}
+
+// Purge 彻底删除表 tableName 中删除时间早于 before 的墓碑
+func (s *sqlStore) Purge(ctx context.Context, tableName string, before time.Time) (int, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, ok := sqlTables[tableName]; !ok {
+		return 0, fmt.Errorf("未知的表 %s: %w", tableName, ErrInvalidQuery)
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE deleted <> 0 AND deleted < ?", tableName)
+	res, err := s.conn().ExecContext(ctx, s.dialect.rebind(query), before.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("清理 %s 失败: %w", tableName, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("清理 %s 失败: %v", tableName, err)
+	}
+	return int(n), nil
+}
+
+// Expire 彻底删除表 tableName 中在 now 时已过期的行。有订阅者关注该表时，
+// 用 DELETE ... RETURNING 取回被删除的行，为其中未被软删除的行发布 ChangeDelete
+func (s *sqlStore) Expire(ctx context.Context, tableName string, now time.Time) (int, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	t, ok := sqlTables[tableName]
+	if !ok {
+		return 0, fmt.Errorf("未知的表 %s: %w", tableName, ErrInvalidQuery)
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE expires <> 0 AND expires <= ?", tableName)
+	if !s.feed.watching(tableName) {
+		res, err := s.conn().ExecContext(ctx, s.dialect.rebind(query), now.Unix())
+		if err != nil {
+			return 0, fmt.Errorf("删除 %s 的过期记录失败: %w", tableName, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("删除 %s 的过期记录失败: %v", tableName, err)
+		}
+		return int(n), nil
+	}
+
+	var removed int
+	err := s.inTx(ctx, nil, func(s *sqlStore) error {
+		rows, err := s.query(ctx, query+" RETURNING "+t.columns, now.Unix())
+		if err != nil {
+			return fmt.Errorf("删除 %s 的过期记录失败: %w", tableName, err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			record, err := t.scan(rows)
+			if err != nil {
+				return err
+			}
+			removed++
+			if deletedAt(record) == 0 {
+				s.notify(Change{Table: tableName, ID: stringField(record, "ID"), Type: ChangeDelete, Before: record})
+			}
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// Tx 在一个数据库事务中执行 fn，fn 返回错误时回滚。
+// 数据库因并发冲突中止事务时返回 ErrConflict，调用方可以重试。
+func (s *sqlStore) Tx(ctx context.Context, fn func(tx Records) error) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return s.inTx(ctx, &sql.TxOptions{Isolation: s.dialect.txIsolation}, func(s *sqlStore) error {
+		return fn(s)
+	})
+}
+
+// inTx 以 opts 开启事务并执行 fn，fn 收到一个绑定到该事务的 sqlStore。
+// s 本身已经在事务中时直接复用，不再开启新事务。
+func (s *sqlStore) inTx(ctx context.Context, opts *sql.TxOptions, fn func(s *sqlStore) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	var changes []Change
+	if err := fn(&sqlStore{db: s.db, tx: tx, dialect: s.dialect, config: s.config, feed: s.feed, pending: &changes}); err != nil {
+		return s.txError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return s.txError(fmt.Errorf("提交事务失败: %w", err))
+	}
+	s.feed.publish(changes...)
+	return nil
+}
+
+// txError 把数据库的并发冲突错误包装为 ErrConflict
+func (s *sqlStore) txError(err error) error {
+	if s.dialect.isSerializationFailure != nil && s.dialect.isSerializationFailure(err) {
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+	return err
+}
+
+// Flush 每次写入都已提交，没有需要落盘的数据
+func (s *sqlStore) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Watch 订阅表 tableName 的变化。只包含通过本实例写入的变化，
+// 其他进程直接写入数据库的修改不会出现在这里
+func (s *sqlStore) Watch(ctx context.Context, tableName string) (<-chan Change, error) {
+	return s.feed.watch(ctx, tableName)
+}
+
+// Close 关闭 Watch 通道和数据库
+func (s *sqlStore) Close() error {
+	s.feed.close()
+	return s.db.Close()
+}
+
+// conn 返回执行语句的连接：事务中为事务本身，否则为连接池
+func (s *sqlStore) conn() sqlConn {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+func (s *sqlStore) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	return s.conn().QueryRowContext(ctx, s.dialect.rebind(query), args...)
+}
+
+func (s *sqlStore) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return s.conn().QueryContext(ctx, s.dialect.rebind(query), args...)
+}
+
+// insert 插入一行（value 是对应的记录），主键已存在时返回 existsErr。同一主键的墓碑或过期的行被新行覆盖
+func (s *sqlStore) insert(ctx context.Context, table, columns string, args []any, value any, existsErr error) error {
+	names := strings.Split(columns, ", ")[1:]
+	for i, name := range names {
+		names[i] = name + " = excluded." + name
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (id) DO UPDATE SET %s "+
+		"WHERE %s.deleted <> 0 OR (%s.expires <> 0 AND %s.expires <= ?)",
+		table, columns, placeholders(len(args)), strings.Join(names, ", "), table, table, table)
+	if err := s.execOne(ctx, existsErr, query, append(args, time.Now().Unix())...); err != nil {
+		return err
+	}
+
+	s.notify(Change{Table: table, ID: args[0].(string), Type: ChangeCreate, After: value})
+	return nil
+}
+
+// update 按 id（args 的第一个元素）把一行更新为 value，行不存在、已被软删除或已过期时返回 notFoundErr。
+// 有订阅者关注该表时，在事务中先读出修改前的行
+func (s *sqlStore) update(ctx context.Context, table, columns string, args []any, value any, notFoundErr error) error {
+	if s.feed.watching(table) {
+		return s.inTx(ctx, nil, func(s *sqlStore) error {
+			return s.replaceRow(ctx, table, columns, args, value, notFoundErr)
+		})
+	}
+
+	query, args := updateQuery(table, columns, args)
+	return s.execOne(ctx, notFoundErr, query, args...)
+}
+
+// replaceRow 读出（支持行锁时锁住）修改前的行，再把它更新为 value，调用方必须在事务中
+func (s *sqlStore) replaceRow(ctx context.Context, table, columns string, args []any, value any, notFoundErr error) error {
+	id := args[0].(string)
+	before, err := s.lookup(ctx, table, id, notFoundErr)
+	if err != nil {
+		return err
+	}
+
+	query, args := updateQuery(table, columns, args)
+	if err := s.execOne(ctx, notFoundErr, query, args...); err != nil {
+		return err
+	}
+
+	s.notify(Change{Table: table, ID: id, Type: ChangeUpdate, Before: before, After: value})
+	return nil
+}
+
+// delete 按 id 软删除一行：把 deleted 设置为当前时间，行不存在、已被软删除或已过期时返回 notFoundErr。
+// 有订阅者关注该表时，在事务中先读出删除前的行
+func (s *sqlStore) delete(ctx context.Context, table, id string, notFoundErr error) error {
+	query := fmt.Sprintf("UPDATE %s SET deleted = ? WHERE id = ? AND %s", table, liveCondition)
+	now := time.Now().Unix()
+	if !s.feed.watching(table) {
+		return s.execOne(ctx, notFoundErr, query, now, id, now)
+	}
+
+	return s.inTx(ctx, nil, func(s *sqlStore) error {
+		before, err := s.lookup(ctx, table, id, notFoundErr)
+		if err != nil {
+			return err
+		}
+		if err := s.execOne(ctx, notFoundErr, query, now, id, now); err != nil {
+			return err
+		}
+
+		s.notify(Change{Table: table, ID: id, Type: ChangeDelete, Before: before})
+		return nil
+	})
+}
+
+// lookup 读取表 table 中的一行，支持行锁的数据库同时锁住该行直到事务结束。
+// 行不存在、已被软删除或已过期时返回 notFoundErr
+func (s *sqlStore) lookup(ctx context.Context, table, id string, notFoundErr error) (any, error) {
+	t := sqlTables[table]
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id = ? AND %s", t.columns, table, liveCondition)
+	if s.dialect.rowLocks {
+		query += " FOR UPDATE"
+	}
+
+	record, err := t.scan(s.queryRow(ctx, query, id, time.Now().Unix()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFoundErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", table, err)
+	}
+	return record, nil
+}
+
+// notify 发布一条变化，事务中先暂存，提交后再发布
+func (s *sqlStore) notify(c Change) {
+	if s.pending != nil {
+		*s.pending = append(*s.pending, c)
+		return
+	}
+	s.feed.publish(c)
+}
+
+// execOne 执行写入语句，没有影响任何行时返回 noRowsErr
+func (s *sqlStore) execOne(ctx context.Context, noRowsErr error, query string, args ...any) error {
+	res, err := s.conn().ExecContext(ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return fmt.Errorf("写入数据库失败: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("写入数据库失败: %v", err)
+	}
+	if n == 0 {
+		return noRowsErr
+	}
+	return nil
+}
+
+// updateQuery 生成按 id（args 的第一个元素）更新一条可见的行的语句，并把 id 参数移到末尾
+func updateQuery(table, columns string, args []any) (string, []any) {
+	names := strings.Split(columns, ", ")[1:]
+	for i, name := range names {
+		names[i] = name + " = ?"
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE id = ? AND %s", table, strings.Join(names, ", "), liveCondition)
+	return query, append(args[1:], args[0], time.Now().Unix())
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func userArgs(u User) []any {
+	var verified sql.NullBool
+	if u.Verified != nil {
+		verified = sql.NullBool{Bool: *u.Verified, Valid: true}
+	}
+	return []any{u.ID, u.Username, u.Password, u.Email, u.Plan, u.Created, u.Updated, u.LastLogin, verified, u.Deleted, u.Expires}
+}
+
+func scanUser(row rowScanner) (User, error) {
+	var (
+		u        User
+		verified sql.NullBool
+	)
+	if err := row.Scan(&u.ID, &u.Username, &u.Password, &u.Email, &u.Plan,
+		&u.Created, &u.Updated, &u.LastLogin, &verified, &u.Deleted, &u.Expires); err != nil {
+		return User{}, err
+	}
+	if verified.Valid {
+		u.Verified = &verified.Bool
+	}
+	return u, nil
+}
+
+func orderArgs(o Order) ([]any, error) {
+	products, err := json.Marshal(o.Products)
+	if err != nil {
+		return nil, fmt.Errorf("序列化订单商品失败: %v", err)
+	}
+	return []any{o.ID, o.UserID, o.Status, o.Currency, string(products),
+		o.TotalAmount, o.PaidAmount, o.Description, o.Created, o.Updated, o.Deleted, o.Expires}, nil
+}
+
+func scanOrder(row rowScanner) (Order, error) {
+	var (
+		o        Order
+		products string
+	)
+	if err := row.Scan(&o.ID, &o.UserID, &o.Status, &o.Currency, &products,
+		&o.TotalAmount, &o.PaidAmount, &o.Description, &o.Created, &o.Updated, &o.Deleted, &o.Expires); err != nil {
+		return Order{}, err
+	}
+	if err := json.Unmarshal([]byte(products), &o.Products); err != nil {
+		return Order{}, fmt.Errorf("解析订单商品失败: %v", err)
+	}
+	return o, nil
+}
+
+func productArgs(p Product) ([]any, error) {
+	content, err := json.Marshal(p.Content)
+	if err != nil {
+		return nil, fmt.Errorf("序列化商品内容失败: %v", err)
+	}
+	return []any{p.ID, p.Name, p.Type, p.Image, p.Description, p.Price,
+		p.Currency, p.Status, string(content), p.Created, p.Updated, p.Deleted, p.Expires}, nil
+}
+
+func scanProduct(row rowScanner) (Product, error) {
+	var (
+		p       Product
+		content string
+	)
+	if err := row.Scan(&p.ID, &p.Name, &p.Type, &p.Image, &p.Description, &p.Price,
+		&p.Currency, &p.Status, &content, &p.Created, &p.Updated, &p.Deleted, &p.Expires); err != nil {
+		return Product{}, err
+	}
+	if err := json.Unmarshal([]byte(content), &p.Content); err != nil {
+		return Product{}, fmt.Errorf("解析商品内容失败: %v", err)
+	}
+	return p, nil
+}
+
+func commentArgs(c Comment) []any {
+	return []any{c.ID, c.UserID, c.Content, c.Created, c.Updated, c.Deleted, c.Expires}
+}
+
+func scanComment(row rowScanner) (Comment, error) {
+	var c Comment
+	if err := row.Scan(&c.ID, &c.UserID, &c.Content, &c.Created, &c.Updated, &c.Deleted, &c.Expires); err != nil {
+		return Comment{}, err
+	}
+	return c, nil
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Axpz/store/internal/config"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore 实现基于嵌入式 SQLite 的存储（纯 Go 驱动，无需 CGO）
+type SQLiteStore struct {
+	sqlStore
+}
+
+// NewSQLiteStore 打开 storage.path 指定的数据库文件，并在启动时执行结构迁移
+func NewSQLiteStore(cfg *config.Config) (StoreInterface, error) {
+	if dir := filepath.Dir(cfg.Storage.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("创建数据库目录失败: %v", err)
+		}
+	}
+
+	// 事务以 BEGIN IMMEDIATE 开始，开始时即取得写锁，
+	// 避免两个事务都先读后写时升级写锁失败（SQLITE_BUSY）
+	dsn := fmt.Sprintf("file:%s?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(FULL)&_txlock=immediate",
+		cfg.Storage.Path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库失败: %v", err)
+	}
+
+	dialect := sqlDialect{}
+	if err := migrate(db, dialect, sqliteMigrations); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{sqlStore{db: db, dialect: dialect, config: cfg, timeout: storageTimeout(cfg, defaultSQLTimeout), feed: newChangeFeed()}}, nil
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/Axpz/store/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestSQLiteStore(t *testing.T, path string) *SQLiteStore {
+	store, err := New(&config.Config{
+		Storage: config.StorageConfig{Type: "sqlite", Path: path},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store.(*SQLiteStore)
+}
+
+func TestSQLiteStore_UserCRUD(t *testing.T) {
+	store := openTestSQLiteStore(t, filepath.Join(t.TempDir(), "store.db"))
+
+	verified := false
+	user := User{ID: "user-1", Username: "Test User", Email: "test@example.com", Verified: &verified}
+	require.NoError(t, store.Create(t.Context(), user))
+	assert.ErrorIs(t, store.Create(t.Context(), user), ErrAlreadyExists)
+
+	got, err := store.Get(t.Context(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user, got)
+
+	// 未设置 Verified 的老用户保持 nil
+	legacy := User{ID: "user-2", Username: "Legacy"}
+	require.NoError(t, store.Create(t.Context(), legacy))
+	got, err = store.Get(t.Context(), legacy.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got.Verified)
+
+	user.Username = "Updated User"
+	require.NoError(t, store.Update(t.Context(), user))
+	got, err = store.Get(t.Context(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user, got)
+
+	require.NoError(t, store.Delete(t.Context(), user.ID))
+	_, err = store.Get(t.Context(), user.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.Update(t.Context(), user), ErrNotFound)
+	assert.ErrorIs(t, store.Delete(t.Context(), user.ID), ErrNotFound)
+}
+
+func TestSQLiteStore_OrdersAndProducts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	store := openTestSQLiteStore(t, path)
+
+	older := Order{
+		ID:       "o-1",
+		UserID:   "user-1",
+		Status:   "pending",
+		Products: []types.OrderProduct{{ID: "p-1", Quantity: 2, Price: 100}},
+		Updated:  1,
+	}
+	newer := Order{ID: "o-2", UserID: "user-1", Updated: 2}
+	other := Order{ID: "o-3", UserID: "user-2", Updated: 3}
+	for _, o := range []Order{older, newer, other} {
+		require.NoError(t, store.CreateOrder(t.Context(), o))
+	}
+	assert.Error(t, store.CreateOrder(t.Context(), older))
+
+	orders, err := store.GetOrdersByUserID(t.Context(), "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, []Order{newer, older}, orders)
+
+	older.Status = "paid"
+	require.NoError(t, store.UpdateOrder(t.Context(), older))
+	require.NoError(t, store.DeleteOrder(t.Context(), newer.ID))
+	assert.Error(t, store.DeleteOrder(t.Context(), newer.ID))
+
+	beta := Product{ID: "p-2", Name: "Beta", Content: []string{"x"}}
+	alpha := Product{ID: "p-1", Name: "Alpha"}
+	require.NoError(t, store.CreateProduct(t.Context(), beta))
+	require.NoError(t, store.CreateProduct(t.Context(), alpha))
+
+	// 重新打开数据库，迁移不会重复执行，数据保留
+	require.NoError(t, store.Close())
+	store = openTestSQLiteStore(t, path)
+
+	got, err := store.GetOrder(t.Context(), older.ID)
+	require.NoError(t, err)
+	assert.Equal(t, older, got)
+
+	products, err := store.GetProducts(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []Product{alpha, beta}, products)
+
+	_, err = store.GetOrder(t.Context(), newer.ID)
+	assert.Error(t, err)
+	assert.Error(t, store.UpdateProduct(t.Context(), Product{ID: "missing"}))
+}
+
+func TestSQLiteStore_CommentCRUD(t *testing.T) {
+	store := openTestSQLiteStore(t, filepath.Join(t.TempDir(), "store.db"))
+
+	comment := Comment{ID: "c-1", UserID: "user-1", Content: "hello"}
+	require.NoError(t, store.CreateComment(t.Context(), comment))
+	assert.ErrorIs(t, store.CreateComment(t.Context(), comment), ErrAlreadyExists)
+
+	comment.Content = "updated"
+	require.NoError(t, store.UpdateComment(t.Context(), comment))
+
+	got, err := store.GetComment(t.Context(), comment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, comment, got)
+
+	require.NoError(t, store.DeleteComment(t.Context(), comment.ID))
+	_, err = store.GetComment(t.Context(), comment.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
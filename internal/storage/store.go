@@ -2,76 +2,284 @@ package storage
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/Axpz/store/internal/config"
-	"github.com/Axpz/store/internal/pkg/throttle"
 	"go.uber.org/zap"
 )
 
 // StoreInterface 定义存储接口
 type StoreInterface interface {
+	Records
+
+	// Tx 在一个事务中执行 fn，fn 通过 tx 读写任意多张表。
+	// fn 返回 nil 时全部修改原子地提交；fn 返回错误或提交失败时全部丢弃，并返回该错误。
+	// fn 中只能通过 tx 访问存储，不能调用存储本身的方法。
+	Tx(ctx context.Context, fn func(tx Records) error) error
+
+	// Watch 订阅表的变化，见 Watcher
+	Watcher
+	// Undelete 和 Purge 恢复、清理软删除的记录，见 Tombstones
+	Tombstones
+	// Expire 删除过期的记录，见 Expiry
+	Expiry
+
+	// Flush 将所有已确认但尚未落盘的写入同步落盘
+	Flush(ctx context.Context) error
+	// Close 停止后台任务并落盘剩余数据，关闭全部 Watch 通道，之后不应再使用该实例
+	Close() error
+}
+
+// Records 是对各表记录的读写操作，既由存储本身提供，也由事务提供。
+// Delete 系列方法是软删除，被删除的记录对其他方法不可见，见 Tombstones
+type Records interface {
 	// 用户相关操作
-	Create(user User) error
-	Get(id string) (User, error)
-	Update(user User) error
-	Delete(id string) error
+	Create(ctx context.Context, user User) error
+	Get(ctx context.Context, id string) (User, error)
+	Update(ctx context.Context, user User) error
+	Delete(ctx context.Context, id string) error
 
 	// 订单相关操作
-	CreateOrder(order Order) error
-	GetOrder(id string) (Order, error)
-	GetOrdersByUserID(userID string) ([]Order, error)
-	UpdateOrder(order Order) error
-	DeleteOrder(id string) error
+	CreateOrder(ctx context.Context, order Order) error
+	GetOrder(ctx context.Context, id string) (Order, error)
+	GetOrdersByUserID(ctx context.Context, userID string) ([]Order, error)
+	UpdateOrder(ctx context.Context, order Order) error
+	DeleteOrder(ctx context.Context, id string) error
 
 	// 商品相关操作
-	CreateProduct(product Product) error
-	GetProduct(id string) (Product, error)
-	GetProducts() ([]Product, error)
-	UpdateProduct(product Product) error
-	DeleteProduct(id string) error
+	CreateProduct(ctx context.Context, product Product) error
+	GetProduct(ctx context.Context, id string) (Product, error)
+	GetProducts(ctx context.Context) ([]Product, error)
+	UpdateProduct(ctx context.Context, product Product) error
+	DeleteProduct(ctx context.Context, id string) error
 
 	// 评论相关操作
-	CreateComment(comment Comment) error
-	GetComment(id string) (Comment, error)
-	UpdateComment(comment Comment) error
-	DeleteComment(id string) error
+	CreateComment(ctx context.Context, comment Comment) error
+	GetComment(ctx context.Context, id string) (Comment, error)
+	UpdateComment(ctx context.Context, comment Comment) error
+	DeleteComment(ctx context.Context, id string) error
+
+	// List 按 q 查询表 tableName 中的记录，表或字段不存在时返回 ErrInvalidQuery
+	List(ctx context.Context, tableName string, q Query) (Page, error)
 }
 
+// Store 是基于内存表的存储后端（LocalStore、GitHubStore、MemoryStore）的公共部分，
+// 实现了 StoreInterface 中的全部读写操作，各后端只需提供 driver。
 type Store struct {
-	mu            sync.RWMutex
-	ctx           context.Context
-	throttlesaver *throttle.Saver
+	mu sync.RWMutex
 
 	config *config.Config
-	loaded map[string]bool
+	driver driver
+	// timeout 单次读写操作的超时，0 表示不限制
+	timeout time.Duration
+	// tables 按表名索引全部表
+	tables map[string]table
+	// feed 分发各表记录的变化，各后端在 Close 时关闭
+	feed *changeFeed
 
 	Tables
 }
 
+// Tables 是 Store 中的全部表。新增实体类型时在这里加一个字段，并在 NewStore 中注册。
 type Tables struct {
-	users    map[string]User
-	orders   map[string]Order
-	products map[string]Product
-	comments map[string]Comment
-}
-
-func NewStore(cfg *config.Config) Store {
-	return Store{
-		mu:            sync.RWMutex{},
-		ctx:           context.Background(),
-		throttlesaver: throttle.NewSaver(10 * time.Second),
-
-		config: cfg,
-		loaded: make(map[string]bool),
-		Tables: Tables{
-			users:    make(map[string]User),
-			comments: make(map[string]Comment),
-		},
+	users    *Table[string, User]
+	orders   *Table[string, Order]
+	products *Table[string, Product]
+	comments *Table[string, Comment]
+}
+
+// TableNames 是 Tables 中全部表的名称，与表文件名、数据库表名一致
+var TableNames = []string{"users", "orders", "products", "comments"}
+
+// NewStore 创建一个使用 d 加载和持久化数据的 Store，每次读写最长执行 timeout
+func NewStore(cfg *config.Config, d driver, timeout time.Duration) *Store {
+	s := &Store{
+		config:  cfg,
+		driver:  d,
+		timeout: timeout,
+		tables:  make(map[string]table),
+		feed:    newChangeFeed(),
 	}
+
+	s.users = newTable(s, tableSpec[string, User]{
+		name: "users",
+		key:  func(u User) string { return u.ID },
+	})
+	s.orders = newTable(s, tableSpec[string, Order]{
+		name: "orders",
+		key:  func(o Order) string { return o.ID },
+	})
+	s.products = newTable(s, tableSpec[string, Product]{
+		name: "products",
+		key:  func(p Product) string { return p.ID },
+	})
+	s.comments = newTable(s, tableSpec[string, Comment]{
+		name: "comments",
+		key:  func(c Comment) string { return c.ID },
+	})
+
+	return s
 }
 
 func (s *Store) Logger() *zap.Logger {
+	if s.config.Logger == nil {
+		return zap.NewNop()
+	}
 	return s.config.Logger
 }
+
+// table 按表名返回表，未知的表返回 ErrInvalidQuery
+func (s *Store) table(tableName string) (table, error) {
+	t, ok := s.tables[tableName]
+	if !ok {
+		return nil, fmt.Errorf("未知的表 %s: %w", tableName, ErrInvalidQuery)
+	}
+	return t, nil
+}
+
+// Create 创建新用户
+func (s *Store) Create(ctx context.Context, user User) error {
+	return s.users.Insert(ctx, user)
+}
+
+// Get 获取用户
+func (s *Store) Get(ctx context.Context, id string) (User, error) {
+	return s.users.Get(ctx, id)
+}
+
+// Update 更新用户
+func (s *Store) Update(ctx context.Context, user User) error {
+	return s.users.Update(ctx, user)
+}
+
+// Delete 删除用户
+func (s *Store) Delete(ctx context.Context, id string) error {
+	return s.users.Delete(ctx, id)
+}
+
+// CreateOrder 创建新订单
+func (s *Store) CreateOrder(ctx context.Context, order Order) error {
+	return s.orders.Insert(ctx, order)
+}
+
+// GetOrder 获取订单
+func (s *Store) GetOrder(ctx context.Context, id string) (Order, error) {
+	return s.orders.Get(ctx, id)
+}
+
+// GetOrdersByUserID 通过 user_id 索引获取用户订单，按更新时间倒序排列
+func (s *Store) GetOrdersByUserID(ctx context.Context, userID string) ([]Order, error) {
+	page, err := s.orders.Query(ctx, Query{Filter: map[string]string{"user_id": userID}, Sort: "-updated"})
+	if err != nil {
+		return nil, err
+	}
+	return DecodePage[Order](page)
+}
+
+// UpdateOrder 更新订单
+func (s *Store) UpdateOrder(ctx context.Context, order Order) error {
+	return s.orders.Update(ctx, order)
+}
+
+// DeleteOrder 删除订单
+func (s *Store) DeleteOrder(ctx context.Context, id string) error {
+	return s.orders.Delete(ctx, id)
+}
+
+// CreateProduct 创建新商品
+func (s *Store) CreateProduct(ctx context.Context, product Product) error {
+	return s.products.Insert(ctx, product)
+}
+
+// GetProduct 获取商品
+func (s *Store) GetProduct(ctx context.Context, id string) (Product, error) {
+	return s.products.Get(ctx, id)
+}
+
+// GetProducts 获取所有商品，按名称排序
+func (s *Store) GetProducts(ctx context.Context) ([]Product, error) {
+	products, err := s.products.List(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].Name < products[j].Name
+	})
+
+	return products, nil
+}
+
+// UpdateProduct 更新商品
+func (s *Store) UpdateProduct(ctx context.Context, product Product) error {
+	return s.products.Update(ctx, product)
+}
+
+// DeleteProduct 删除商品
+func (s *Store) DeleteProduct(ctx context.Context, id string) error {
+	return s.products.Delete(ctx, id)
+}
+
+// CreateComment 创建新评论
+func (s *Store) CreateComment(ctx context.Context, comment Comment) error {
+	return s.comments.Insert(ctx, comment)
+}
+
+// GetComment 获取评论
+func (s *Store) GetComment(ctx context.Context, id string) (Comment, error) {
+	return s.comments.Get(ctx, id)
+}
+
+// UpdateComment 更新评论
+func (s *Store) UpdateComment(ctx context.Context, comment Comment) error {
+	return s.comments.Update(ctx, comment)
+}
+
+// DeleteComment 删除评论
+func (s *Store) DeleteComment(ctx context.Context, id string) error {
+	return s.comments.Delete(ctx, id)
+}
+
+// Watch 订阅表 tableName 的变化
+func (s *Store) Watch(ctx context.Context, tableName string) (<-chan Change, error) {
+	return s.feed.watch(ctx, tableName)
+}
+
+// Undelete 恢复表 tableName 中被软删除的记录
+func (s *Store) Undelete(ctx context.Context, tableName, id string) error {
+	t, err := s.table(tableName)
+	if err != nil {
+		return err
+	}
+	return t.undelete(ctx, id)
+}
+
+// Purge 彻底删除表 tableName 中删除时间早于 before 的墓碑
+func (s *Store) Purge(ctx context.Context, tableName string, before time.Time) (int, error) {
+	t, err := s.table(tableName)
+	if err != nil {
+		return 0, err
+	}
+	return t.purge(ctx, before)
+}
+
+// Expire 彻底删除表 tableName 中在 now 时已过期的记录
+func (s *Store) Expire(ctx context.Context, tableName string, now time.Time) (int, error) {
+	t, err := s.table(tableName)
+	if err != nil {
+		return 0, err
+	}
+	return t.expire(ctx, now)
+}
+
+// List 按 q 查询表 tableName 中的记录
+func (s *Store) List(ctx context.Context, tableName string, q Query) (Page, error) {
+	t, err := s.table(tableName)
+	if err != nil {
+		return Page{}, err
+	}
+	return t.query(ctx, q)
+}
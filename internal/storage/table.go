@@ -0,0 +1,523 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// driver 负责表的加载和持久化，由各存储后端实现。
+// Table 在持有存储写锁时调用这些方法。
+type driver interface {
+	// loadTable 首次访问表时调用，把持久化的数据读入 data（指向 map 的指针）
+	loadTable(ctx context.Context, tableName string, data any) error
+	// beforePut 在内存表写入记录之前调用，返回错误时放弃本次写入
+	beforePut(ctx context.Context, tableName, id string, value any) error
+	// beforeDelete 在内存表删除记录之前调用，返回错误时放弃本次删除
+	beforeDelete(ctx context.Context, tableName, id string) error
+	// saveTable 在内存表修改之后调用，data 指向 map
+	saveTable(ctx context.Context, tableName string, data any) error
+	// commitTx 在事务的修改应用到内存表之前调用，负责把 changes 作为一个整体持久化。
+	// tables 是被修改的表提交后的完整数据（表名 → 指向 map 的指针），归事务所有，不需要加锁读取。
+	// 提交需要访问网络的实现（GitHubStore）可以在此期间暂时释放写锁，返回前重新加锁，
+	// 因此返回时内存表可能已被其他写入修改。
+	// 返回错误时事务被丢弃，内存表保持不变；成功后 Tx 按记录应用修改，再对每张被修改的表调用 saveTable。
+	commitTx(ctx context.Context, changes []txChange, tables map[string]any) error
+}
+
+// Table 是一张以 K 为主键、V 为记录的内存表。
+// 读写由所属 Store 的锁保护，加载和持久化委托给 driver，
+// 因此所有基于 Store 的后端对每张表都有相同的语义。
+type Table[K ~string, V any] struct {
+	name    string
+	key     func(V) K
+	mu      *sync.RWMutex
+	driver  driver
+	timeout time.Duration
+	// feed 分发记录的变化
+	feed *changeFeed
+
+	loaded bool
+	rows   map[K]V
+
+	// fields 是 V 中可查询的字段
+	fields queryFields
+	// indexes 是二级索引：字段 → 字段值 → 主键集合。
+	// indexed 为 false 时索引可能过期，下次按索引查询前在写锁下重建
+	indexes map[string]map[string]map[K]struct{}
+	indexed bool
+}
+
+// tableSpec 描述一张表的名称和主键
+type tableSpec[K ~string, V any] struct {
+	name string
+	key  func(V) K
+}
+
+// newTable 创建一张表并注册到 s，s.driver 必须已设置
+func newTable[K ~string, V any](s *Store, spec tableSpec[K, V]) *Table[K, V] {
+	t := &Table[K, V]{
+		name:    spec.name,
+		key:     spec.key,
+		mu:      &s.mu,
+		driver:  s.driver,
+		timeout: s.timeout,
+		feed:    s.feed,
+		rows:    make(map[K]V),
+		fields:  fieldsOf(reflect.TypeFor[V]()),
+	}
+	s.tables[spec.name] = t
+	return t
+}
+
+// Insert 写入一条新记录，主键已存在时返回 ErrAlreadyExists。同一主键的墓碑或过期记录被新记录覆盖
+func (t *Table[K, V]) Insert(ctx context.Context, value V) error {
+	ctx, cancel := withTimeout(ctx, t.timeout)
+	defer cancel()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.load(ctx); err != nil {
+		return err
+	}
+
+	id := t.key(value)
+	if t.live(id) {
+		return errAlreadyExists(t.name, string(id))
+	}
+
+	return t.put(ctx, id, value)
+}
+
+// Get 按主键读取记录，不存在、已被软删除或已过期时返回 ErrNotFound
+func (t *Table[K, V]) Get(ctx context.Context, id K) (V, error) {
+	var result V
+
+	err := t.read(ctx, false, func() error {
+		if !t.live(id) {
+			return errNotFound(t.name, string(id))
+		}
+		result = t.rows[id]
+		return nil
+	})
+
+	return result, err
+}
+
+// Update 覆盖一条已有记录，不存在、已被软删除或已过期时返回 ErrNotFound
+func (t *Table[K, V]) Update(ctx context.Context, value V) error {
+	ctx, cancel := withTimeout(ctx, t.timeout)
+	defer cancel()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.load(ctx); err != nil {
+		return err
+	}
+
+	id := t.key(value)
+	if !t.live(id) {
+		return errNotFound(t.name, string(id))
+	}
+
+	return t.put(ctx, id, value)
+}
+
+// Delete 软删除一条记录：把记录的 Deleted 设置为当前时间，不存在、已被软删除或已过期时返回 ErrNotFound
+func (t *Table[K, V]) Delete(ctx context.Context, id K) error {
+	ctx, cancel := withTimeout(ctx, t.timeout)
+	defer cancel()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.load(ctx); err != nil {
+		return err
+	}
+
+	if !t.live(id) {
+		return errNotFound(t.name, string(id))
+	}
+
+	return t.put(ctx, id, setDeleted(t.rows[id], time.Now().Unix()).(V))
+}
+
+// Undelete 恢复一条被软删除的记录，记录不存在或未被删除时返回 ErrNotFound
+func (t *Table[K, V]) Undelete(ctx context.Context, id K) error {
+	ctx, cancel := withTimeout(ctx, t.timeout)
+	defer cancel()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.load(ctx); err != nil {
+		return err
+	}
+
+	value, exists := t.rows[id]
+	if !exists || deletedAt(value) == 0 {
+		return errNotFound(t.name, string(id))
+	}
+
+	return t.put(ctx, id, setDeleted(value, 0).(V))
+}
+
+// Purge 彻底删除删除时间早于 before 的墓碑，返回删除的记录数
+func (t *Table[K, V]) Purge(ctx context.Context, before time.Time) (int, error) {
+	return t.remove(ctx, func(value V) bool {
+		at := deletedAt(value)
+		return at != 0 && at < before.Unix()
+	})
+}
+
+// Expire 彻底删除在 now 时已过期的记录，返回删除的记录数。未被软删除的记录发布 ChangeDelete
+func (t *Table[K, V]) Expire(ctx context.Context, now time.Time) (int, error) {
+	return t.remove(ctx, func(value V) bool {
+		return expired(value, now.Unix())
+	})
+}
+
+// remove 彻底删除 match 返回 true 的全部记录，返回删除的记录数
+func (t *Table[K, V]) remove(ctx context.Context, match func(V) bool) (int, error) {
+	ctx, cancel := withTimeout(ctx, t.timeout)
+	defer cancel()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.load(ctx); err != nil {
+		return 0, err
+	}
+
+	var ids []K
+	for id, value := range t.rows {
+		if match(value) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if err := t.driver.beforeDelete(ctx, t.name, string(id)); err != nil {
+			return 0, err
+		}
+	}
+	var (
+		changes []Change
+		removed = make(map[K]V, len(ids))
+	)
+	for _, id := range ids {
+		old := t.rows[id]
+		removed[id] = old
+		var zero V
+		t.set(id, zero, false)
+		if change, ok := newChange(t.name, string(id), old, true, zero, false); ok {
+			changes = append(changes, change)
+		}
+	}
+	if err := t.driver.saveTable(ctx, t.name, &t.rows); err != nil {
+		// 保存失败时恢复删除的记录，内存表与持久化的状态保持一致
+		for id, old := range removed {
+			t.set(id, old, true)
+		}
+		return 0, err
+	}
+
+	t.feed.publish(changes...)
+	return len(ids), nil
+}
+
+// List 返回 keep 返回 true 的全部记录（不含墓碑和过期记录），keep 为 nil 时返回全部记录，顺序不确定
+func (t *Table[K, V]) List(ctx context.Context, keep func(V) bool) ([]V, error) {
+	var result []V
+
+	err := t.read(ctx, false, func() error {
+		now := time.Now().Unix()
+		for _, value := range t.rows {
+			if !visible(value, now) {
+				continue
+			}
+			if keep == nil || keep(value) {
+				result = append(result, value)
+			}
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+func (t *Table[K, V]) put(ctx context.Context, id K, value V) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := t.driver.beforePut(ctx, t.name, string(id), value); err != nil {
+		return err
+	}
+
+	old, existed := t.rows[id]
+	t.set(id, value, true)
+	if err := t.driver.saveTable(ctx, t.name, &t.rows); err != nil {
+		// 保存失败时恢复原来的记录，内存表与持久化的状态保持一致
+		t.set(id, old, existed)
+		return err
+	}
+
+	change, _ := newChange(t.name, string(id), old, existed, value, true)
+	t.feed.publish(change)
+	return nil
+}
+
+// set 写入（ok 为 true）或删除主键 id 的记录并维护索引，调用方必须持有写锁
+func (t *Table[K, V]) set(id K, value V, ok bool) {
+	old, existed := t.rows[id]
+	if t.indexed {
+		if existed {
+			t.unindex(id, old)
+		}
+		if ok {
+			t.index(id, value)
+		}
+	}
+	if ok {
+		t.rows[id] = value
+	} else {
+		delete(t.rows, id)
+	}
+}
+
+// live 判断主键 id 的记录存在且当前可见（未被软删除、未过期），调用方必须持有锁
+func (t *Table[K, V]) live(id K) bool {
+	value, exists := t.rows[id]
+	return exists && visible(value, time.Now().Unix())
+}
+
+// Query 按 q 过滤、排序并分页。过滤条件包含索引字段时只检查索引命中的记录
+func (t *Table[K, V]) Query(ctx context.Context, q Query) (Page, error) {
+	tq, err := prepareQuery(t.name, q)
+	if err != nil {
+		return Page{}, err
+	}
+
+	var page Page
+	err = t.read(ctx, true, func() error {
+		var records []queryRecord
+		add := func(id K, value V) {
+			if tq.match(value) {
+				records = append(records, queryRecord{id: string(id), record: value})
+			}
+		}
+
+		if field, value, ok := tq.indexedFilter(t.name); ok {
+			for id := range t.indexes[field][indexKey(value)] {
+				add(id, t.rows[id])
+			}
+		} else {
+			for id, value := range t.rows {
+				add(id, value)
+			}
+		}
+
+		var err error
+		page, err = tq.page(records)
+		return err
+	})
+
+	return page, err
+}
+
+// read 在读锁下执行 fn。表尚未加载（或 indexed 为 true 且索引需要重建）时先在写锁下准备好，
+// 避免多个读者在读锁下同时修改内存表。
+func (t *Table[K, V]) read(ctx context.Context, indexed bool, fn func() error) error {
+	ctx, cancel := withTimeout(ctx, t.timeout)
+	defer cancel()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	t.mu.RLock()
+	if t.loaded && (!indexed || t.indexed) {
+		defer t.mu.RUnlock()
+		return fn()
+	}
+	t.mu.RUnlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.load(ctx); err != nil {
+		return err
+	}
+	if indexed && !t.indexed {
+		t.reindex()
+	}
+	return fn()
+}
+
+// reindex 重建全部二级索引，调用方必须持有写锁
+func (t *Table[K, V]) reindex() {
+	t.indexes = make(map[string]map[string]map[K]struct{})
+	for _, field := range tableIndexes(t.name) {
+		t.indexes[field] = make(map[string]map[K]struct{})
+	}
+	for id, value := range t.rows {
+		t.index(id, value)
+	}
+	t.indexed = true
+}
+
+func (t *Table[K, V]) index(id K, value V) {
+	for field, values := range t.indexes {
+		key := indexKey(scalar(reflect.ValueOf(value).Field(t.fields[field])))
+		if values[key] == nil {
+			values[key] = make(map[K]struct{})
+		}
+		values[key][id] = struct{}{}
+	}
+}
+
+func (t *Table[K, V]) unindex(id K, value V) {
+	for field, values := range t.indexes {
+		key := indexKey(scalar(reflect.ValueOf(value).Field(t.fields[field])))
+		delete(values[key], id)
+		if len(values[key]) == 0 {
+			delete(values, key)
+		}
+	}
+}
+
+// load 首次访问时加载表，调用方必须持有写锁
+func (t *Table[K, V]) load(ctx context.Context) error {
+	if t.loaded {
+		return nil
+	}
+
+	if err := t.driver.loadTable(ctx, t.name, &t.rows); err != nil {
+		return fmt.Errorf("加载表 %s 失败: %w", t.name, err)
+	}
+
+	t.loaded = true
+	return nil
+}
+
+// table 是 Table 的非泛型视图，供按表名处理所有表的代码使用（压缩、提交、刷新）
+type table interface {
+	// ensureLoaded 确保表已加载，调用方必须持有写锁
+	ensureLoaded(ctx context.Context) error
+	// data 返回指向内存 map 的指针，调用方必须持有写锁；
+	// 调用方可能通过该指针修改内存表，索引随之标记为过期
+	data() any
+	// records 按记录 ID 返回内存表的规范化 JSON，不修改内存表和索引，调用方持有读锁即可
+	records() (map[string]json.RawMessage, error)
+	// empty 返回一张与内存表同类型的空表（指向 map 的指针）
+	empty() any
+	// copyTo 把内存表的浅拷贝写入 data（指向 map 的指针），调用方必须持有锁
+	copyTo(data any)
+	// merge 把 data（指向 map 的指针）中 ids 的记录写入内存表，data 中没有的记录从内存表删除，
+	// 其他记录保持不变，调用方必须持有写锁
+	merge(data any, ids []string)
+	// query 按条件查询记录
+	query(ctx context.Context, q Query) (Page, error)
+	// undelete 和 purge 见 Tombstones
+	undelete(ctx context.Context, id string) error
+	purge(ctx context.Context, before time.Time) (int, error)
+	// expire 见 Expiry
+	expire(ctx context.Context, now time.Time) (int, error)
+	// track 执行 apply（整体替换或修改内存表），返回 ids 中记录的变化，调用方必须持有写锁。
+	// 返回的变化尚未分配序号，由调用方在修改持久化之后发布
+	track(ids []string, apply func() error) ([]Change, error)
+}
+
+func (t *Table[K, V]) ensureLoaded(ctx context.Context) error {
+	return t.load(ctx)
+}
+
+func (t *Table[K, V]) data() any {
+	t.indexed = false
+	return &t.rows
+}
+
+func (t *Table[K, V]) records() (map[string]json.RawMessage, error) {
+	return toRecords(t.rows)
+}
+
+func (t *Table[K, V]) empty() any {
+	return &map[K]V{}
+}
+
+func (t *Table[K, V]) copyTo(data any) {
+	rows := maps.Clone(t.rows)
+	if rows == nil {
+		rows = make(map[K]V)
+	}
+	*data.(*map[K]V) = rows
+}
+
+func (t *Table[K, V]) replace(data any) {
+	t.rows = *data.(*map[K]V)
+	t.indexed = false
+}
+
+func (t *Table[K, V]) merge(data any, ids []string) {
+	rows := *data.(*map[K]V)
+	for _, id := range ids {
+		value, ok := rows[K(id)]
+		t.set(K(id), value, ok)
+	}
+}
+
+func (t *Table[K, V]) query(ctx context.Context, q Query) (Page, error) {
+	return t.Query(ctx, q)
+}
+
+func (t *Table[K, V]) undelete(ctx context.Context, id string) error {
+	return t.Undelete(ctx, K(id))
+}
+
+func (t *Table[K, V]) purge(ctx context.Context, before time.Time) (int, error) {
+	return t.Purge(ctx, before)
+}
+
+func (t *Table[K, V]) expire(ctx context.Context, now time.Time) (int, error) {
+	return t.Expire(ctx, now)
+}
+
+func (t *Table[K, V]) track(ids []string, apply func() error) ([]Change, error) {
+	type row struct {
+		value  V
+		exists bool
+	}
+	before := make(map[string]row, len(ids))
+	for _, id := range ids {
+		value, exists := t.rows[K(id)]
+		before[id] = row{value, exists}
+	}
+
+	if err := apply(); err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for _, id := range ids {
+		old := before[id]
+		value, exists := t.rows[K(id)]
+		if change, ok := newChange(t.name, id, old.value, old.exists, value, exists); ok {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
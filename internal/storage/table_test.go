@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTable_SameSemanticsAcrossBackends 所有基于 Store 的后端对同一操作返回相同的结果和错误
+func TestTable_SameSemanticsAcrossBackends(t *testing.T) {
+	backends := map[string]func(t *testing.T) StoreInterface{
+		"local": func(t *testing.T) StoreInterface {
+			store, err := NewLocalStore(&config.Config{
+				Storage: config.StorageConfig{Type: "local", Path: t.TempDir()},
+			})
+			require.NoError(t, err)
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+		"github": func(t *testing.T) StoreInterface {
+			fake := newFakeGitHub(t)
+			for _, path := range []string{"users", "orders", "products", "comments"} {
+				fake.setTable(t, "tables/"+path+".json", map[string]any{})
+			}
+			return fake.newStore(t)
+		},
+		"github-records": func(t *testing.T) StoreInterface {
+			return newFakeGitHub(t).newRecordStore(t, 0)
+		},
+		"memory": func(t *testing.T) StoreInterface {
+			store, err := NewMemoryStore(&config.Config{})
+			require.NoError(t, err)
+			return store
+		},
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+
+			user := User{ID: "user-1", Username: "Test"}
+			require.NoError(t, store.Create(t.Context(), user))
+			assert.ErrorIs(t, store.Create(t.Context(), user), ErrAlreadyExists)
+			assert.ErrorIs(t, store.Update(t.Context(), User{ID: "missing"}), ErrNotFound)
+			assert.ErrorIs(t, store.Delete(t.Context(), "missing"), ErrNotFound)
+
+			comment := Comment{ID: "c-1"}
+			require.NoError(t, store.CreateComment(t.Context(), comment))
+			assert.ErrorIs(t, store.CreateComment(t.Context(), comment), ErrAlreadyExists)
+			_, err := store.GetComment(t.Context(), "missing")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			require.NoError(t, store.CreateOrder(t.Context(), Order{ID: "o-1", UserID: user.ID}))
+			assert.ErrorIs(t, store.CreateOrder(t.Context(), Order{ID: "o-1"}), ErrAlreadyExists)
+			_, err = store.GetOrder(t.Context(), "missing")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			assert.ErrorIs(t, store.DeleteProduct(t.Context(), "missing"), ErrNotFound)
+		})
+	}
+}
+
+// TestTable_ConcurrentFirstRead 多个读者同时触发首次加载时，加载在写锁下进行（配合 -race 运行）
+func TestTable_ConcurrentFirstRead(t *testing.T) {
+	fake := newFakeGitHub(t)
+	user := User{ID: "user-1", Username: "Remote"}
+	fake.setTable(t, "tables/users.json", map[string]User{user.ID: user})
+
+	store := fake.newStore(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := store.Get(t.Context(), user.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, user, got)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTable_ContextCanceled(t *testing.T) {
+	store, err := NewMemoryStore(&config.Config{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	assert.ErrorIs(t, store.Create(ctx, User{ID: "user-1"}), context.Canceled)
+	_, err = store.Get(t.Context(), "user-1")
+	assert.ErrorIs(t, err, ErrNotFound, "取消的写入不应生效")
+}
+
+func TestTable_Timeout(t *testing.T) {
+	fake := newFakeGitHub(t)
+	fake.setTable(t, "tables/users.json", map[string]User{})
+	fake.delay = time.Minute
+
+	store := fake.newStore(t)
+	store.users.timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err := store.Get(t.Context(), "user-1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestStore_UnknownTable(t *testing.T) {
+	store, err := NewMemoryStore(&config.Config{})
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	_, err = store.(*MemoryStore).table("missing")
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+	assert.ErrorIs(t, store.Undelete(t.Context(), "missing", "id"), ErrInvalidQuery)
+	_, err = store.Purge(t.Context(), "missing", time.Now())
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+	_, err = store.Expire(t.Context(), "missing", time.Now())
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+	_, err = store.List(t.Context(), "missing", Query{})
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+}
+
+// saveFailingDriver 在 saveTable 时返回 err，其他操作与内存存储相同
+type saveFailingDriver struct {
+	*MemoryStore
+	err error
+}
+
+func (d *saveFailingDriver) saveTable(ctx context.Context, tableName string, data any) error {
+	return d.err
+}
+
+func TestTable_SaveFailureLeavesTableUnchanged(t *testing.T) {
+	mem, err := NewMemoryStore(&config.Config{})
+	require.NoError(t, err)
+	d := &saveFailingDriver{MemoryStore: mem.(*MemoryStore)}
+	store := NewStore(&config.Config{}, d, 0)
+
+	order := Order{ID: "o-1", UserID: "user-1", Status: "pending"}
+	require.NoError(t, store.CreateOrder(t.Context(), order))
+
+	d.err = errors.New("disk full")
+	assert.ErrorIs(t, store.CreateOrder(t.Context(), Order{ID: "o-2", UserID: "user-1"}), d.err)
+	assert.ErrorIs(t, store.UpdateOrder(t.Context(), Order{ID: "o-1", UserID: "user-2"}), d.err)
+	assert.ErrorIs(t, store.DeleteOrder(t.Context(), "o-1"), d.err)
+
+	// 内存表和索引都保持保存失败之前的状态
+	_, err = store.GetOrder(t.Context(), "o-2")
+	assert.ErrorIs(t, err, ErrNotFound)
+	got, err := store.GetOrder(t.Context(), "o-1")
+	require.NoError(t, err)
+	assert.Equal(t, order, got)
+	for userID, want := range map[string]int{"user-1": 1, "user-2": 0} {
+		page, err := store.List(t.Context(), "orders", Query{Filter: map[string]string{"user_id": userID}})
+		require.NoError(t, err)
+		assert.Len(t, page.Items, want, userID)
+	}
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"time"
+
+	"github.com/Axpz/store/internal/config"
+)
+
+// 各后端单次读写的默认超时，可通过 storage.timeout 覆盖
+const (
+	defaultGitHubTimeout = 30 * time.Second
+	defaultLocalTimeout  = 5 * time.Second
+	defaultSQLTimeout    = 5 * time.Second
+	defaultBoltTimeout   = 5 * time.Second
+)
+
+// storageTimeout 返回当前后端单次读写的超时：配置了 storage.timeout 时使用配置值，否则使用 def
+func storageTimeout(cfg *config.Config, def time.Duration) time.Duration {
+	return cmp.Or(cfg.Storage.Timeout, def)
+}
+
+// withTimeout 为 ctx 加上超时，timeout 不大于 0 时只继承 ctx 的取消
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// ctxErr 与 ctx.Err 相同，但截止时间已过、取消还没有生效时也返回 context.DeadlineExceeded。
+// 超时的取消由计时器在另一个 goroutine 中执行，决定是否提交时不能只看 ctx.Err
+func ctxErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// Tombstones 由支持软删除的存储实现（全部后端）。
+//
+// Delete 系列方法不直接删除记录，而是把记录的 Deleted 设置为删除时间，留下一个墓碑。
+// 墓碑对 Get、Update、Delete 和默认的查询不可见，Create 可以用同一主键覆盖它；
+// Undelete 把墓碑恢复为正常记录，Purge 才真正删除记录。
+type Tombstones interface {
+	// Undelete 恢复表 tableName 中被软删除的记录 id，记录不存在或未被删除时返回 ErrNotFound
+	Undelete(ctx context.Context, tableName, id string) error
+	// Purge 彻底删除表 tableName 中删除时间早于 before 的墓碑，返回删除的记录数
+	Purge(ctx context.Context, tableName string, before time.Time) (int, error)
+}
+
+// deletedAt 返回记录的软删除时间，0 表示未删除。记录没有 Deleted 字段时返回 0
+func deletedAt(record any) int64 {
+	return int64Field(record, "Deleted")
+}
+
+// int64Field 返回记录中名为 name 的 int64 字段，记录没有该字段时返回 0
+func int64Field(record any, name string) int64 {
+	f := recordField(record, name, reflect.Int64)
+	if !f.IsValid() {
+		return 0
+	}
+	return f.Int()
+}
+
+// stringField 返回记录中名为 name 的字符串字段，记录没有该字段时返回空字符串
+func stringField(record any, name string) string {
+	f := recordField(record, name, reflect.String)
+	if !f.IsValid() {
+		return ""
+	}
+	return f.String()
+}
+
+// recordField 返回记录（结构体或其指针）中名为 name、类型为 kind 的字段，不存在时返回无效的 Value
+func recordField(record any, name string, kind reflect.Kind) reflect.Value {
+	v := reflect.ValueOf(record)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	f := v.FieldByName(name)
+	if !f.IsValid() || f.Kind() != kind {
+		return reflect.Value{}
+	}
+	return f
+}
+
+// setDeleted 返回 Deleted 设置为 at 的记录副本，at 为 0 表示恢复。record 是记录的值（User、Order 等）
+func setDeleted(record any, at int64) any {
+	v := reflect.New(reflect.TypeOf(record)).Elem()
+	v.Set(reflect.ValueOf(record))
+	if f := v.FieldByName("Deleted"); f.IsValid() && f.Kind() == reflect.Int64 {
+		f.SetInt(at)
+	}
+	return v.Interface()
+}
+
+// recordTimes 是记录 JSON 中的软删除和过期时间
+type recordTimes struct {
+	Deleted int64 `json:"deleted"`
+	Expires int64 `json:"expires"`
+}
+
+// rawTimes 读取记录 JSON 中的软删除和过期时间，解析失败时都为 0
+func rawTimes(data []byte) recordTimes {
+	var times recordTimes
+	if err := json.Unmarshal(data, &times); err != nil {
+		return recordTimes{}
+	}
+	return times
+}
+
+// rawDeletedAt 返回记录 JSON 中的软删除时间，0 表示未删除
+func rawDeletedAt(data []byte) int64 {
+	return rawTimes(data).Deleted
+}
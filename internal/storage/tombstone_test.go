@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTombstones_AcrossBackends(t *testing.T) {
+	for name, open := range txBackends() {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			first := Order{ID: "o-1", UserID: "user-1", Status: "pending"}
+			second := Order{ID: "o-2", UserID: "user-1", Status: "pending"}
+			require.NoError(t, store.CreateOrder(t.Context(), first))
+			require.NoError(t, store.CreateOrder(t.Context(), second))
+			require.NoError(t, store.CreateProduct(t.Context(), Product{ID: "p-1", Name: "Widget"}))
+
+			require.NoError(t, store.DeleteOrder(t.Context(), first.ID))
+			require.NoError(t, store.DeleteProduct(t.Context(), "p-1"))
+
+			// 墓碑对读写不可见
+			_, err := store.GetOrder(t.Context(), first.ID)
+			assert.True(t, errors.Is(err, ErrNotFound))
+			assert.True(t, errors.Is(store.UpdateOrder(t.Context(), first), ErrNotFound))
+			assert.True(t, errors.Is(store.DeleteOrder(t.Context(), first.ID), ErrNotFound))
+			orders, err := store.GetOrdersByUserID(t.Context(), "user-1")
+			require.NoError(t, err)
+			assert.Equal(t, []Order{second}, orders)
+			products, err := store.GetProducts(t.Context())
+			require.NoError(t, err)
+			assert.Empty(t, products)
+
+			page, err := store.List(t.Context(), "orders", Query{Filter: map[string]string{"user_id": "user-1"}})
+			require.NoError(t, err)
+			assert.Len(t, page.Items, 1)
+			page, err = store.List(t.Context(), "orders", Query{WithDeleted: true})
+			require.NoError(t, err)
+			all, err := DecodePage[Order](page)
+			require.NoError(t, err)
+			require.Len(t, all, 2)
+			assert.Equal(t, first.ID, all[0].ID)
+			assert.NotZero(t, all[0].Deleted)
+
+			// 恢复
+			require.NoError(t, store.Undelete(t.Context(), "orders", first.ID))
+			got, err := store.GetOrder(t.Context(), first.ID)
+			require.NoError(t, err)
+			assert.Equal(t, first, got)
+			assert.True(t, errors.Is(store.Undelete(t.Context(), "orders", first.ID), ErrNotFound), "记录未被删除")
+			assert.True(t, errors.Is(store.Undelete(t.Context(), "orders", "missing"), ErrNotFound))
+			assert.True(t, errors.Is(store.Undelete(t.Context(), "missing", first.ID), ErrInvalidQuery))
+
+			// 新建记录覆盖同一主键的墓碑
+			require.NoError(t, store.DeleteOrder(t.Context(), second.ID))
+			replacement := Order{ID: second.ID, UserID: "user-2", Status: "paid"}
+			require.NoError(t, store.CreateOrder(t.Context(), replacement))
+			got, err = store.GetOrder(t.Context(), second.ID)
+			require.NoError(t, err)
+			assert.Equal(t, replacement, got)
+			assert.True(t, errors.Is(store.CreateOrder(t.Context(), replacement), ErrAlreadyExists))
+
+			// 清理早于 before 的墓碑
+			require.NoError(t, store.DeleteOrder(t.Context(), first.ID))
+			purged, err := store.Purge(t.Context(), "orders", time.Now().Add(-time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, 0, purged)
+			purged, err = store.Purge(t.Context(), "orders", time.Now().Add(time.Second))
+			require.NoError(t, err)
+			assert.Equal(t, 1, purged)
+			assert.True(t, errors.Is(store.Undelete(t.Context(), "orders", first.ID), ErrNotFound))
+			page, err = store.List(t.Context(), "orders", Query{WithDeleted: true})
+			require.NoError(t, err)
+			assert.Len(t, page.Items, 1)
+		})
+	}
+}
+
+func TestTombstones_Watch(t *testing.T) {
+	for name, open := range txBackends() {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			user := User{ID: "user-1", Username: "Alice"}
+			require.NoError(t, store.Create(t.Context(), user))
+
+			users, err := store.Watch(t.Context(), "users")
+			require.NoError(t, err)
+
+			require.NoError(t, store.Delete(t.Context(), user.ID))
+			require.NoError(t, store.Undelete(t.Context(), "users", user.ID))
+			require.NoError(t, store.Delete(t.Context(), user.ID))
+			_, err = store.Purge(t.Context(), "users", time.Now().Add(time.Second))
+			require.NoError(t, err)
+			require.NoError(t, store.Create(t.Context(), user))
+
+			changes := nextChanges(t, users, 4)
+			assert.Equal(t, ChangeDelete, changes[0].Type)
+			assert.Equal(t, user, changes[0].Before)
+			assert.Nil(t, changes[0].After)
+			assert.Equal(t, ChangeCreate, changes[1].Type)
+			assert.Equal(t, user, changes[1].After)
+			assert.Equal(t, ChangeDelete, changes[2].Type)
+			// 清理墓碑不产生变化，之后的新建紧随其后
+			assert.Equal(t, ChangeCreate, changes[3].Type)
+			assert.Equal(t, user, changes[3].After)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Axpz/store/internal/config"
+	"go.uber.org/zap"
+)
+
+// defaultSweepInterval 是清扫过期记录的默认间隔
+const defaultSweepInterval = time.Minute
+
+// Expiry 由支持记录过期的存储实现（全部后端）。
+//
+// 记录的 Expires 是过期时间（Unix 秒），0 表示不过期。到期的记录立即对读写不可见，
+// 与不存在的记录相同，Create 可以用同一主键覆盖它；Expire（通常由 Sweeper 定期调用）
+// 随后把它彻底删除，并向 Watch 的订阅者发布 ChangeDelete。
+type Expiry interface {
+	// Expire 彻底删除表 tableName 中在 now 时已过期的记录，返回删除的记录数
+	Expire(ctx context.Context, tableName string, now time.Time) (int, error)
+}
+
+// expired 判断记录在 now（Unix 秒）时已过期
+func expired(record any, now int64) bool {
+	at := int64Field(record, "Expires")
+	return at != 0 && at <= now
+}
+
+// visible 判断记录在 now（Unix 秒）时对读写可见：未被软删除且未过期
+func visible(record any, now int64) bool {
+	return deletedAt(record) == 0 && !expired(record, now)
+}
+
+// rawVisible 是 visible 对记录 JSON 的版本
+func rawVisible(data []byte, now int64) bool {
+	times := rawTimes(data)
+	return times.Deleted == 0 && (times.Expires == 0 || times.Expires > now)
+}
+
+// Sweeper 定期彻底删除过期的记录。过期的记录到期后已经对读写不可见，清扫只负责回收空间
+// 并发布 ChangeDelete，所以和 Purger 一样由长期运行的服务启动，一次性的命令行子命令不需要
+type Sweeper struct {
+	store  StoreInterface
+	cfg    config.TTLConfig
+	logger *zap.Logger
+	// now 返回当前时间，测试中可替换
+	now func() time.Time
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// NewSweeper 创建过期记录的定时清扫，调用 Start 后开始运行
+func NewSweeper(store StoreInterface, cfg config.TTLConfig, logger *zap.Logger) *Sweeper {
+	if cfg.SweepInterval <= 0 {
+		cfg.SweepInterval = defaultSweepInterval
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Sweeper{store: store, cfg: cfg, logger: logger, now: time.Now}
+}
+
+// Start 在后台按间隔清扫过期记录
+func (s *Sweeper) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stop, s.done = cancel, make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.cfg.SweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				removed, err := s.RunOnce(ctx)
+				if err != nil {
+					s.logger.Error("清扫过期记录失败", zap.Error(err))
+					continue
+				}
+				if removed > 0 {
+					s.logger.Info("清扫过期记录完成", zap.Int("removed", removed))
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止定时清扫，等待正在进行的清扫结束
+func (s *Sweeper) Stop() {
+	if s.stop == nil {
+		return
+	}
+	s.stop()
+	<-s.done
+}
+
+// RunOnce 删除每张表中已过期的记录，返回删除的记录总数
+func (s *Sweeper) RunOnce(ctx context.Context) (int, error) {
+	now := s.now()
+
+	var total int
+	for _, tableName := range TableNames {
+		n, err := s.store.Expire(ctx, tableName, now)
+		if err != nil {
+			return total, fmt.Errorf("清扫表 %s 失败: %w", tableName, err)
+		}
+		total += n
+	}
+	return total, nil
+}
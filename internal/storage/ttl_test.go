@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpiry_AcrossBackends(t *testing.T) {
+	for name, open := range txBackends() {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			now := time.Now()
+			stale := Order{ID: "o-1", UserID: "user-1", Status: "pending", Expires: now.Add(-time.Minute).Unix()}
+			fresh := Order{ID: "o-2", UserID: "user-1", Status: "pending", Expires: now.Add(time.Hour).Unix()}
+			require.NoError(t, store.CreateOrder(t.Context(), stale))
+			require.NoError(t, store.CreateOrder(t.Context(), fresh))
+			require.NoError(t, store.CreateProduct(t.Context(), Product{ID: "p-1", Name: "Widget", Expires: now.Add(-time.Minute).Unix()}))
+
+			// 过期记录在清扫之前就对读写不可见
+			_, err := store.GetOrder(t.Context(), stale.ID)
+			assert.True(t, errors.Is(err, ErrNotFound))
+			assert.True(t, errors.Is(store.UpdateOrder(t.Context(), stale), ErrNotFound))
+			assert.True(t, errors.Is(store.DeleteOrder(t.Context(), stale.ID), ErrNotFound))
+			got, err := store.GetOrder(t.Context(), fresh.ID)
+			require.NoError(t, err)
+			assert.Equal(t, fresh, got)
+			orders, err := store.GetOrdersByUserID(t.Context(), "user-1")
+			require.NoError(t, err)
+			assert.Equal(t, []Order{fresh}, orders)
+			products, err := store.GetProducts(t.Context())
+			require.NoError(t, err)
+			assert.Empty(t, products)
+
+			page, err := store.List(t.Context(), "orders", Query{Filter: map[string]string{"user_id": "user-1"}})
+			require.NoError(t, err)
+			assert.Len(t, page.Items, 1)
+			page, err = store.List(t.Context(), "orders", Query{WithDeleted: true})
+			require.NoError(t, err)
+			assert.Len(t, page.Items, 2)
+
+			// 清扫只删除已过期的记录
+			removed, err := store.Expire(t.Context(), "orders", now)
+			require.NoError(t, err)
+			assert.Equal(t, 1, removed)
+			page, err = store.List(t.Context(), "orders", Query{WithDeleted: true})
+			require.NoError(t, err)
+			all, err := DecodePage[Order](page)
+			require.NoError(t, err)
+			require.Len(t, all, 1)
+			assert.Equal(t, fresh.ID, all[0].ID)
+			_, err = store.Expire(t.Context(), "missing", now)
+			assert.True(t, errors.Is(err, ErrInvalidQuery))
+
+			// 新建记录覆盖同一主键尚未清扫的过期记录
+			replacement := Product{ID: "p-1", Name: "Gadget"}
+			require.NoError(t, store.CreateProduct(t.Context(), replacement))
+			product, err := store.GetProduct(t.Context(), replacement.ID)
+			require.NoError(t, err)
+			assert.Equal(t, replacement, product)
+			removed, err = store.Expire(t.Context(), "products", time.Now())
+			require.NoError(t, err)
+			assert.Equal(t, 0, removed)
+		})
+	}
+}
+
+func TestExpiry_Watch(t *testing.T) {
+	for name, open := range txBackends() {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			expires := time.Now().Add(-time.Minute).Unix()
+			user := User{ID: "user-1", Username: "Alice", Expires: expires}
+			deleted := User{ID: "user-2", Username: "Bob", Expires: expires}
+			require.NoError(t, store.Create(t.Context(), user))
+			require.NoError(t, store.Create(t.Context(), User{ID: deleted.ID, Username: deleted.Username}))
+			require.NoError(t, store.Delete(t.Context(), deleted.ID))
+
+			users, err := store.Watch(t.Context(), "users")
+			require.NoError(t, err)
+
+			_, err = store.Expire(t.Context(), "users", time.Now())
+			require.NoError(t, err)
+			later := User{ID: "user-3", Username: "Carol"}
+			require.NoError(t, store.Create(t.Context(), later))
+
+			changes := nextChanges(t, users, 2)
+			assert.Equal(t, ChangeDelete, changes[0].Type)
+			assert.Equal(t, user, changes[0].Before)
+			assert.Nil(t, changes[0].After)
+			assert.Equal(t, ChangeCreate, changes[1].Type)
+			assert.Equal(t, later, changes[1].After)
+		})
+	}
+}
+
+// TestExpiry_WatchOverwrite 覆盖尚未清扫的过期记录发布 ChangeCreate，与 SQL 后端一致
+func TestExpiry_WatchOverwrite(t *testing.T) {
+	for name, open := range txBackends() {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			expires := time.Now().Add(-time.Minute).Unix()
+			require.NoError(t, store.Create(t.Context(), User{ID: "user-1", Username: "Alice", Expires: expires}))
+			require.NoError(t, store.Create(t.Context(), User{ID: "user-2", Username: "Bob", Expires: expires}))
+
+			users, err := store.Watch(t.Context(), "users")
+			require.NoError(t, err)
+
+			replacement := User{ID: "user-1", Username: "Alice Again"}
+			require.NoError(t, store.Create(t.Context(), replacement))
+			inTx := User{ID: "user-2", Username: "Bob Again"}
+			require.NoError(t, store.Tx(t.Context(), func(tx Records) error {
+				return tx.Create(t.Context(), inTx)
+			}))
+
+			changes := nextChanges(t, users, 2)
+			for i, want := range []User{replacement, inTx} {
+				assert.Equal(t, ChangeCreate, changes[i].Type)
+				assert.Nil(t, changes[i].Before)
+				assert.Equal(t, want, changes[i].After)
+			}
+		})
+	}
+}
+
+func TestSweeper_RunOnce(t *testing.T) {
+	store, err := NewMemoryStore(&config.Config{})
+	require.NoError(t, err)
+
+	expires := time.Now().Add(time.Hour).Unix()
+	require.NoError(t, store.Create(t.Context(), User{ID: "user-1", Expires: expires}))
+	require.NoError(t, store.CreateOrder(t.Context(), Order{ID: "o-1", UserID: "user-1", Expires: expires}))
+	require.NoError(t, store.CreateOrder(t.Context(), Order{ID: "o-2", UserID: "user-1"}))
+
+	sweeper := NewSweeper(store, config.TTLConfig{}, nil)
+
+	// 未到期的记录不清扫
+	removed, err := sweeper.RunOnce(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 0, removed)
+
+	sweeper.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	removed, err = sweeper.RunOnce(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	page, err := store.List(t.Context(), "orders", Query{WithDeleted: true})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 1, "不过期的记录保留")
+}
+
+func TestSweeper_Start(t *testing.T) {
+	store, err := NewMemoryStore(&config.Config{})
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	require.NoError(t, store.Create(t.Context(), User{ID: "user-1", Expires: time.Now().Add(-time.Second).Unix()}))
+	require.NoError(t, store.Create(t.Context(), User{ID: "user-2"}))
+
+	sweeper := NewSweeper(store, config.TTLConfig{SweepInterval: 10 * time.Millisecond}, nil)
+	sweeper.Start()
+	t.Cleanup(sweeper.Stop)
+
+	require.Eventually(t, func() bool {
+		page, err := store.List(t.Context(), "users", Query{WithDeleted: true})
+		return err == nil && len(page.Items) == 1
+	}, time.Second, 10*time.Millisecond)
+}
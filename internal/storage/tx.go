@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+)
+
+// txChange 是事务中对一条记录的修改
+type txChange struct {
+	table string
+	id    string
+	// value 是写入的记录，为 nil 表示删除
+	value any
+}
+
+// Tx 在一个事务中执行 fn。
+//
+// 执行 fn 期间持有存储的写锁，其他读写等待，因此事务之间是串行的。
+// fn 通过 tx 操作一组影子表：首次访问时复制正式表，之后的修改只作用于影子表并记录下来。
+// fn 成功返回后，由 driver 把全部修改作为一个整体持久化，再把修改过的记录写入正式表；
+// 任何一步失败都只需丢弃影子表，正式表不受影响。
+// GitHubStore 在提交访问网络期间释放写锁，期间其他写入修改的其他记录保留在正式表中。
+func (s *Store) Tx(ctx context.Context, fn func(tx Records) error) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := &txDriver{store: s, tables: make(map[string]any)}
+	if err := fn(NewStore(s.config, d, 0)); err != nil {
+		return err
+	}
+	if len(d.changes) == 0 {
+		return nil
+	}
+
+	if err := ctxErr(ctx); err != nil {
+		return err
+	}
+	if err := s.driver.commitTx(ctx, d.changes, d.tables); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(d.tables))
+	for tableName := range d.tables {
+		names = append(names, tableName)
+	}
+	sort.Strings(names)
+
+	ids := make(map[string][]string)
+	for _, c := range d.changes {
+		if !slices.Contains(ids[c.table], c.id) {
+			ids[c.table] = append(ids[c.table], c.id)
+		}
+	}
+
+	var changes []Change
+	for _, tableName := range names {
+		t, err := s.table(tableName)
+		if err != nil {
+			return err
+		}
+		tracked, err := t.track(ids[tableName], func() error {
+			t.merge(d.tables[tableName], ids[tableName])
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		changes = append(changes, tracked...)
+	}
+	for _, tableName := range names {
+		t, err := s.table(tableName)
+		if err != nil {
+			return err
+		}
+		if err := s.driver.saveTable(ctx, tableName, t.data()); err != nil {
+			return err
+		}
+	}
+
+	// 事务的全部变化在提交后一起发布，序号连续
+	s.feed.publish(changes...)
+	return nil
+}
+
+// txDriver 是事务中影子表的 driver。调用时正式存储的写锁由 Tx 持有。
+type txDriver struct {
+	store   *Store
+	changes []txChange
+	// tables 记录被修改过的影子表（表名 → 指向 map 的指针）
+	tables map[string]any
+}
+
+// loadTable 首次访问时加载正式表并复制一份
+func (d *txDriver) loadTable(ctx context.Context, tableName string, data any) error {
+	t, err := d.store.table(tableName)
+	if err != nil {
+		return err
+	}
+	if err := t.ensureLoaded(ctx); err != nil {
+		return err
+	}
+
+	t.copyTo(data)
+	return nil
+}
+
+func (d *txDriver) beforePut(ctx context.Context, tableName, id string, value any) error {
+	d.changes = append(d.changes, txChange{table: tableName, id: id, value: value})
+	return nil
+}
+
+func (d *txDriver) beforeDelete(ctx context.Context, tableName, id string) error {
+	d.changes = append(d.changes, txChange{table: tableName, id: id})
+	return nil
+}
+
+func (d *txDriver) saveTable(ctx context.Context, tableName string, data any) error {
+	d.tables[tableName] = data
+	return nil
+}
+
+// commitTx 事务不能嵌套
+func (d *txDriver) commitTx(ctx context.Context, changes []txChange, tables map[string]any) error {
+	return errors.New("不支持嵌套事务")
+}
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// txBackends 返回所有可以在本地运行的后端
+func txBackends() map[string]func(t *testing.T) StoreInterface {
+	return map[string]func(t *testing.T) StoreInterface{
+		"local": func(t *testing.T) StoreInterface {
+			store, err := NewLocalStore(&config.Config{
+				Storage: config.StorageConfig{Type: "local", Path: t.TempDir()},
+			})
+			require.NoError(t, err)
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+		"github": func(t *testing.T) StoreInterface {
+			fake := newFakeGitHub(t)
+			for _, path := range []string{"users", "orders", "products", "comments"} {
+				fake.setTable(t, "tables/"+path+".json", map[string]any{})
+			}
+			return fake.newStore(t)
+		},
+		"github-records": func(t *testing.T) StoreInterface {
+			return newFakeGitHub(t).newRecordStore(t, 0)
+		},
+		"memory": func(t *testing.T) StoreInterface {
+			store, err := NewMemoryStore(&config.Config{})
+			require.NoError(t, err)
+			return store
+		},
+		"sqlite": func(t *testing.T) StoreInterface {
+			return openTestSQLiteStore(t, filepath.Join(t.TempDir(), "store.db"))
+		},
+		"bolt": func(t *testing.T) StoreInterface {
+			return openTestBoltStore(t, filepath.Join(t.TempDir(), "store.db"))
+		},
+	}
+}
+
+func TestTx_CommitAndRollback(t *testing.T) {
+	for name, open := range txBackends() {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			require.NoError(t, store.CreateProduct(t.Context(), Product{ID: "p-1", Name: "Widget", Status: "in_stock"}))
+
+			// 提交：下单并修改库存，事务内可以读到自己的写入
+			err := store.Tx(t.Context(), func(tx Records) error {
+				if err := tx.CreateOrder(t.Context(), Order{ID: "o-1", UserID: "user-1"}); err != nil {
+					return err
+				}
+				if _, err := tx.GetOrder(t.Context(), "o-1"); err != nil {
+					return err
+				}
+				return tx.UpdateProduct(t.Context(), Product{ID: "p-1", Name: "Widget", Status: "reserved"})
+			})
+			require.NoError(t, err)
+
+			_, err = store.GetOrder(t.Context(), "o-1")
+			assert.NoError(t, err)
+			product, err := store.GetProduct(t.Context(), "p-1")
+			require.NoError(t, err)
+			assert.Equal(t, "reserved", product.Status)
+
+			// 一个事务修改三张表
+			err = store.Tx(t.Context(), func(tx Records) error {
+				if err := tx.CreateOrder(t.Context(), Order{ID: "o-2", UserID: "user-1"}); err != nil {
+					return err
+				}
+				if err := tx.UpdateProduct(t.Context(), Product{ID: "p-1", Name: "Widget", Status: "sold"}); err != nil {
+					return err
+				}
+				return tx.CreateComment(t.Context(), Comment{ID: "c-1"})
+			})
+			require.NoError(t, err)
+
+			// 回滚：最后一个操作失败，之前的操作也不生效
+			err = store.Tx(t.Context(), func(tx Records) error {
+				if err := tx.CreateOrder(t.Context(), Order{ID: "o-3", UserID: "user-1"}); err != nil {
+					return err
+				}
+				if err := tx.DeleteProduct(t.Context(), "p-1"); err != nil {
+					return err
+				}
+				return tx.CreateComment(t.Context(), Comment{ID: "c-1"})
+			})
+			assert.ErrorIs(t, err, ErrAlreadyExists)
+
+			// 回滚：fn 返回错误
+			errStop := errors.New("stop")
+			err = store.Tx(t.Context(), func(tx Records) error {
+				if err := tx.Delete(t.Context(), "missing"); !errors.Is(err, ErrNotFound) {
+					return err
+				}
+				return errStop
+			})
+			assert.ErrorIs(t, err, errStop)
+
+			_, err = store.GetOrder(t.Context(), "o-3")
+			assert.ErrorIs(t, err, ErrNotFound)
+			product, err = store.GetProduct(t.Context(), "p-1")
+			require.NoError(t, err)
+			assert.Equal(t, "sold", product.Status)
+		})
+	}
+}
+
+func TestLocalStore_TxIsOneJournalRecord(t *testing.T) {
+	store, tempDir, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	err := store.Tx(t.Context(), func(tx Records) error {
+		if err := tx.CreateOrder(t.Context(), Order{ID: "o-1", UserID: "user-1"}); err != nil {
+			return err
+		}
+		return tx.CreateProduct(t.Context(), Product{ID: "p-1"})
+	})
+	require.NoError(t, err)
+
+	journalPath := filepath.Join(tempDir, journalFile)
+	data, err := os.ReadFile(journalPath)
+	require.NoError(t, err)
+	entries, _, err := readJournal(bytes.NewReader(data), nil)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, journalOpTx, entries[0].Op)
+
+	// 表文件写入前崩溃：日志回放恢复整个事务
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "orders"), []byte("{}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "products"), []byte("{}"), 0644))
+
+	reopened := reopenLocalStore(t, tempDir)
+	_, err = reopened.GetOrder(t.Context(), "o-1")
+	assert.NoError(t, err)
+	_, err = reopened.GetProduct(t.Context(), "p-1")
+	assert.NoError(t, err)
+
+	// 事务记录写到一半时崩溃：整个事务都不生效
+	require.NoError(t, os.WriteFile(journalPath, data[:len(data)-10], 0644))
+
+	torn := reopenLocalStore(t, tempDir)
+	_, err = torn.GetOrder(t.Context(), "o-1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = torn.GetProduct(t.Context(), "p-1")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestGitHubStore_TxIsOneCommit(t *testing.T) {
+	fake := newFakeGitHub(t)
+	fake.setTable(t, "tables/orders.json", map[string]Order{})
+	fake.setTable(t, "tables/products.json", map[string]Product{"p-1": {ID: "p-1"}})
+	fake.setTable(t, "tables/comments.json", map[string]Comment{})
+
+	store := fake.newStore(t)
+
+	// 提交期间分支被推进一次，事务以新的分支头重试
+	raced := false
+	fake.beforeUpdateRef = func() {
+		if !raced {
+			raced = true
+			fake.put("tables/comments.json", []byte(`{"c-1": {"id": "c-1"}}`))
+		}
+	}
+
+	err := store.Tx(t.Context(), func(tx Records) error {
+		if err := tx.CreateOrder(t.Context(), Order{ID: "o-1", UserID: "user-1"}); err != nil {
+			return err
+		}
+		return tx.UpdateProduct(t.Context(), Product{ID: "p-1", Status: "reserved"})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, fake.gitCommits)
+
+	var orders map[string]Order
+	fake.table(t, "tables/orders.json", &orders)
+	assert.Contains(t, orders, "o-1")
+	var products map[string]Product
+	fake.table(t, "tables/products.json", &products)
+	assert.Equal(t, "reserved", products["p-1"].Status)
+	var comments map[string]Comment
+	fake.table(t, "tables/comments.json", &comments)
+	assert.Contains(t, comments, "c-1", "并发提交的其他文件保留")
+
+	// 提交后同步版本已推进，写回队列不再重复提交
+	require.NoError(t, store.Flush(t.Context()))
+	assert.Equal(t, 1, fake.gitCommits)
+
+	// 事务涉及的表已在远端被修改：整体放弃
+	fake.setTable(t, "tables/products.json", map[string]Product{"p-1": {ID: "p-1", Status: "sold"}})
+	err = store.Tx(t.Context(), func(tx Records) error {
+		if err := tx.CreateOrder(t.Context(), Order{ID: "o-2", UserID: "user-1"}); err != nil {
+			return err
+		}
+		return tx.UpdateProduct(t.Context(), Product{ID: "p-1", Status: "reserved"})
+	})
+	assert.ErrorIs(t, err, ErrConflict)
+	_, err = store.GetOrder(t.Context(), "o-2")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 1, fake.gitCommits)
+}
+
+func TestGitHubStore_WritesDuringTx(t *testing.T) {
+	fake := newFakeGitHub(t)
+	fake.setTable(t, "tables/orders.json", map[string]Order{})
+
+	store := fake.newStore(t)
+
+	// 事务提交等待 GitHub 时，其他记录的写入不被阻塞
+	fake.beforeUpdateRef = func() {
+		fake.beforeUpdateRef = nil
+		done := make(chan error, 1)
+		go func() {
+			done <- store.CreateOrder(context.Background(), Order{ID: "o-2", UserID: "user-2"})
+		}()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Error("事务提交期间的写入被阻塞")
+		}
+	}
+	err := store.Tx(t.Context(), func(tx Records) error {
+		return tx.CreateOrder(t.Context(), Order{ID: "o-1", UserID: "user-1"})
+	})
+	require.NoError(t, err)
+
+	var orders map[string]Order
+	fake.table(t, "tables/orders.json", &orders)
+	assert.Contains(t, orders, "o-1")
+	assert.NotContains(t, orders, "o-2", "提交的是事务的影子表")
+
+	// 提交期间的写入保留在内存表中，由写回队列提交
+	for _, id := range []string{"o-1", "o-2"} {
+		_, err := store.GetOrder(t.Context(), id)
+		assert.NoError(t, err, id)
+	}
+	require.NoError(t, store.Flush(t.Context()))
+	fake.table(t, "tables/orders.json", &orders)
+	assert.Contains(t, orders, "o-1")
+	assert.Contains(t, orders, "o-2")
+}
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// watchBuffer 是每个订阅者的事件缓冲区大小
+const watchBuffer = 1024
+
+// ChangeType 是记录变化的类型
+type ChangeType string
+
+const (
+	// ChangeCreate 新建记录
+	ChangeCreate ChangeType = "create"
+	// ChangeUpdate 修改记录
+	ChangeUpdate ChangeType = "update"
+	// ChangeDelete 删除记录
+	ChangeDelete ChangeType = "delete"
+)
+
+// Change 是一张表中一条记录的变化
+type Change struct {
+	Table string
+	ID    string
+	Type  ChangeType
+	// Revision 是变化的序号，在同一个存储实例内单调递增
+	Revision uint64
+	// Before 和 After 是变化前后的记录，类型与表对应（User、Order、Product 或 Comment）。
+	// 新建时 Before 为 nil，删除时 After 为 nil。
+	Before, After any
+	// Remote 表示变化来自其他副本，例如从 GitHub 远端提交合并进来的修改
+	Remote bool
+	// Conflict 表示本地对该记录的修改与远端冲突，远端版本胜出，本地修改被丢弃（Before 是被丢弃的本地版本）。
+	// 只出现在 GitHub 后端写回提交合并远端修改时，此时 Remote 也为 true
+	Conflict bool
+}
+
+// Watcher 由支持变化通知的存储实现。
+// 变化只在写入所在的存储实例内分发：GitHub 后端会把合并进来的其他副本的提交作为 Remote 变化发布，
+// SQL（含多副本共用同一数据库的 postgres）和 bbolt 后端只报告本实例的写入，
+// 其他副本或进程直接写入数据库的修改不会出现在本实例的 Watch 中。
+type Watcher interface {
+	// Watch 返回表 tableName 的变化事件，按 Revision 递增的顺序送达，只包含调用之后的变化。
+	// 通道在 ctx 结束、存储关闭或订阅者消费过慢（缓冲区满）时关闭；
+	// 最后一种情况下调用方应重新 Watch 并重新读取数据。表不存在时返回 ErrInvalidQuery。
+	Watch(ctx context.Context, tableName string) (<-chan Change, error)
+}
+
+// changeFeed 为存储分配变化的序号并分发给订阅者。
+// publish 不阻塞，可以在持有存储锁时调用，保证事件顺序与写入顺序一致。
+type changeFeed struct {
+	mu       sync.Mutex
+	revision uint64
+	watchers map[*watcher]struct{}
+	closed   bool
+}
+
+type watcher struct {
+	table string
+	ch    chan Change
+	// done 在订阅者被移除时关闭，结束等待 ctx 的 goroutine
+	done chan struct{}
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{watchers: make(map[*watcher]struct{})}
+}
+
+// watch 订阅表 tableName 的变化，直到 ctx 结束或 feed 关闭
+func (f *changeFeed) watch(ctx context.Context, tableName string) (<-chan Change, error) {
+	if !slices.Contains(TableNames, tableName) {
+		return nil, fmt.Errorf("未知的表 %s: %w", tableName, ErrInvalidQuery)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w := &watcher{table: tableName, ch: make(chan Change, watchBuffer), done: make(chan struct{})}
+	if f.closed {
+		close(w.ch)
+		return w.ch, nil
+	}
+	f.watchers[w] = struct{}{}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-w.done:
+			return
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.remove(w)
+	}()
+	return w.ch, nil
+}
+
+// watching 判断是否有订阅者关注表 tableName，没有时后端可以省去读取变化前记录的开销
+func (f *changeFeed) watching(tableName string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for w := range f.watchers {
+		if w.table == tableName {
+			return true
+		}
+	}
+	return false
+}
+
+// publish 依次为 changes 分配序号并发送给订阅者。缓冲区已满的订阅者被移除，其通道关闭。
+func (f *changeFeed) publish(changes ...Change) {
+	if len(changes) == 0 {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, c := range changes {
+		f.revision++
+		c.Revision = f.revision
+		for w := range f.watchers {
+			if w.table != c.Table {
+				continue
+			}
+			select {
+			case w.ch <- c:
+			default:
+				f.remove(w)
+			}
+		}
+	}
+}
+
+// close 关闭全部订阅者的通道，之后的 watch 返回已关闭的通道
+func (f *changeFeed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for w := range f.watchers {
+		f.remove(w)
+	}
+	f.closed = true
+}
+
+// remove 移除订阅者并关闭其通道，调用方持有锁
+func (f *changeFeed) remove(w *watcher) {
+	if _, ok := f.watchers[w]; !ok {
+		return
+	}
+	delete(f.watchers, w)
+	close(w.ch)
+	close(w.done)
+}
+
+// newChange 根据记录变化前后是否存在构造变化事件，两边都不存在时返回 false。
+// 墓碑（软删除的记录）视为不存在：软删除是 ChangeDelete，恢复是 ChangeCreate，清理墓碑不产生事件。
+// 写入覆盖尚未清扫的过期记录同样视为新建；清扫过期记录仍是 ChangeDelete
+func newChange(tableName, id string, before any, hadBefore bool, after any, hasAfter bool) (Change, bool) {
+	hadBefore = hadBefore && deletedAt(before) == 0 && !(hasAfter && expired(before, time.Now().Unix()))
+	hasAfter = hasAfter && deletedAt(after) == 0
+
+	c := Change{Table: tableName, ID: id}
+	switch {
+	case hadBefore && hasAfter:
+		c.Type, c.Before, c.After = ChangeUpdate, before, after
+	case hasAfter:
+		c.Type, c.After = ChangeCreate, after
+	case hadBefore:
+		c.Type, c.Before = ChangeDelete, before
+	default:
+		return Change{}, false
+	}
+	return c, true
+}
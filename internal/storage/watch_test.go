@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Axpz/store/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nextChanges 从 ch 读取 n 个变化，超时则测试失败
+func nextChanges(t *testing.T, ch <-chan Change, n int) []Change {
+	t.Helper()
+
+	var changes []Change
+	for len(changes) < n {
+		select {
+		case c, ok := <-ch:
+			require.True(t, ok, "通道已关闭")
+			changes = append(changes, c)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("等待变化超时，已收到 %d 个", len(changes))
+		}
+	}
+	return changes
+}
+
+func TestWatch_AcrossBackends(t *testing.T) {
+	for name, open := range txBackends() {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			orders, err := store.Watch(t.Context(), "orders")
+			require.NoError(t, err)
+			users, err := store.Watch(t.Context(), "users")
+			require.NoError(t, err)
+
+			pending := Order{ID: "o-1", UserID: "user-1", Status: "pending"}
+			paid := pending
+			paid.Status = "paid"
+			created := Order{ID: "o-2", UserID: "user-1", Status: "pending"}
+
+			require.NoError(t, store.CreateOrder(t.Context(), pending))
+			require.NoError(t, store.UpdateOrder(t.Context(), paid))
+			// 失败的写入不产生变化
+			require.True(t, errors.Is(store.UpdateOrder(t.Context(), Order{ID: "missing"}), ErrNotFound))
+			require.NoError(t, store.Tx(t.Context(), func(tx Records) error {
+				if err := tx.CreateOrder(t.Context(), created); err != nil {
+					return err
+				}
+				return tx.DeleteOrder(t.Context(), paid.ID)
+			}))
+
+			changes := nextChanges(t, orders, 4)
+			for i, c := range changes {
+				if i > 0 {
+					assert.Greater(t, c.Revision, changes[i-1].Revision)
+				}
+				c.Revision = 0
+				changes[i] = c
+			}
+			assert.Equal(t, []Change{
+				{Table: "orders", ID: "o-1", Type: ChangeCreate, After: pending},
+				{Table: "orders", ID: "o-1", Type: ChangeUpdate, Before: pending, After: paid},
+				{Table: "orders", ID: "o-2", Type: ChangeCreate, After: created},
+				{Table: "orders", ID: "o-1", Type: ChangeDelete, Before: paid},
+			}, changes)
+
+			// 其他表的订阅者收不到订单的变化
+			select {
+			case c := <-users:
+				t.Fatalf("收到了其他表的变化: %+v", c)
+			default:
+			}
+
+			_, err = store.Watch(t.Context(), "missing")
+			assert.True(t, errors.Is(err, ErrInvalidQuery))
+		})
+	}
+}
+
+func TestWatch_ExpiredTombstoneIsNotPublished(t *testing.T) {
+	for name, open := range txBackends() {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			now := time.Now()
+			require.NoError(t, store.CreateComment(t.Context(), Comment{ID: "c-1", Expires: now.Add(time.Minute).Unix()}))
+			require.NoError(t, store.DeleteComment(t.Context(), "c-1"))
+
+			comments, err := store.Watch(t.Context(), "comments")
+			require.NoError(t, err)
+
+			// 清扫已软删除的过期记录不产生变化，也不占用序号
+			require.NoError(t, store.CreateComment(t.Context(), Comment{ID: "c-2"}))
+			n, err := store.Expire(t.Context(), "comments", now.Add(time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, 1, n)
+			require.NoError(t, store.CreateComment(t.Context(), Comment{ID: "c-3"}))
+
+			changes := nextChanges(t, comments, 2)
+			assert.Equal(t, "c-2", changes[0].ID)
+			assert.Equal(t, "c-3", changes[1].ID)
+			assert.Equal(t, changes[0].Revision+1, changes[1].Revision)
+		})
+	}
+}
+
+func TestWatch_RolledBackTxIsNotPublished(t *testing.T) {
+	for name, open := range txBackends() {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			ch, err := store.Watch(t.Context(), "comments")
+			require.NoError(t, err)
+
+			errRollback := errors.New("rollback")
+			err = store.Tx(t.Context(), func(tx Records) error {
+				if err := tx.CreateComment(t.Context(), Comment{ID: "c-1"}); err != nil {
+					return err
+				}
+				return errRollback
+			})
+			require.ErrorIs(t, err, errRollback)
+
+			require.NoError(t, store.CreateComment(t.Context(), Comment{ID: "c-2"}))
+			assert.Equal(t, "c-2", nextChanges(t, ch, 1)[0].ID)
+		})
+	}
+}
+
+func TestWatch_ChannelLifecycle(t *testing.T) {
+	store, err := NewMemoryStore(&config.Config{})
+	require.NoError(t, err)
+
+	// ctx 结束时关闭通道
+	ctx, cancel := context.WithCancel(t.Context())
+	ch, err := store.Watch(ctx, "users")
+	require.NoError(t, err)
+	cancel()
+	select {
+	case _, ok := <-ch:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ctx 结束后通道没有关闭")
+	}
+
+	// 消费过慢的订阅者被移除，不阻塞写入
+	slow, err := store.Watch(t.Context(), "users")
+	require.NoError(t, err)
+	for i := range watchBuffer + 1 {
+		require.NoError(t, store.Create(t.Context(), User{ID: fmt.Sprintf("user-%d", i)}))
+	}
+	n := 0
+	for range slow {
+		n++
+	}
+	assert.Equal(t, watchBuffer, n)
+
+	// 存储关闭时关闭通道，之后的订阅得到已关闭的通道
+	ch, err = store.Watch(t.Context(), "users")
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+	_, ok := <-ch
+	assert.False(t, ok)
+	ch, err = store.Watch(t.Context(), "users")
+	require.NoError(t, err)
+	_, ok = <-ch
+	assert.False(t, ok)
+}
+
+// watchGoroutines 返回正在等待订阅结束的 goroutine 数
+func watchGoroutines() int {
+	var buf bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&buf, 2)
+	return strings.Count(buf.String(), "(*changeFeed).watch.func")
+}
+
+func TestWatch_CloseStopsGoroutines(t *testing.T) {
+	store, err := NewMemoryStore(&config.Config{})
+	require.NoError(t, err)
+	before := watchGoroutines()
+
+	// ctx 永不结束的订阅在存储关闭后也不能留下 goroutine
+	var channels []<-chan Change
+	for range 10 {
+		ch, err := store.Watch(context.Background(), "users")
+		require.NoError(t, err)
+		channels = append(channels, ch)
+	}
+	require.Greater(t, watchGoroutines(), before)
+
+	require.NoError(t, store.Close())
+	for _, ch := range channels {
+		_, ok := <-ch
+		assert.False(t, ok)
+	}
+	assert.Eventually(t, func() bool { return watchGoroutines() <= before },
+		5*time.Second, 10*time.Millisecond, "关闭存储后订阅的 goroutine 没有退出")
+}
+
+func TestGitHubStore_WatchRemoteChanges(t *testing.T) {
+	fake := newFakeGitHub(t)
+	kept := User{ID: "user-1", Username: "Kept"}
+	edited := User{ID: "user-2", Username: "Before"}
+	fake.setTable(t, "tables/users.json", map[string]User{kept.ID: kept, edited.ID: edited})
+
+	store := fake.newStore(t)
+	_, err := store.Get(t.Context(), kept.ID)
+	require.NoError(t, err)
+	ch, err := store.Watch(t.Context(), "users")
+	require.NoError(t, err)
+
+	// 其他副本修改并删除了用户，新增了一个用户
+	after := edited
+	after.Username = "After"
+	added := User{ID: "user-3", Username: "Added"}
+	fake.setTable(t, "tables/users.json", map[string]User{after.ID: after, added.ID: added})
+
+	require.NoError(t, store.Reload(t.Context(), "users"))
+
+	changes := nextChanges(t, ch, 3)
+	for i := range changes {
+		changes[i].Revision = 0
+	}
+	assert.ElementsMatch(t, []Change{
+		{Table: "users", ID: "user-1", Type: ChangeDelete, Before: kept, Remote: true},
+		{Table: "users", ID: "user-2", Type: ChangeUpdate, Before: edited, After: after, Remote: true},
+		{Table: "users", ID: "user-3", Type: ChangeCreate, After: added, Remote: true},
+	}, changes)
+
+	// 本地写入不带 Remote 标记
+	require.NoError(t, store.Create(t.Context(), User{ID: "user-4"}))
+	assert.False(t, nextChanges(t, ch, 1)[0].Remote)
+}
+
+func TestGitHubStore_WatchRemoteRecords(t *testing.T) {
+	fake := newFakeGitHub(t)
+	writer := fake.newRecordStore(t, 0)
+	require.NoError(t, writer.Create(t.Context(), User{ID: "user-1"}))
+	require.NoError(t, writer.Flush(t.Context()))
+
+	reader := fake.newRecordStore(t, 0)
+	_, err := reader.Get(t.Context(), "user-1")
+	require.NoError(t, err)
+	ch, err := reader.Watch(t.Context(), "users")
+	require.NoError(t, err)
+
+	changed := User{ID: "user-1", Username: "changed"}
+	require.NoError(t, writer.Update(t.Context(), changed))
+	require.NoError(t, writer.Flush(t.Context()))
+	require.NoError(t, reader.Reload(t.Context(), "users"))
+
+	c := nextChanges(t, ch, 1)[0]
+	assert.Equal(t, ChangeUpdate, c.Type)
+	assert.Equal(t, changed, c.After)
+	assert.True(t, c.Remote)
+}
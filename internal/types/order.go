@@ -13,16 +13,18 @@ type OrderProduct struct {
 
 // Order 代表订单信息
 type Order struct {
-	ID          string         `json:"id"`           // 订单ID，也是 PayPal 的订单ID
-	UserID      string         `json:"user_id"`      // 用户ID
-	Status      string         `json:"status"`       // 订单状态
-	Currency    string         `json:"currency"`     // 货币类型
-	Products    []OrderProduct `json:"products"`     // 订单包含的商品
-	TotalAmount int64          `json:"total_amount"` // 总金额，单位分
-	PaidAmount  int64          `json:"paid_amount"`  // 已支付金额，单位分
-	Description string         `json:"description"`  // 订单描述
-	Created     int64          `json:"created"`      // 创建时间（时间戳）
-	Updated     int64          `json:"updated"`      // 更新时间（时间戳）
+	ID          string         `json:"id"`                // 订单ID，也是 PayPal 的订单ID
+	UserID      string         `json:"user_id"`           // 用户ID
+	Status      string         `json:"status"`            // 订单状态
+	Currency    string         `json:"currency"`          // 货币类型
+	Products    []OrderProduct `json:"products"`          // 订单包含的商品
+	TotalAmount int64          `json:"total_amount"`      // 总金额，单位分
+	PaidAmount  int64          `json:"paid_amount"`       // 已支付金额，单位分
+	Description string         `json:"description"`       // 订单描述
+	Created     int64          `json:"created"`           // 创建时间（时间戳）
+	Updated     int64          `json:"updated"`           // 更新时间（时间戳）
+	Deleted     int64          `json:"deleted,omitempty"` // 软删除时间（时间戳），0 表示未删除
+	Expires     int64          `json:"expires,omitempty"` // 过期时间（时间戳），到期后记录被自动删除，0 表示不过期
 }
 
 // CreateOrderRequest 创建订单请求
@@ -20,6 +20,10 @@ type User struct {
 	Updated   int64  `json:"updated"`
 	LastLogin int64  `json:"last_login"`
 	Verified  *bool  `json:"verified,omitempty"`
+	// Deleted 软删除时间（时间戳），0 表示未删除
+	Deleted int64 `json:"deleted,omitempty"`
+	// Expires 过期时间（时间戳），到期后记录被自动删除，0 表示不过期
+	Expires int64 `json:"expires,omitempty"`
 }
 
 // UpdateUserRequest 更新用户请求
@@ -49,11 +53,25 @@ type LoginResponse struct {
 	User  User   `json:"user"`
 }
 
-// ErrorResponse 错误响应
+// ErrorResponse 错误响应，Code 是稳定的机器可读错误码
 type ErrorResponse struct {
+	Code  string `json:"code"`
 	Error string `json:"error"`
 }
 
+// 错误码，API 处理器和中间件共用
+const (
+	CodeInvalidArgument = "invalid_argument"
+	CodeUnauthenticated = "unauthenticated"
+	CodeForbidden       = "forbidden"
+	CodeNotFound        = "not_found"
+	CodeAlreadyExists   = "already_exists"
+	CodeConflict        = "conflict"
+	CodeTimeout         = "timeout"
+	CodeUnimplemented   = "unimplemented"
+	CodeInternal        = "internal"
+)
+
 // SuccessResponse 成功响应
 type SuccessResponse struct {
 	Message string `json:"message"`